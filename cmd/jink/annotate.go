@@ -0,0 +1,35 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/lasseh/jink/highlighter"
+	"github.com/lasseh/jink/lexer"
+)
+
+// lineTagWidth pads --annotate-lines tags to the widest one ("[PROMPT]")
+const lineTagWidth = 8
+
+// lineTags maps each line kind to its --annotate-lines tag and the token
+// type whose theme color the tag uses
+var lineTags = map[lexer.LineKind]struct {
+	tag   string
+	color lexer.TokenType
+}{
+	lexer.LineText:   {"[TEXT]", lexer.TokenComment},
+	lexer.LineConfig: {"[CFG]", lexer.TokenCommand},
+	lexer.LineShow:   {"[SHOW]", lexer.TokenColumnHeader},
+	lexer.LinePrompt: {"[PROMPT]", lexer.TokenPromptHostOper},
+	lexer.LineDiff:   {"[DIFF]", lexer.TokenDiffContext},
+}
+
+// lineTag returns the --annotate-lines prefix for a line of input: its kind
+// as a padded tag, colored with the theme unless disabled.
+func lineTag(line string, theme *highlighter.Theme, disabled bool) string {
+	entry := lineTags[lexer.ClassifyLine(highlighter.StripANSI(line))]
+	tag := fmt.Sprintf("%-*s ", lineTagWidth, entry.tag)
+	if color := theme.GetColor(entry.color); color != "" && !disabled {
+		tag = color + tag + highlighter.Reset
+	}
+	return tag
+}
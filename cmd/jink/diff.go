@@ -0,0 +1,266 @@
+package main
+
+import (
+	"io"
+	"os"
+	"strings"
+
+	"github.com/lasseh/jink/highlighter"
+	"github.com/lasseh/jink/lexer"
+)
+
+// diffContext is the number of unchanged lines shown around each change
+const diffContext = 3
+
+// diffOp is the kind of a line in a diff
+type diffOp int
+
+const (
+	diffEqual diffOp = iota
+	diffDelete
+	diffInsert
+)
+
+// diffPrefixes are the "show | compare" line prefixes for each diffOp
+var diffPrefixes = map[diffOp]string{
+	diffEqual:  "  ",
+	diffDelete: "- ",
+	diffInsert: "+ ",
+}
+
+// diffLine is one line of a diff with the config hierarchy it sits in
+type diffLine struct {
+	op   diffOp
+	text string
+	path string
+}
+
+// runDiff compares two config files and writes the differences in the
+// "show | compare" format, highlighted unless disabled.
+func runDiff(oldPath, newPath string, theme *highlighter.Theme, disabled bool, w io.Writer) error {
+	oldConfig, err := os.ReadFile(oldPath)
+	if err != nil {
+		return err
+	}
+	newConfig, err := os.ReadFile(newPath)
+	if err != nil {
+		return err
+	}
+
+	out := formatDiff(diffLines(splitLines(string(oldConfig)), splitLines(string(newConfig))))
+	if out == "" || disabled {
+		_, err = io.WriteString(w, out)
+		return err
+	}
+
+	hl := highlighter.NewWithTheme(theme)
+	hl.SetParseMode(lexer.ParseModeConfig)
+	_, err = io.WriteString(w, hl.Highlight(out))
+	return err
+}
+
+// splitLines splits text into lines without their line endings
+func splitLines(text string) []string {
+	text = strings.TrimSuffix(strings.ReplaceAll(text, "\r\n", "\n"), "\n")
+	if text == "" {
+		return nil
+	}
+	return strings.Split(text, "\n")
+}
+
+// diffLines returns the shortest edit script turning a into b (Myers'
+// algorithm), each line tagged with its "[edit ...]" hierarchy.
+func diffLines(a, b []string) []diffLine {
+	script := editScript(nil, a, b)
+
+	// Deleted lines sit in the old hierarchy, inserted lines in the new one
+	var oldPath, newPath []string
+	for i := range script {
+		switch script[i].op {
+		case diffEqual:
+			script[i].path = strings.Join(newPath, " ")
+			oldPath = enterBlock(oldPath, script[i].text)
+			newPath = enterBlock(newPath, script[i].text)
+		case diffDelete:
+			script[i].path = strings.Join(oldPath, " ")
+			oldPath = enterBlock(oldPath, script[i].text)
+		case diffInsert:
+			script[i].path = strings.Join(newPath, " ")
+			newPath = enterBlock(newPath, script[i].text)
+		}
+	}
+	return script
+}
+
+// editScript appends the shortest edit script turning a into b to script.
+// It uses the linear space variant of Myers' algorithm: the middle snake of
+// the edit graph splits the problem in two halves, which are diffed
+// recursively, so memory stays O(N+M) however different the inputs are.
+func editScript(script []diffLine, a, b []string) []diffLine {
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		script = append(script, diffLine{op: diffEqual, text: a[prefix]})
+		prefix++
+	}
+	a, b = a[prefix:], b[prefix:]
+
+	suffix := 0
+	for suffix < len(a) && suffix < len(b) && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+	common := a[len(a)-suffix:]
+	a, b = a[:len(a)-suffix], b[:len(b)-suffix]
+
+	x, y, ok := middleSnake(a, b)
+	if ok {
+		script = editScript(script, a[:x], b[:y])
+		script = editScript(script, a[x:], b[y:])
+	} else {
+		// One side is empty or the two have nothing in common
+		for _, line := range a {
+			script = append(script, diffLine{op: diffDelete, text: line})
+		}
+		for _, line := range b {
+			script = append(script, diffLine{op: diffInsert, text: line})
+		}
+	}
+
+	for _, line := range common {
+		script = append(script, diffLine{op: diffEqual, text: line})
+	}
+	return script
+}
+
+// middleSnake searches the edit graph of a and b from both ends at once and
+// returns the point (x, y) where the forward and reverse shortest paths
+// meet. The edit script up to that point and the one after it are halves of
+// a shortest script for the whole. ok is false when a or b is empty or no
+// lines match. a and b must not share a first or a last line.
+func middleSnake(a, b []string) (x, y int, ok bool) {
+	n, m := len(a), len(b)
+	if n == 0 || m == 0 {
+		return 0, 0, false
+	}
+
+	maxD := (n + m + 1) / 2
+	offset := maxD
+	// forward[k] and reverse[k] are the furthest x reached on diagonal k,
+	// counted from the start and from the end; -1 means not reached yet
+	forward := make([]int, 2*maxD+2)
+	reverse := make([]int, 2*maxD+2)
+	for i := range forward {
+		forward[i], reverse[i] = -1, -1
+	}
+	forward[offset+1], reverse[offset+1] = 0, 0
+
+	delta := n - m
+	// With an odd delta the paths meet on a forward step, otherwise on a
+	// reverse one
+	odd := delta%2 != 0
+	// Diagonals that ran off the graph are skipped on later steps
+	var fStart, fEnd, rStart, rEnd int
+
+	for d := 0; d < maxD; d++ {
+		for k := -d + fStart; k <= d-fEnd; k += 2 {
+			i := offset + k
+			var x int
+			if k == -d || (k != d && forward[i-1] < forward[i+1]) {
+				x = forward[i+1]
+			} else {
+				x = forward[i-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+			forward[i] = x
+			switch {
+			case x > n:
+				fEnd += 2
+			case y > m:
+				fStart += 2
+			case odd:
+				if j := offset + delta - k; j >= 0 && j < len(reverse) && reverse[j] != -1 && x >= n-reverse[j] {
+					return x, y, true
+				}
+			}
+		}
+
+		for k := -d + rStart; k <= d-rEnd; k += 2 {
+			i := offset + k
+			var x int
+			if k == -d || (k != d && reverse[i-1] < reverse[i+1]) {
+				x = reverse[i+1]
+			} else {
+				x = reverse[i-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && a[n-1-x] == b[m-1-y] {
+				x++
+				y++
+			}
+			reverse[i] = x
+			switch {
+			case x > n:
+				rEnd += 2
+			case y > m:
+				rStart += 2
+			case !odd:
+				if j := offset + delta - k; j >= 0 && j < len(forward) && forward[j] != -1 && forward[j] >= n-x {
+					fx := forward[j]
+					return fx, fx - (j - offset), true
+				}
+			}
+		}
+	}
+	return 0, 0, false
+}
+
+// enterBlock returns the hierarchy after a config line: "name {" opens a
+// block and "}" closes one.
+func enterBlock(path []string, line string) []string {
+	trimmed := strings.TrimSpace(line)
+	switch {
+	case strings.HasSuffix(trimmed, "{"):
+		return append(path, strings.TrimSpace(strings.TrimSuffix(trimmed, "{")))
+	case strings.HasPrefix(trimmed, "}") && len(path) > 0:
+		return path[:len(path)-1]
+	}
+	return path
+}
+
+// formatDiff renders the changes with diffContext lines of context as
+// hunks, each under an "[edit ...]" header for the hierarchy it starts in.
+// Returns "" when nothing changed.
+func formatDiff(script []diffLine) string {
+	shown := make([]bool, len(script))
+	for i, line := range script {
+		if line.op == diffEqual {
+			continue
+		}
+		for j := max(0, i-diffContext); j <= min(len(script)-1, i+diffContext); j++ {
+			shown[j] = true
+		}
+	}
+
+	var b strings.Builder
+	for i, line := range script {
+		if !shown[i] {
+			continue
+		}
+		if i == 0 || !shown[i-1] {
+			b.WriteString(editHeader(line.path) + "\n")
+		}
+		b.WriteString(diffPrefixes[line.op] + line.text + "\n")
+	}
+	return b.String()
+}
+
+// editHeader returns the "[edit ...]" context line for a hierarchy
+func editHeader(path string) string {
+	if path == "" {
+		return "[edit]"
+	}
+	return "[edit " + path + "]"
+}
@@ -0,0 +1,33 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/lasseh/jink/highlighter"
+	"github.com/lasseh/jink/lexer"
+)
+
+// explainTypeWidth is the column width for token type names in --explain output
+const explainTypeWidth = 20
+
+// runExplain tokenizes a single line and prints one token per line with its
+// type and value, the value colored as the highlighter would color it unless
+// disabled. Whitespace tokens are skipped.
+func runExplain(line string, theme *highlighter.Theme, disabled bool, w io.Writer) {
+	lex := lexer.New(line)
+	tokens := lex.Tokenize()
+
+	fmt.Fprintf(w, "Mode: %s\n", lex.GetParseMode())
+	for _, tok := range tokens {
+		if tok.Type == lexer.TokenText && strings.TrimSpace(tok.Value) == "" {
+			continue
+		}
+		value := tok.Value
+		if color := theme.GetColor(tok.Type); color != "" && !disabled {
+			value = color + value + highlighter.Reset
+		}
+		fmt.Fprintf(w, "%-*s %s\n", explainTypeWidth, tok.Type, value)
+	}
+}
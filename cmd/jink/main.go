@@ -2,13 +2,21 @@ package main
 
 import (
 	"bufio"
+	"context"
+	"encoding/json"
 	"flag"
 	"fmt"
 	"io"
 	"os"
+	"os/signal"
+	"regexp"
+	"strconv"
 	"strings"
+	"syscall"
+	"time"
 
 	"github.com/lasseh/jink/highlighter"
+	"github.com/lasseh/jink/lexer"
 	"github.com/lasseh/jink/terminal"
 )
 
@@ -21,12 +29,39 @@ USAGE:
     jink ssh user@router          # Interactive SSH with highlighting
     cat config.conf | jink        # Highlight a config file
     jink -t monokai ssh router    # Use a different theme
+    jink --pager ssh router show route  # Page highlighted output
+    jink --watch 5 ssh router show chassis environment  # Rerun every 5s
+    cat output.txt | jink show    # Force show output highlighting
+    cat config.conf | jink config # Force config highlighting
+    jink diff old.conf new.conf   # Compare two configs like "show | compare"
 
 OPTIONS:
     -f, --force           Always highlight (skip auto-detection)
     -t, --theme <name>    Color theme (see THEMES below)
     -n, --no-highlight    Disable highlighting (pass-through mode)
+    -p, --pager           Page command output through $PAGER (default: less -R)
+    -w, --watch <seconds> Rerun the command every interval and redraw its output
+    -l, --legend          Show what each color means in the selected theme
+    --explain <line>      Print each token of a line with its type
+    --match <regex>       Mark text matching regex (reverse video)
+    --trailing-whitespace Mark trailing spaces and tabs on piped input
+    --wrap                Wrap long comments and values on piped input at $COLUMNS
+    --width <columns>     Wrap long comments and values at this width (implies --wrap)
+    --indent-guides       Draw guides for each block level in piped hierarchical config
+    --annotate-lines      Prefix piped lines with their type ([CFG], [SHOW], ...)
+    --title               Set the terminal title to user@host from the session's prompt
+    --detect <level>      Detection strictness on piped input: loose, normal, strict
+                          (default: normal)
+    --comment-style <style>
+                          Comment emphasis: theme, dim, normal, bold (default: theme)
+    --profile <name>      Coloring profile: full, or minimal for only states,
+                          addresses, interfaces and durations (default: full)
+    --theme-preview <file>
+                          Preview a theme file and report unset palette fields
+    --pick-theme          Page through the themes and print the one selected
+    -q, --quiet           Print nothing when run with no command and no piped input
     -v, --version         Show version
+    --json                With --version, print {"name":"jink","version":"..."}
     -h, --help            Show this help
 
 THEMES:
@@ -40,18 +75,39 @@ THEMES:
     dracula     - Dracula color scheme
     gruvbox     - Gruvbox Dark color scheme
     onedark     - Atom One Dark color scheme
+    mono        - Bold, underline and reverse only, no colors
 
 `
 
 func main() {
 	// Custom flag handling to support both short and long forms
 	var (
-		themeName   string
-		noHighlight bool
-		forceHL     bool
-		showVersion bool
-		showHelp    bool
-		debug       bool
+		themeName    string
+		noHighlight  bool
+		forceHL      bool
+		showVersion  bool
+		showHelp     bool
+		debug        bool
+		themePreview string
+		usePager     bool
+		showLegend   bool
+		detectLevel  string
+		matchExpr    string
+		showTrailing bool
+		explainLine  string
+		cpuProfile   string
+		memProfile   string
+		annotate     bool
+		watchSeconds int
+		pickTheme    bool
+		commentStyle string
+		quiet        bool
+		wrap         bool
+		width        int
+		profile      string
+		setTitle     bool
+		guides       bool
+		jsonOutput   bool
 	)
 
 	flag.StringVar(&themeName, "theme", "default", "Color theme")
@@ -62,10 +118,36 @@ func main() {
 	flag.BoolVar(&forceHL, "f", false, "Force highlighting (shorthand)")
 	flag.BoolVar(&showVersion, "version", false, "Show version")
 	flag.BoolVar(&showVersion, "v", false, "Show version (shorthand)")
+	flag.BoolVar(&jsonOutput, "json", false, "Print --version output as JSON")
 	flag.BoolVar(&showHelp, "help", false, "Show help")
 	flag.BoolVar(&showHelp, "h", false, "Show help (shorthand)")
 	flag.BoolVar(&debug, "debug", false, "Enable debug output")
 	flag.BoolVar(&debug, "d", false, "Enable debug output (shorthand)")
+	flag.StringVar(&themePreview, "theme-preview", "", "Preview a theme file")
+	flag.BoolVar(&pickTheme, "pick-theme", false, "Pick a theme interactively")
+	flag.BoolVar(&quiet, "quiet", false, "No help when stdin is a terminal")
+	flag.BoolVar(&quiet, "q", false, "No help when stdin is a terminal (shorthand)")
+	flag.BoolVar(&usePager, "pager", false, "Page command output")
+	flag.BoolVar(&usePager, "p", false, "Page command output (shorthand)")
+	flag.IntVar(&watchSeconds, "watch", 0, "Rerun the command every N seconds")
+	flag.IntVar(&watchSeconds, "w", 0, "Rerun the command every N seconds (shorthand)")
+	flag.BoolVar(&showLegend, "legend", false, "Show color legend")
+	flag.BoolVar(&showLegend, "l", false, "Show color legend (shorthand)")
+	flag.StringVar(&detectLevel, "detect", "normal", "Detection strictness")
+	flag.StringVar(&commentStyle, "comment-style", "theme", "Comment emphasis")
+	flag.StringVar(&profile, "profile", "full", "Coloring profile")
+	flag.StringVar(&matchExpr, "match", "", "Mark text matching a regex")
+	flag.BoolVar(&showTrailing, "trailing-whitespace", false, "Mark trailing whitespace")
+	flag.BoolVar(&wrap, "wrap", false, "Wrap long comments and values")
+	flag.IntVar(&width, "width", 0, "Wrap width")
+	flag.StringVar(&explainLine, "explain", "", "Explain the tokens of a line")
+	flag.BoolVar(&annotate, "annotate-lines", false, "Prefix lines with their detected type")
+	flag.BoolVar(&setTitle, "title", false, "Set the terminal title from the prompt")
+	flag.BoolVar(&guides, "indent-guides", false, "Draw indent guides in hierarchical config")
+
+	// Profiling for contributors; not listed in the usage text
+	flag.StringVar(&cpuProfile, "cpuprofile", "", "Write a CPU profile of stdin highlighting")
+	flag.StringVar(&memProfile, "memprofile", "", "Write a memory profile of stdin highlighting")
 
 	flag.Usage = func() {
 		fmt.Fprint(os.Stderr, usage)
@@ -79,21 +161,172 @@ func main() {
 	}
 
 	if showVersion {
+		if jsonOutput {
+			printVersionJSON(os.Stdout)
+			os.Exit(0)
+		}
 		fmt.Printf("jink version %s\n", version)
 		os.Exit(0)
 	}
 
+	if themePreview != "" {
+		if err := runThemePreview(themePreview, os.Stdout); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
 	// Select theme
-	theme := highlighter.ThemeByName(strings.ToLower(themeName))
+	themeName = strings.ToLower(themeName)
+	theme, err := highlighter.ThemeByNameStrict(themeName)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v (available: default, %s)\n", err, strings.Join(highlighter.ThemeNames(), ", "))
+		os.Exit(1)
+	}
+
+	// The picker UI goes to stderr so "$(jink --pick-theme)" captures the name
+	if pickTheme {
+		name, err := runThemePicker(os.Stdin, os.Stderr, themeName)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		if name != "" {
+			fmt.Println(name)
+		}
+		return
+	}
+
+	// Convert RGB themes for terminals without true color support
+	theme = theme.Degrade(highlighter.DetectColorDepth())
+
+	style, err := parseCommentStyle(commentStyle)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	theme = theme.WithCommentStyle(style)
+
+	theme, err = applyProfile(theme, profile)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	if showLegend {
+		fmt.Print(highlighter.NewWithTheme(theme).HighlightLegend())
+		return
+	}
+
+	if explainLine != "" {
+		runExplain(explainLine, theme, noHighlight, os.Stdout)
+		return
+	}
+
+	var match *regexp.Regexp
+	if matchExpr != "" {
+		re, err := regexp.Compile(matchExpr)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: invalid --match pattern: %v\n", err)
+			os.Exit(1)
+		}
+		match = re
+	}
 
 	args := flag.Args()
 
+	// Command output is always highlighted, so detection only applies to
+	// piped input
+	if len(args) > 0 && flagSet("detect") {
+		fmt.Fprintln(os.Stderr, "Error: --detect only applies to piped input, not to a command")
+		os.Exit(1)
+	}
+
 	// Enable debug mode
 	terminal.SetDebug(debug)
 
+	if usePager {
+		if err := runWithPager(args, theme, match, noHighlight); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	if watchSeconds != 0 {
+		interval := time.Duration(watchSeconds) * time.Second
+		if err := runWithWatch(args, theme, match, noHighlight, interval); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	// "jink diff old.conf new.conf" compares two saved configs
+	if len(args) > 0 && args[0] == "diff" {
+		if len(args) != 3 {
+			fmt.Fprintln(os.Stderr, "Error: usage: jink diff <old-config> <new-config>")
+			os.Exit(1)
+		}
+		if err := runDiff(args[1], args[2], theme, noHighlight, os.Stdout); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	// "jink show" / "jink config" highlight stdin in a fixed parse mode
+	if mode, ok := stdinAliasMode(args); ok {
+		hl := highlighter.NewWithTheme(theme)
+		hl.SetParseMode(mode)
+		hl.SetHighlightPattern(match, "")
+		hl.SetShowTrailingWhitespace(showTrailing)
+		hl.SetWrapWidth(wrapWidth(wrap, width, os.Getenv))
+		hl.SetIndentGuides(guides)
+
+		opts := stdinOptions{
+			disabled:   noHighlight,
+			force:      true,
+			annotate:   annotate,
+			quiet:      quiet,
+			debug:      debug,
+			theme:      theme,
+			cpuProfile: cpuProfile,
+			memProfile: memProfile,
+		}
+		if err := highlightStdinProfiled(hl, opts); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
 	// If no command provided, read from stdin and highlight
 	if len(args) == 0 {
-		if err := highlightStdin(theme, noHighlight, forceHL); err != nil {
+		threshold, err := parseDetectionThreshold(detectLevel)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		hl := highlighter.NewWithTheme(theme)
+		hl.SetDetectionThreshold(threshold)
+		hl.SetHighlightPattern(match, "")
+		hl.SetShowTrailingWhitespace(showTrailing)
+		hl.SetWrapWidth(wrapWidth(wrap, width, os.Getenv))
+		hl.SetIndentGuides(guides)
+
+		opts := stdinOptions{
+			disabled:   noHighlight,
+			force:      forceHL,
+			annotate:   annotate,
+			quiet:      quiet,
+			debug:      debug,
+			theme:      theme,
+			cpuProfile: cpuProfile,
+			memProfile: memProfile,
+		}
+		if err := highlightStdinProfiled(hl, opts); err != nil {
 			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
 			os.Exit(1)
 		}
@@ -101,43 +334,96 @@ func main() {
 	}
 
 	// Run command with PTY terminal
-	if err := runWithTerminal(args, theme, noHighlight); err != nil {
+	if err := runWithTerminal(args, theme, match, noHighlight, setTitle); err != nil {
 		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
 		os.Exit(1)
 	}
 }
 
-func highlightStdin(theme *highlighter.Theme, disabled bool, force bool) error {
+// flagSet reports whether the named flag was given on the command line
+func flagSet(name string) bool {
+	set := false
+	flag.Visit(func(f *flag.Flag) {
+		if f.Name == name {
+			set = true
+		}
+	})
+	return set
+}
+
+// versionInfo is the --version --json output
+type versionInfo struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+}
+
+// printVersionJSON writes the version as a single line of JSON for tooling
+func printVersionJSON(w io.Writer) {
+	// Encoding a struct of strings can't fail
+	_ = json.NewEncoder(w).Encode(versionInfo{Name: "jink", Version: version})
+}
+
+// stdinOptions controls how piped input is highlighted
+type stdinOptions struct {
+	disabled   bool               // pass input through unhighlighted
+	force      bool               // highlight without JunOS detection
+	annotate   bool               // prefix each line with its detected type
+	quiet      bool               // print nothing instead of help when stdin is a terminal
+	debug      bool               // report how highlighting was decided on stderr
+	theme      *highlighter.Theme // colors for the annotation tags
+	cpuProfile string             // write a CPU profile here (hidden --cpuprofile)
+	memProfile string             // write a heap profile here (hidden --memprofile)
+}
+
+func highlightStdin(hl *highlighter.Highlighter, opts stdinOptions) error {
 	// Check if stdin is a terminal (no pipe)
 	stat, _ := os.Stdin.Stat()
 	if (stat.Mode() & os.ModeCharDevice) != 0 {
 		// Interactive mode - show help
-		fmt.Print(usage)
+		if !opts.quiet {
+			fmt.Print(usage)
+		}
 		return nil
 	}
 
-	hl := highlighter.NewWithTheme(theme)
 	reader := bufio.NewReader(os.Stdin)
+	// The lines are one input, so block depth carries across them
+	stream := hl.NewStream()
+
+	// Legacy Windows consoles get colors through console API calls
+	out := highlighter.NewConsoleWriter(os.Stdout)
 
 	// Track if we've detected JunOS content (sticky detection)
+	disabled, force := opts.disabled, opts.force
 	detectedJunOS := force
 
+	lineNum := 0
 	for {
 		line, err := reader.ReadString('\n')
 		if len(line) > 0 {
+			lineNum++
+			if opts.debug && force && lineNum == 1 {
+				debugDetection(os.Stderr, "highlighting forced", line)
+			}
+			if opts.annotate {
+				fmt.Fprint(out, lineTag(line, opts.theme, disabled))
+			}
 			if disabled {
-				fmt.Print(line)
+				fmt.Fprint(out, line)
 			} else if detectedJunOS || force {
 				// Force mode or already detected - highlight everything
-				fmt.Print(hl.HighlightForced(line))
+				fmt.Fprint(out, stream.HighlightForced(line))
 			} else {
 				// Auto-detect mode - check if this looks like JunOS
-				highlighted := hl.Highlight(line)
+				highlighted := stream.Highlight(line)
 				if highlighted != line {
 					// We got highlighting, so it's JunOS - enable for all future lines
 					detectedJunOS = true
+					if opts.debug {
+						debugDetection(os.Stderr, fmt.Sprintf("JunOS detected at line %d (threshold %s)", lineNum, hl.DetectionThreshold()), line)
+					}
 				}
-				fmt.Print(highlighted)
+				fmt.Fprint(out, highlighted)
 			}
 		}
 		if err != nil {
@@ -148,17 +434,135 @@ func highlightStdin(theme *highlighter.Theme, disabled bool, force bool) error {
 		}
 	}
 
+	if opts.debug && !detectedJunOS && !disabled {
+		fmt.Fprintf(os.Stderr, "[DEBUG] Detection: no JunOS detected in %d lines (threshold %s)\n", lineNum, hl.DetectionThreshold())
+	}
 	return nil
 }
 
-func runWithTerminal(args []string, theme *highlighter.Theme, disabled bool) error {
+// debugDetection reports on w why piped input is highlighted, and the parse
+// mode the lexer detects for line, the line that decided it
+func debugDetection(w io.Writer, reason, line string) {
+	report := lexer.New(highlighter.StripANSI(line)).DetectionReport()
+	fmt.Fprintf(w, "[DEBUG] Detection: %s; parse mode %s\n", reason, report)
+}
+
+// stdinAliasMode reports whether args is one of the stdin aliases
+// ("show" or "config") and returns the parse mode it forces.
+func stdinAliasMode(args []string) (lexer.ParseMode, bool) {
+	if len(args) != 1 {
+		return lexer.ParseModeAuto, false
+	}
+	switch args[0] {
+	case "show":
+		return lexer.ParseModeShow, true
+	case "config":
+		return lexer.ParseModeConfig, true
+	default:
+		return lexer.ParseModeAuto, false
+	}
+}
+
+// parseDetectionThreshold converts a --detect value to a detection threshold
+func parseDetectionThreshold(level string) (highlighter.DetectionThreshold, error) {
+	switch strings.ToLower(level) {
+	case "loose":
+		return highlighter.DetectionLoose, nil
+	case "normal", "":
+		return highlighter.DetectionNormal, nil
+	case "strict":
+		return highlighter.DetectionStrict, nil
+	default:
+		return highlighter.DetectionNormal, fmt.Errorf("unknown detection level %q (want loose, normal or strict)", level)
+	}
+}
+
+// defaultWrapWidth is the --wrap width when $COLUMNS isn't set
+const defaultWrapWidth = 80
+
+// wrapWidth returns the width to wrap piped output at, or 0 for no wrapping.
+// --width sets it; --wrap alone uses $COLUMNS.
+func wrapWidth(wrap bool, width int, getenv func(string) string) int {
+	if width > 0 {
+		return width
+	}
+	if !wrap {
+		return 0
+	}
+	if columns, err := strconv.Atoi(getenv("COLUMNS")); err == nil && columns > 0 {
+		return columns
+	}
+	return defaultWrapWidth
+}
+
+// applyProfile applies a --profile value to theme
+func applyProfile(theme *highlighter.Theme, name string) (*highlighter.Theme, error) {
+	switch strings.ToLower(name) {
+	case "full", "":
+		return theme, nil
+	case "minimal":
+		return highlighter.MinimalProfile(theme), nil
+	default:
+		return nil, fmt.Errorf("unknown profile %q (want full or minimal)", name)
+	}
+}
+
+// parseCommentStyle converts a --comment-style value to a comment style
+func parseCommentStyle(name string) (highlighter.CommentStyle, error) {
+	switch strings.ToLower(name) {
+	case "theme", "":
+		return highlighter.CommentStyleTheme, nil
+	case "dim":
+		return highlighter.CommentStyleDim, nil
+	case "normal":
+		return highlighter.CommentStyleNormal, nil
+	case "bold":
+		return highlighter.CommentStyleBold, nil
+	default:
+		return highlighter.CommentStyleTheme, fmt.Errorf("unknown comment style %q (want theme, dim, normal or bold)", name)
+	}
+}
+
+func runWithTerminal(args []string, theme *highlighter.Theme, match *regexp.Regexp, disabled, setTitle bool) error {
 	if len(args) == 0 {
 		return fmt.Errorf("no command specified")
 	}
 
 	t := terminal.New(args[0], args[1:]...)
 	t.SetTheme(theme)
+	t.SetHighlightPattern(match, "")
 	t.SetEnabled(!disabled)
+	t.SetTitleUpdates(setTitle)
 
 	return t.Run()
 }
+
+func runWithPager(args []string, theme *highlighter.Theme, match *regexp.Regexp, disabled bool) error {
+	if len(args) == 0 {
+		return fmt.Errorf("--pager requires a command")
+	}
+
+	t := terminal.New(args[0], args[1:]...)
+	t.SetTheme(theme)
+	t.SetHighlightPattern(match, "")
+	t.SetEnabled(!disabled)
+
+	return t.RunPaged(terminal.PagerCommand(os.Getenv("PAGER")))
+}
+
+func runWithWatch(args []string, theme *highlighter.Theme, match *regexp.Regexp, disabled bool, interval time.Duration) error {
+	if len(args) == 0 {
+		return fmt.Errorf("--watch requires a command")
+	}
+
+	t := terminal.New(args[0], args[1:]...)
+	t.SetTheme(theme)
+	t.SetHighlightPattern(match, "")
+	t.SetEnabled(!disabled)
+
+	// Ctrl-C stops watching; the command runs in its own PTY session
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	return t.Watch(ctx, interval)
+}
@@ -2,10 +2,16 @@ package main
 
 import (
 	"bytes"
+	"encoding/json"
 	"os"
 	"os/exec"
+	"path/filepath"
 	"strings"
 	"testing"
+
+	"github.com/creack/pty"
+	"github.com/lasseh/jink/highlighter"
+	"github.com/lasseh/jink/lexer"
 )
 
 // TestCLIHelp tests that --help returns usage information
@@ -116,6 +122,27 @@ func TestCLIThemeOption(t *testing.T) {
 	}
 }
 
+// TestCLIUnknownTheme tests that a mistyped theme is rejected, not replaced
+func TestCLIUnknownTheme(t *testing.T) {
+	cmd := exec.Command("go", "run", ".", "--theme", "monokia")
+	cmd.Stdin = strings.NewReader("set system host-name router")
+
+	output, err := cmd.CombinedOutput()
+	if err == nil {
+		t.Fatalf("expected an error for an unknown theme, got %q", output)
+	}
+
+	outStr := string(output)
+	for _, want := range []string{`unknown theme "monokia"`, "default", "monokai", "tokyonight"} {
+		if !strings.Contains(outStr, want) {
+			t.Errorf("expected %q in error output, got %q", want, outStr)
+		}
+	}
+	if strings.Contains(outStr, "host-name") {
+		t.Errorf("input should not be highlighted with an unknown theme, got %q", outStr)
+	}
+}
+
 // TestCLIShortFlags tests short flag versions
 func TestCLIShortFlags(t *testing.T) {
 	// Test -h (help)
@@ -242,3 +269,687 @@ func TestCLIBinaryBuilds(t *testing.T) {
 		t.Error("binary should output version")
 	}
 }
+
+// TestCLIThemePreview tests --theme-preview with a partial theme file
+func TestCLIThemePreview(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "partial.theme")
+	content := "command = #ff0000\nsection = 33\n"
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatalf("failed to write theme file: %v", err)
+	}
+
+	cmd := exec.Command("go", "run", ".", "--theme-preview", path)
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("theme preview failed: %v\nOutput: %s", err, output)
+	}
+
+	outStr := string(output)
+	if !strings.Contains(outStr, "\033[38;2;255;0;0m") {
+		t.Error("preview should use the command color from the theme file")
+	}
+	if !strings.Contains(outStr, "Missing (using default theme colors):") {
+		t.Error("preview should contain a coverage report")
+	}
+	for _, field := range []string{"Interface", "StateGood", "PromptUser"} {
+		if !strings.Contains(outStr, "    "+field+"\n") {
+			t.Errorf("coverage report should list missing field %s", field)
+		}
+	}
+	if strings.Contains(outStr, "    Command\n") {
+		t.Error("coverage report should not list fields set in the file")
+	}
+}
+
+// TestCLILegend tests that --legend prints colored token categories
+func TestCLILegend(t *testing.T) {
+	cmd := exec.Command("go", "run", ".", "--legend", "-t", "nord")
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("legend failed: %v\nOutput: %s", err, output)
+	}
+
+	outStr := string(output)
+	if !strings.Contains(outStr, "\033[") {
+		t.Error("legend should be colored")
+	}
+	for _, name := range []string{"Command", "Section", "Interface", "State good"} {
+		if !strings.Contains(outStr, name) {
+			t.Errorf("legend should contain %q", name)
+		}
+	}
+}
+
+// TestCLIDetectLevels tests --detect on borderline input
+func TestCLIDetectLevels(t *testing.T) {
+	input := "ge-0/0/0 is the uplink\n"
+
+	tests := []struct {
+		level       string
+		highlighted bool
+	}{
+		{"loose", true},
+		{"normal", true},
+		{"strict", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.level, func(t *testing.T) {
+			cmd := exec.Command("go", "run", ".", "--detect", tt.level)
+			cmd.Stdin = strings.NewReader(input)
+
+			output, err := cmd.CombinedOutput()
+			if err != nil {
+				t.Fatalf("--detect %s failed: %v\nOutput: %s", tt.level, err, output)
+			}
+			if strings.Contains(string(output), "\033[") != tt.highlighted {
+				t.Errorf("--detect %s: highlighted=%v, want %v", tt.level, !tt.highlighted, tt.highlighted)
+			}
+		})
+	}
+
+	cmd := exec.Command("go", "run", ".", "--detect", "bogus")
+	cmd.Stdin = strings.NewReader(input)
+	if err := cmd.Run(); err == nil {
+		t.Error("--detect with an unknown level should fail")
+	}
+}
+
+// TestCLIDetectWithCommand tests that --detect is rejected with a command,
+// whose output is always highlighted, instead of being ignored
+func TestCLIDetectWithCommand(t *testing.T) {
+	cmd := exec.Command("go", "run", ".", "--detect", "strict", "echo", "ge-0/0/0 is the uplink")
+
+	output, err := cmd.CombinedOutput()
+	if err == nil {
+		t.Fatalf("expected --detect with a command to fail, got %q", output)
+	}
+	if !strings.Contains(string(output), "--detect only applies to piped input") {
+		t.Errorf("expected a --detect error, got %q", output)
+	}
+}
+
+// TestCLIParseModeAliases tests "jink show" and "jink config" over input that
+// could be either config or show output
+func TestCLIParseModeAliases(t *testing.T) {
+	input := "Peer State static\n"
+
+	tests := []struct {
+		alias string
+		mode  lexer.ParseMode
+	}{
+		{"show", lexer.ParseModeShow},
+		{"config", lexer.ParseModeConfig},
+	}
+
+	outputs := map[string]string{}
+	for _, tt := range tests {
+		t.Run(tt.alias, func(t *testing.T) {
+			cmd := exec.Command("go", "run", ".", tt.alias)
+			cmd.Stdin = strings.NewReader(input)
+			cmd.Env = append(os.Environ(), "COLORTERM=truecolor")
+
+			output, err := cmd.CombinedOutput()
+			if err != nil {
+				t.Fatalf("jink %s failed: %v\nOutput: %s", tt.alias, err, output)
+			}
+
+			hl := highlighter.New()
+			hl.SetParseMode(tt.mode)
+			if want := hl.HighlightForced(input); string(output) != want {
+				t.Errorf("jink %s: got %q, want %q", tt.alias, output, want)
+			}
+			outputs[tt.alias] = string(output)
+		})
+	}
+
+	if outputs["show"] == outputs["config"] {
+		t.Error("show and config aliases should highlight differently")
+	}
+}
+
+// TestCLIMatch tests --match marks matching text and rejects invalid patterns
+func TestCLIMatch(t *testing.T) {
+	input := "set protocols bgp group ebgp-peers neighbor 10.0.0.1\n"
+
+	cmd := exec.Command("go", "run", ".", "--match", "bgp")
+	cmd.Stdin = strings.NewReader(input)
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("--match failed: %v\nOutput: %s", err, output)
+	}
+	if n := strings.Count(string(output), highlighter.Reverse); n != 2 {
+		t.Errorf("expected 2 marked matches, got %d in %q", n, output)
+	}
+
+	cmd = exec.Command("go", "run", ".", "--match", "(")
+	cmd.Stdin = strings.NewReader(input)
+	if err := cmd.Run(); err == nil {
+		t.Error("--match with an invalid pattern should fail")
+	}
+}
+
+// TestCLICommentStyle tests --comment-style sets the attribute of comments
+func TestCLICommentStyle(t *testing.T) {
+	input := "set system host-name r1\n# uplinks\n"
+	comment := highlighter.DefaultTheme().Palette().Comment
+
+	tests := []struct {
+		style string
+		want  string
+	}{
+		{"theme", highlighter.Italic + comment},
+		{"dim", highlighter.Dim + comment},
+		{"normal", comment},
+		{"bold", highlighter.Bold + comment},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.style, func(t *testing.T) {
+			cmd := exec.Command("go", "run", ".", "--comment-style", tt.style)
+			cmd.Env = append(os.Environ(), "COLORTERM=truecolor")
+			cmd.Stdin = strings.NewReader(input)
+			output, err := cmd.CombinedOutput()
+			if err != nil {
+				t.Fatalf("--comment-style %s failed: %v\nOutput: %s", tt.style, err, output)
+			}
+			if !strings.Contains(string(output), tt.want+"# uplinks") {
+				t.Errorf("--comment-style %s: comment not rendered with %q: %q", tt.style, tt.want, output)
+			}
+		})
+	}
+
+	cmd := exec.Command("go", "run", ".", "--comment-style", "bogus")
+	cmd.Stdin = strings.NewReader(input)
+	if err := cmd.Run(); err == nil {
+		t.Error("--comment-style with an unknown style should fail")
+	}
+}
+
+// TestCLIWrap tests --width and --wrap soft-wrap long comments
+func TestCLIWrap(t *testing.T) {
+	input := "set system host-name r1\n# uplink to the provider edge in the north data center\n"
+
+	for _, args := range [][]string{{"--width", "30"}, {"--wrap"}} {
+		cmd := exec.Command("go", append([]string{"run", "."}, args...)...)
+		cmd.Env = append(os.Environ(), "COLUMNS=30")
+		cmd.Stdin = strings.NewReader(input)
+		output, err := cmd.CombinedOutput()
+		if err != nil {
+			t.Fatalf("%v failed: %v\nOutput: %s", args, err, output)
+		}
+		lines := strings.Split(strings.TrimSuffix(highlighter.StripANSI(string(output)), "\n"), "\n")
+		if len(lines) < 3 {
+			t.Errorf("%v: expected the comment to wrap, got %q", args, output)
+		}
+		for _, line := range lines {
+			if len(line) > 30 {
+				t.Errorf("%v: line longer than 30 columns: %q", args, line)
+			}
+		}
+	}
+}
+
+func TestWrapWidth(t *testing.T) {
+	env := func(columns string) func(string) string {
+		return func(string) string { return columns }
+	}
+	tests := []struct {
+		wrap    bool
+		width   int
+		columns string
+		want    int
+	}{
+		{false, 0, "100", 0},
+		{true, 0, "100", 100},
+		{true, 0, "", defaultWrapWidth},
+		{true, 0, "wide", defaultWrapWidth},
+		{false, 60, "100", 60},
+		{true, 60, "100", 60},
+	}
+
+	for _, tt := range tests {
+		if got := wrapWidth(tt.wrap, tt.width, env(tt.columns)); got != tt.want {
+			t.Errorf("wrapWidth(%v, %d, COLUMNS=%q) = %d, want %d", tt.wrap, tt.width, tt.columns, got, tt.want)
+		}
+	}
+}
+
+// TestCLIColorProfile tests --profile minimal keeps states but not commands
+func TestCLIColorProfile(t *testing.T) {
+	input := "set interfaces ge-0/0/0 disable\nge-0/0/0  up    down\n"
+	theme := highlighter.DefaultTheme()
+
+	cmd := exec.Command("go", "run", ".", "--force", "--profile", "minimal")
+	cmd.Env = append(os.Environ(), "COLORTERM=truecolor")
+	cmd.Stdin = strings.NewReader(input)
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("--profile minimal failed: %v\nOutput: %s", err, output)
+	}
+	if strings.HasPrefix(string(output), "\033[") {
+		t.Errorf("expected the command to be uncolored, got %q", output)
+	}
+	if !strings.Contains(string(output), theme.GetColor(lexer.TokenInterface)+"ge-0/0/0") {
+		t.Errorf("expected interfaces to stay colored, got %q", output)
+	}
+
+	cmd = exec.Command("go", "run", ".", "--profile", "bogus")
+	cmd.Stdin = strings.NewReader(input)
+	if err := cmd.Run(); err == nil {
+		t.Error("--profile with an unknown name should fail")
+	}
+}
+
+// TestCLITrailingWhitespace tests --trailing-whitespace marks trailing spaces
+func TestCLITrailingWhitespace(t *testing.T) {
+	input := "set system host-name r1   \n"
+
+	run := func(args ...string) string {
+		cmd := exec.Command("go", append([]string{"run", "."}, args...)...)
+		cmd.Stdin = strings.NewReader(input)
+		output, err := cmd.CombinedOutput()
+		if err != nil {
+			t.Fatalf("jink %v failed: %v\nOutput: %s", args, err, output)
+		}
+		return string(output)
+	}
+
+	if out := run(); strings.Contains(out, "\033[48;") {
+		t.Errorf("trailing whitespace should not be marked by default, got %q", out)
+	}
+	if out := run("--trailing-whitespace"); !strings.Contains(out, "\033[48;") {
+		t.Errorf("expected trailing whitespace to be marked, got %q", out)
+	}
+}
+
+// TestCLIExplain tests --explain lists the tokens of a set command in order
+func TestCLIExplain(t *testing.T) {
+	cmd := exec.Command("go", "run", ".", "--explain", "set interfaces ge-0/0/0 unit 0 family inet address 10.0.0.1/24")
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("--explain failed: %v\nOutput: %s", err, output)
+	}
+
+	outStr := string(output)
+	if !strings.Contains(outStr, "\033[") {
+		t.Error("--explain should color token values")
+	}
+
+	lines := strings.Split(strings.TrimSpace(highlighter.StripANSI(outStr)), "\n")
+	want := [][2]string{
+		{"Mode:", "config"},
+		{"Command", "set"},
+		{"Section", "interfaces"},
+		{"Interface", "ge-0/0/0"},
+		{"Keyword", "unit"},
+		{"Unit", "0"},
+		{"Keyword", "family"},
+		{"Protocol", "inet"},
+		{"Keyword", "address"},
+		{"IPv4Prefix", "10.0.0.1/24"},
+	}
+	if len(lines) != len(want) {
+		t.Fatalf("expected %d lines, got %d:\n%s", len(want), len(lines), outStr)
+	}
+	for i, w := range want {
+		fields := strings.Fields(lines[i])
+		if len(fields) != 2 || fields[0] != w[0] || fields[1] != w[1] {
+			t.Errorf("line %d: got %q, want %s %s", i+1, lines[i], w[0], w[1])
+		}
+	}
+}
+
+// TestCLIProfile tests the hidden profiling flags write non-empty profiles
+// and stay out of the help text
+func TestCLIProfile(t *testing.T) {
+	dir := t.TempDir()
+	cpuPath := filepath.Join(dir, "cpu.prof")
+	memPath := filepath.Join(dir, "mem.prof")
+
+	cmd := exec.Command("go", "run", ".", "--cpuprofile", cpuPath, "--memprofile", memPath)
+	cmd.Stdin = strings.NewReader(strings.Repeat("set interfaces ge-0/0/0 unit 0 family inet address 10.0.0.1/24\n", 1000))
+	if output, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("profiling failed: %v\nOutput: %s", err, output)
+	}
+
+	for _, path := range []string{cpuPath, memPath} {
+		info, err := os.Stat(path)
+		if err != nil {
+			t.Errorf("expected profile %s: %v", filepath.Base(path), err)
+		} else if info.Size() == 0 {
+			t.Errorf("expected profile %s to be non-empty", filepath.Base(path))
+		}
+	}
+
+	if strings.Contains(usage, "cpuprofile") || strings.Contains(usage, "memprofile") {
+		t.Error("profiling flags should not be in the usage text")
+	}
+}
+
+// TestCLIAnnotateLines tests --annotate-lines tags each line with its type
+func TestCLIAnnotateLines(t *testing.T) {
+	input := "admin@router> show configuration\nset system host-name r1\n"
+
+	cmd := exec.Command("go", "run", ".", "--annotate-lines")
+	cmd.Stdin = strings.NewReader(input)
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("--annotate-lines failed: %v\nOutput: %s", err, output)
+	}
+
+	lines := strings.Split(highlighter.StripANSI(string(output)), "\n")
+	if !strings.HasPrefix(lines[0], "[PROMPT] admin@router>") {
+		t.Errorf("expected the prompt line tagged [PROMPT], got %q", lines[0])
+	}
+	if !strings.HasPrefix(lines[1], "[CFG]    set system") {
+		t.Errorf("expected the set line tagged [CFG], got %q", lines[1])
+	}
+}
+
+// TestCLIWatchRequiresCommand tests --watch fails without a command to run
+func TestCLIWatchRequiresCommand(t *testing.T) {
+	cmd := exec.Command("go", "run", ".", "--watch", "1")
+	cmd.Stdin = strings.NewReader("")
+	output, err := cmd.CombinedOutput()
+	if err == nil {
+		t.Fatal("--watch without a command should fail")
+	}
+	if !strings.Contains(string(output), "--watch requires a command") {
+		t.Errorf("expected a missing command error, got %q", output)
+	}
+}
+
+// TestCLIDebugDetection tests --debug reports once on stderr how piped input
+// was detected
+func TestCLIDebugDetection(t *testing.T) {
+	run := func(input string, args ...string) string {
+		t.Helper()
+		cmd := exec.Command("go", append([]string{"run", ".", "--debug"}, args...)...)
+		cmd.Stdin = strings.NewReader(input)
+		var stderr bytes.Buffer
+		cmd.Stderr = &stderr
+		if err := cmd.Run(); err != nil {
+			t.Fatalf("--debug failed: %v\nStderr: %s", err, stderr.String())
+		}
+		return stderr.String()
+	}
+
+	stderr := run("hello\nset system host-name r1;\nset system ntp server 10.0.0.1;\n", "--detect", "strict")
+	want := "[DEBUG] Detection: JunOS detected at line 2 (threshold strict); parse mode config (config 3 [set, ;, host-name]"
+	if !strings.Contains(stderr, want) {
+		t.Errorf("expected %q, got %q", want, stderr)
+	}
+	if n := strings.Count(stderr, "[DEBUG] Detection:"); n != 1 {
+		t.Errorf("expected one detection report, got %d: %q", n, stderr)
+	}
+
+	if stderr := run("hello\nworld\n"); !strings.Contains(stderr, "[DEBUG] Detection: no JunOS detected in 2 lines (threshold normal)") {
+		t.Errorf("expected a report that nothing was detected, got %q", stderr)
+	}
+	if stderr := run("hello\nworld\n", "--force"); !strings.Contains(stderr, "[DEBUG] Detection: highlighting forced; parse mode") || strings.Count(stderr, "[DEBUG] Detection:") != 1 {
+		t.Errorf("expected one report for forced highlighting, got %q", stderr)
+	}
+}
+
+func TestParsePickerKey(t *testing.T) {
+	tests := []struct {
+		input string
+		key   pickerKey
+		size  int
+	}{
+		{"\033[C", keyNext, 3},
+		{"\033[B", keyNext, 3},
+		{"\033OC", keyNext, 3},
+		{"\033[D", keyPrev, 3},
+		{"\033[A", keyPrev, 3},
+		{"l", keyNext, 1},
+		{"k", keyPrev, 1},
+		{"\r", keySelect, 1},
+		{"q", keyQuit, 1},
+		{"\003", keyQuit, 1},
+		{"\033", keyQuit, 1},
+		{"\033[5~", keyNone, 3},
+		{"x", keyNone, 1},
+	}
+
+	for _, tt := range tests {
+		key, size := parsePickerKey([]byte(tt.input))
+		if key != tt.key || size != tt.size {
+			t.Errorf("parsePickerKey(%q) = %v, %d, want %v, %d", tt.input, key, size, tt.key, tt.size)
+		}
+	}
+}
+
+func TestThemePicker(t *testing.T) {
+	p := newThemePicker("nord")
+	if p.names[p.index] != "nord" {
+		t.Fatalf("expected to start at nord, got %s", p.names[p.index])
+	}
+
+	// Wraps around in both directions
+	p = newThemePicker("no-such-theme")
+	if p.handle(keyPrev) || p.index != len(p.names)-1 {
+		t.Errorf("expected prev from the first theme to wrap to the last, got %d", p.index)
+	}
+	if p.handle(keyNext) || p.index != 0 {
+		t.Errorf("expected next from the last theme to wrap to the first, got %d", p.index)
+	}
+	if p.handle(keyNone) || p.index != 0 {
+		t.Error("expected unknown keys to be ignored")
+	}
+
+	p.handle(keyNext)
+	if !p.handle(keySelect) || p.chosen != p.names[1] {
+		t.Errorf("expected enter to select %s, got %q", p.names[1], p.chosen)
+	}
+
+	p = newThemePicker("")
+	if !p.handle(keyQuit) || p.chosen != "" {
+		t.Errorf("expected quit to select nothing, got %q", p.chosen)
+	}
+
+	var out bytes.Buffer
+	p.render(&out)
+	if !strings.Contains(out.String(), "Theme 1/") || strings.Contains(strings.ReplaceAll(out.String(), "\r\n", ""), "\n") {
+		t.Errorf("expected a raw-mode preview, got %q", out.String())
+	}
+}
+
+func TestCLIPickThemeRequiresTerminal(t *testing.T) {
+	cmd := exec.Command("go", "run", ".", "--pick-theme")
+	cmd.Stdin = strings.NewReader("")
+	output, err := cmd.CombinedOutput()
+	if err == nil {
+		t.Fatal("--pick-theme without a terminal should fail")
+	}
+	if !strings.Contains(string(output), "--pick-theme needs an interactive terminal") {
+		t.Errorf("expected a terminal error, got %q", output)
+	}
+}
+
+// TestCLIQuiet tests --quiet prints nothing when stdin is a terminal, where
+// jink normally prints its usage
+func TestCLIQuiet(t *testing.T) {
+	ptmx, tty, err := pty.Open()
+	if err != nil {
+		t.Skipf("no pty available: %v", err)
+	}
+	defer func() { _ = ptmx.Close() }()
+	defer func() { _ = tty.Close() }()
+
+	for _, args := range [][]string{{"--quiet"}, {"-q"}, {"-q", "show"}} {
+		cmd := exec.Command("go", append([]string{"run", "."}, args...)...)
+		cmd.Stdin = tty
+		output, err := cmd.Output()
+		if err != nil {
+			t.Fatalf("%v failed: %v", args, err)
+		}
+		if len(output) != 0 {
+			t.Errorf("%v: expected no output, got %q", args, output)
+		}
+	}
+
+	cmd := exec.Command("go", "run", ".")
+	cmd.Stdin = tty
+	output, err := cmd.Output()
+	if err != nil {
+		t.Fatalf("jink without --quiet failed: %v", err)
+	}
+	if !strings.Contains(string(output), "USAGE") {
+		t.Errorf("expected usage without --quiet, got %q", output)
+	}
+}
+
+// TestCLIDiff tests "jink diff" colors added and removed config lines
+func TestCLIDiff(t *testing.T) {
+	dir := t.TempDir()
+	oldPath := filepath.Join(dir, "old.conf")
+	newPath := filepath.Join(dir, "new.conf")
+	oldConfig := "system {\n    host-name r1;\n    services {\n        ssh;\n    }\n}\n"
+	newConfig := "system {\n    host-name r2;\n    services {\n        ssh;\n        netconf;\n    }\n}\n"
+	if err := os.WriteFile(oldPath, []byte(oldConfig), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(newPath, []byte(newConfig), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	cmd := exec.Command("go", "run", ".", "diff", oldPath, newPath)
+	cmd.Env = append(os.Environ(), "COLORTERM=truecolor")
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("jink diff failed: %v\nOutput: %s", err, output)
+	}
+	out := string(output)
+	theme := highlighter.DefaultTheme()
+	for _, want := range []string{
+		theme.GetColor(lexer.TokenDiffContext) + "[edit]",
+		theme.GetColor(lexer.TokenDiffRemove) + "-",
+		theme.GetColor(lexer.TokenDiffAdd) + "+",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("expected %q in output, got %q", want, out)
+		}
+	}
+
+	stripped := highlighter.StripANSI(out)
+	for _, want := range []string{"-     host-name r1;", "+     host-name r2;", "+         netconf;"} {
+		if !strings.Contains(stripped, want) {
+			t.Errorf("expected line %q in diff, got:\n%s", want, stripped)
+		}
+	}
+
+	cmd = exec.Command("go", "run", ".", "diff", oldPath)
+	if err := cmd.Run(); err == nil {
+		t.Error("jink diff with one file should fail")
+	}
+}
+
+// TestDiffLines tests the edit script and the hierarchy of each change
+func TestDiffLines(t *testing.T) {
+	a := splitLines("interfaces {\n    ge-0/0/0 {\n        mtu 1500;\n    }\n}\n")
+	b := splitLines("interfaces {\n    ge-0/0/0 {\n        mtu 9192;\n    }\n}\n")
+
+	var changes []string
+	for _, line := range diffLines(a, b) {
+		if line.op != diffEqual {
+			changes = append(changes, diffPrefixes[line.op]+line.text+" @ "+line.path)
+		}
+	}
+	want := []string{
+		"-         mtu 1500; @ interfaces ge-0/0/0",
+		"+         mtu 9192; @ interfaces ge-0/0/0",
+	}
+	if strings.Join(changes, "\n") != strings.Join(want, "\n") {
+		t.Errorf("expected changes %q, got %q", want, changes)
+	}
+
+	if out := formatDiff(diffLines(a, a)); out != "" {
+		t.Errorf("expected no output for identical configs, got %q", out)
+	}
+	if out := formatDiff(diffLines(nil, splitLines("set system host-name r1"))); out != "[edit]\n+ set system host-name r1\n" {
+		t.Errorf("unexpected diff of set commands: %q", out)
+	}
+}
+
+// TestDiffLinesShortest tests the edit script is a shortest one that turns
+// the old lines into the new ones, including when they interleave
+func TestDiffLinesShortest(t *testing.T) {
+	tests := []struct {
+		a, b  string
+		edits int
+	}{
+		{"a b c a b b a", "c b a b a c", 5},
+		{"x y z", "p q", 5},
+		{"a b c d e", "b c x d", 3},
+		{"1 2 3 4 5 6", "6 5 4 3 2 1", 10},
+	}
+	for _, tt := range tests {
+		a, b := strings.Fields(tt.a), strings.Fields(tt.b)
+		var oldLines, newLines []string
+		edits := 0
+		for _, line := range diffLines(a, b) {
+			if line.op != diffInsert {
+				oldLines = append(oldLines, line.text)
+			}
+			if line.op != diffDelete {
+				newLines = append(newLines, line.text)
+			}
+			if line.op != diffEqual {
+				edits++
+			}
+		}
+		if strings.Join(oldLines, " ") != tt.a || strings.Join(newLines, " ") != tt.b {
+			t.Errorf("%q -> %q: script gives %q -> %q", tt.a, tt.b, oldLines, newLines)
+		}
+		if edits != tt.edits {
+			t.Errorf("%q -> %q: %d edits, want %d", tt.a, tt.b, edits, tt.edits)
+		}
+	}
+}
+
+// TestCLIIndentGuides tests --indent-guides draws guides in piped config
+func TestCLIIndentGuides(t *testing.T) {
+	input := "system {\n    services {\n        ssh;\n    }\n}\n"
+
+	run := func(args ...string) string {
+		cmd := exec.Command("go", append([]string{"run", "."}, args...)...)
+		cmd.Stdin = strings.NewReader(input)
+		output, err := cmd.CombinedOutput()
+		if err != nil {
+			t.Fatalf("jink %v failed: %v\nOutput: %s", args, err, output)
+		}
+		return highlighter.StripANSI(string(output))
+	}
+
+	if out := run("--force"); out != input {
+		t.Errorf("expected no guides by default, got %q", out)
+	}
+	want := "system {\n│   services {\n│   │   ssh;\n│   }\n}\n"
+	if out := run("--force", "--indent-guides"); out != want {
+		t.Errorf("expected %q, got %q", want, out)
+	}
+}
+
+// TestCLIVersionJSON tests --version --json prints parseable version info
+func TestCLIVersionJSON(t *testing.T) {
+	output, err := exec.Command("go", "run", ".", "--version", "--json").Output()
+	if err != nil {
+		t.Fatalf("--version --json failed: %v\nOutput: %s", err, output)
+	}
+
+	var info map[string]string
+	if err := json.Unmarshal(output, &info); err != nil {
+		t.Fatalf("expected JSON, got %q: %v", output, err)
+	}
+	if info["name"] != "jink" || info["version"] != "dev" {
+		t.Errorf("unexpected version info: %v", info)
+	}
+
+	// Plain --version is unchanged
+	output, err = exec.Command("go", "run", ".", "-v").Output()
+	if err != nil || string(output) != "jink version dev\n" {
+		t.Errorf("expected plain --version output, got %q (%v)", output, err)
+	}
+}
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"golang.org/x/term"
+
+	"github.com/lasseh/jink/highlighter"
+	"github.com/lasseh/jink/internal/sample"
+)
+
+// pickerKey is a key press the theme picker acts on
+type pickerKey int
+
+const (
+	keyNone   pickerKey = iota // anything else, ignored
+	keyNext                    // right/down arrow, l, j, tab
+	keyPrev                    // left/up arrow, h, k
+	keySelect                  // enter
+	keyQuit                    // q, escape, ctrl-c
+)
+
+// parsePickerKey decodes the key at the start of buf and returns it along
+// with the number of bytes it takes up
+func parsePickerKey(buf []byte) (pickerKey, int) {
+	if len(buf) == 0 {
+		return keyNone, 0
+	}
+
+	// Arrow keys: ESC [ A-D (or ESC O A-D in application cursor mode)
+	if buf[0] == '\033' {
+		if len(buf) == 1 {
+			return keyQuit, 1
+		}
+		if len(buf) >= 3 && (buf[1] == '[' || buf[1] == 'O') {
+			switch buf[2] {
+			case 'C', 'B':
+				return keyNext, 3
+			case 'D', 'A':
+				return keyPrev, 3
+			}
+			return keyNone, 3
+		}
+		return keyNone, 1
+	}
+
+	switch buf[0] {
+	case 'l', 'j', '\t', ' ':
+		return keyNext, 1
+	case 'h', 'k':
+		return keyPrev, 1
+	case '\r', '\n':
+		return keySelect, 1
+	case 'q', 3: // 3 is ctrl-c in raw mode
+		return keyQuit, 1
+	}
+	return keyNone, 1
+}
+
+// themePicker cycles through the built-in themes
+type themePicker struct {
+	names  []string
+	index  int
+	chosen string // selected theme name, "" until selected
+}
+
+// newThemePicker starts the picker at the theme called start, or the first one
+func newThemePicker(start string) *themePicker {
+	p := &themePicker{names: highlighter.ThemeNames()}
+	for i, name := range p.names {
+		if name == start {
+			p.index = i
+		}
+	}
+	return p
+}
+
+// handle applies key and reports whether the picker is done
+func (p *themePicker) handle(key pickerKey) bool {
+	switch key {
+	case keyNext:
+		p.index = (p.index + 1) % len(p.names)
+	case keyPrev:
+		p.index = (p.index + len(p.names) - 1) % len(p.names)
+	case keySelect:
+		p.chosen = p.names[p.index]
+		return true
+	case keyQuit:
+		return true
+	}
+	return false
+}
+
+// render draws the current theme's preview. Lines end in "\r\n" because the
+// terminal is in raw mode.
+func (p *themePicker) render(w io.Writer) {
+	name := p.names[p.index]
+	theme := highlighter.ThemeByName(name).Degrade(highlighter.DetectColorDepth())
+
+	var sb strings.Builder
+	sb.WriteString(clearScreen)
+	fmt.Fprintf(&sb, "Theme %d/%d: %s\n", p.index+1, len(p.names), name)
+	sb.WriteString("left/right to change, enter to select, q to quit\n\n")
+	sb.WriteString(highlighter.NewWithTheme(theme).HighlightForced(sample.Snippet))
+	fmt.Fprint(w, strings.ReplaceAll(sb.String(), "\n", "\r\n"))
+}
+
+// clearScreen moves the cursor home and clears the screen
+const clearScreen = "\033[H\033[2J"
+
+// runThemePicker lets the user page through the themes on the terminal in,
+// drawing to out, and returns the selected theme name ("" if they quit)
+func runThemePicker(in *os.File, out io.Writer, start string) (string, error) {
+	fd := int(in.Fd())
+	if !term.IsTerminal(fd) {
+		return "", fmt.Errorf("--pick-theme needs an interactive terminal")
+	}
+	oldState, err := term.MakeRaw(fd)
+	if err != nil {
+		return "", fmt.Errorf("setting raw mode: %w", err)
+	}
+	defer func() { _ = term.Restore(fd, oldState) }()
+
+	p := newThemePicker(start)
+	buf := make([]byte, 64)
+	for {
+		p.render(out)
+		n, err := in.Read(buf)
+		if err != nil {
+			return "", err
+		}
+		for keys := buf[:n]; len(keys) > 0; {
+			key, size := parsePickerKey(keys)
+			keys = keys[size:]
+			if p.handle(key) {
+				fmt.Fprint(out, clearScreen)
+				return p.chosen, nil
+			}
+		}
+	}
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/lasseh/jink/highlighter"
+	"github.com/lasseh/jink/internal/sample"
+)
+
+// previewPrompt is a sample CLI prompt in operational and configuration mode
+const previewPrompt = `admin@core-router-01> show configuration
+[edit]
+admin@core-router-01# commit
+`
+
+// runThemePreview loads a theme file, prints the demo samples with it and
+// reports which palette fields fell back to the default theme.
+func runThemePreview(path string, w io.Writer) error {
+	theme, missing, err := highlighter.LoadThemeFile(path)
+	if err != nil {
+		return err
+	}
+
+	hl := highlighter.NewWithTheme(theme)
+	fmt.Fprintf(w, "=== Theme preview: %s ===\n\n", path)
+	fmt.Fprintln(w, hl.HighlightForced(sample.Config))
+	fmt.Fprintln(w, hl.HighlightShowOutput(sample.BGPSummary))
+	fmt.Fprintln(w, hl.HighlightShowOutput(sample.RouteTable))
+	for _, line := range strings.SplitAfter(previewPrompt, "\n") {
+		fmt.Fprint(w, hl.HighlightForced(line))
+	}
+
+	total := len(highlighter.PaletteFieldNames())
+	fmt.Fprintf(w, "\n=== Coverage: %d/%d palette fields set ===\n", total-len(missing), total)
+	if len(missing) == 0 {
+		fmt.Fprintln(w, "All palette fields are set.")
+		return nil
+	}
+	fmt.Fprintln(w, "Missing (using default theme colors):")
+	for _, name := range missing {
+		fmt.Fprintf(w, "    %s\n", name)
+	}
+	return nil
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"runtime"
+	"runtime/pprof"
+
+	"github.com/lasseh/jink/highlighter"
+)
+
+// highlightStdinProfiled runs highlightStdin under the CPU and heap profilers
+// requested with the hidden --cpuprofile and --memprofile flags. Empty paths
+// skip the corresponding profile.
+func highlightStdinProfiled(hl *highlighter.Highlighter, opts stdinOptions) error {
+	cpuPath, memPath := opts.cpuProfile, opts.memProfile
+	if cpuPath != "" {
+		f, err := os.Create(cpuPath)
+		if err != nil {
+			return fmt.Errorf("creating CPU profile: %w", err)
+		}
+		defer func() { _ = f.Close() }()
+
+		if err := pprof.StartCPUProfile(f); err != nil {
+			return fmt.Errorf("starting CPU profile: %w", err)
+		}
+		defer pprof.StopCPUProfile()
+	}
+
+	if err := highlightStdin(hl, opts); err != nil {
+		return err
+	}
+
+	if memPath != "" {
+		f, err := os.Create(memPath)
+		if err != nil {
+			return fmt.Errorf("creating memory profile: %w", err)
+		}
+		defer func() { _ = f.Close() }()
+
+		runtime.GC() // get up-to-date allocation statistics
+		if err := pprof.WriteHeapProfile(f); err != nil {
+			return fmt.Errorf("writing memory profile: %w", err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,45 @@
+package highlighter
+
+import (
+	"runtime"
+	"sync"
+)
+
+// HighlightBatch highlights many inputs concurrently with theme (the default
+// theme if nil), like calling Highlight on each, and returns the results in
+// the order of inputs. workers sets how many inputs are highlighted at once;
+// workers <= 0 uses one per CPU.
+func HighlightBatch(inputs []string, theme *Theme, workers int) []string {
+	if theme == nil {
+		theme = DefaultTheme()
+	}
+	if workers <= 0 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+	if workers > len(inputs) {
+		workers = len(inputs)
+	}
+
+	h := NewWithTheme(theme)
+	results := make([]string, len(inputs))
+	jobs := make(chan int)
+
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			// Each worker writes only the results of the indices it receives
+			for i := range jobs {
+				results[i] = h.Highlight(inputs[i])
+			}
+		}()
+	}
+	for i := range inputs {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+
+	return results
+}
@@ -0,0 +1,123 @@
+package highlighter
+
+import (
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/lasseh/jink/lexer"
+)
+
+// ColorDepth is the range of colors a theme uses or a terminal can show.
+type ColorDepth int
+
+const (
+	// ColorDepthTrueColor is 24-bit RGB color ("\033[38;2;R;G;Bm").
+	ColorDepthTrueColor ColorDepth = iota
+
+	// ColorDepth256 is the xterm 256-color palette ("\033[38;5;Nm").
+	ColorDepth256
+)
+
+// String returns the name of the color depth ("truecolor" or "256")
+func (d ColorDepth) String() string {
+	switch d {
+	case ColorDepthTrueColor:
+		return "truecolor"
+	case ColorDepth256:
+		return "256"
+	default:
+		return "unknown"
+	}
+}
+
+// rgbSequencePattern matches true color foreground and background sequences
+var rgbSequencePattern = regexp.MustCompile(`\033\[(38|48);2;(\d+);(\d+);(\d+)m`)
+
+// DetectColorDepth reports the terminal's color depth from $COLORTERM.
+// Terminals that support true color advertise it as "truecolor" or "24bit";
+// anything else is assumed to support 256 colors.
+func DetectColorDepth() ColorDepth {
+	return detectColorDepth(os.Getenv)
+}
+
+func detectColorDepth(getenv func(string) string) ColorDepth {
+	switch strings.ToLower(getenv("COLORTERM")) {
+	case "truecolor", "24bit":
+		return ColorDepthTrueColor
+	default:
+		return ColorDepth256
+	}
+}
+
+// ColorDepth returns ColorDepthTrueColor if any of the theme's colors are RGB,
+// otherwise ColorDepth256.
+func (t *Theme) ColorDepth() ColorDepth {
+	for _, color := range t.colors {
+		if rgbSequencePattern.MatchString(color) {
+			return ColorDepthTrueColor
+		}
+	}
+	return ColorDepth256
+}
+
+// Degrade returns the theme converted to depth. RGB colors are replaced by
+// the nearest 256-color palette entry when depth is ColorDepth256. The theme
+// itself is returned if it already fits; otherwise the copy doesn't follow
+// later SetColor calls on t.
+func (t *Theme) Degrade(depth ColorDepth) *Theme {
+	if depth == ColorDepthTrueColor || t.ColorDepth() == ColorDepth256 {
+		return t
+	}
+
+	colors := make(map[lexer.TokenType]string, len(t.colors))
+	for tokenType, color := range t.colors {
+		colors[tokenType] = degradeColor(color)
+	}
+	palette := t.palette
+	for _, field := range paletteFields(&palette) {
+		field.SetString(degradeColor(field.String()))
+	}
+	return &Theme{colors: colors, palette: palette}
+}
+
+// degradeColor replaces the RGB sequences in an ANSI style with 256-color ones
+func degradeColor(style string) string {
+	return rgbSequencePattern.ReplaceAllStringFunc(style, func(seq string) string {
+		m := rgbSequencePattern.FindStringSubmatch(seq)
+		r, _ := strconv.Atoi(m[2])
+		g, _ := strconv.Atoi(m[3])
+		b, _ := strconv.Atoi(m[4])
+		return "\033[" + m[1] + ";5;" + strconv.Itoa(rgbTo256(r, g, b)) + "m"
+	})
+}
+
+// rgbTo256 maps an RGB color to the nearest xterm 256-color palette index,
+// using the 24-step gray ramp for grays and the 6x6x6 color cube otherwise.
+func rgbTo256(r, g, b int) int {
+	if r == g && g == b {
+		switch {
+		case r < 8:
+			return 16
+		case r > 248:
+			return 231
+		default:
+			return 232 + min((r-3)/10, 23) // ramp steps are 8, 18, ..., 238
+		}
+	}
+	return 16 + 36*cubeLevel(r) + 6*cubeLevel(g) + cubeLevel(b)
+}
+
+// cubeLevel maps a color channel to the nearest of the cube's six levels
+// (0, 95, 135, 175, 215, 255)
+func cubeLevel(v int) int {
+	switch {
+	case v < 48:
+		return 0
+	case v < 115:
+		return 1
+	default:
+		return (v - 35) / 40
+	}
+}
@@ -0,0 +1,105 @@
+package highlighter
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/lasseh/jink/lexer"
+)
+
+// tabularLinePattern matches lines with at least three cells separated by
+// runs of spaces, like the rows and headers of show command tables
+var tabularLinePattern = regexp.MustCompile(`\S+\s{2,}\S+\s{2,}\S+`)
+
+// GetColumns returns the byte offsets at which the columns of tabular text
+// start. Only lines that look tabular are considered: a column starts wherever
+// text follows a position that is blank on every one of them. Returns nil if
+// fewer than two columns are found.
+func GetColumns(input string) []int {
+	var filled []bool // whether any tabular line has text at each position
+	for _, line := range strings.Split(input, "\n") {
+		if !tabularLinePattern.MatchString(line) {
+			continue
+		}
+		line = strings.TrimRight(line, "\r")
+		for len(filled) < len(line) {
+			filled = append(filled, false)
+		}
+		for i := 0; i < len(line); i++ {
+			if line[i] != ' ' && line[i] != '\t' {
+				filled[i] = true
+			}
+		}
+	}
+
+	var columns []int
+	for i, f := range filled {
+		if f && (i == 0 || !filled[i-1]) {
+			columns = append(columns, i)
+		}
+	}
+	if len(columns) < 2 {
+		return nil
+	}
+	return columns
+}
+
+// SetTabularColumnColors tints the plain cells of tabular show output with
+// colors, one column after another and repeating, e.g. two shades of the
+// foreground for alternating columns. Only identifiers and plain text are
+// tinted; states, addresses and other semantic tokens keep their colors.
+// Calling it with no colors turns tinting off.
+func (h *Highlighter) SetTabularColumnColors(colors ...string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.columnColors = colors
+}
+
+// columnTints picks the tint for tokens in tabular text
+type columnTints struct {
+	colors  []string
+	columns []int        // column start offsets from GetColumns
+	tabular map[int]bool // line numbers (1-based) of tabular lines
+}
+
+// newColumnTints finds the columns of text, the visible text of the tokens
+// being rendered. Returns nil if there are no colors or no columns.
+func newColumnTints(text string, colors []string) *columnTints {
+	if len(colors) == 0 {
+		return nil
+	}
+	columns := GetColumns(text)
+	if columns == nil {
+		return nil
+	}
+
+	tabular := make(map[int]bool)
+	for i, line := range strings.Split(text, "\n") {
+		if tabularLinePattern.MatchString(line) {
+			tabular[i+1] = true
+		}
+	}
+	return &columnTints{colors: colors, columns: columns, tabular: tabular}
+}
+
+// tint returns the tint for token, or "" if it keeps its theme color
+func (c *columnTints) tint(token lexer.Token) string {
+	if c == nil || !c.tabular[token.Line] {
+		return ""
+	}
+	if token.Type != lexer.TokenIdentifier && (token.Type != lexer.TokenText || strings.TrimSpace(token.Value) == "") {
+		return ""
+	}
+
+	column := -1
+	for _, start := range c.columns {
+		if start > token.Column-1 {
+			break
+		}
+		column++
+	}
+	if column < 0 {
+		return ""
+	}
+	return c.colors[column%len(c.colors)]
+}
@@ -0,0 +1,71 @@
+package highlighter
+
+import (
+	"strings"
+
+	"github.com/lasseh/jink/lexer"
+)
+
+// CommentStyle is the emphasis of comments and annotations. It changes their
+// text attributes only; the color stays the theme's.
+type CommentStyle int
+
+const (
+	// CommentStyleTheme keeps the theme's attributes (italic by default).
+	CommentStyleTheme CommentStyle = iota
+
+	// CommentStyleDim renders comments faint.
+	CommentStyleDim
+
+	// CommentStyleNormal renders comments without attributes.
+	CommentStyleNormal
+
+	// CommentStyleBold renders comments bold (bright on most terminals).
+	CommentStyleBold
+)
+
+// String returns the name of the comment style ("theme", "dim", "normal" or "bold")
+func (s CommentStyle) String() string {
+	switch s {
+	case CommentStyleTheme:
+		return "theme"
+	case CommentStyleDim:
+		return "dim"
+	case CommentStyleNormal:
+		return "normal"
+	case CommentStyleBold:
+		return "bold"
+	default:
+		return "unknown"
+	}
+}
+
+// commentAttributes are the text attributes replaced by a comment style
+var commentAttributes = strings.NewReplacer(Bold, "", Dim, "", Italic, "", Underline, "")
+
+// WithCommentStyle returns a copy of the theme with comments and annotations
+// in the given style. The theme itself is returned for CommentStyleTheme.
+func (t *Theme) WithCommentStyle(style CommentStyle) *Theme {
+	if style == CommentStyleTheme {
+		return t
+	}
+
+	styled := t.Clone()
+	for _, tokenType := range []lexer.TokenType{lexer.TokenComment, lexer.TokenAnnotation} {
+		styled.SetColor(tokenType, style.apply(t.GetColor(tokenType)))
+	}
+	return styled
+}
+
+// apply replaces the attributes of an ANSI style with the comment style's
+func (s CommentStyle) apply(color string) string {
+	color = commentAttributes.Replace(color)
+	switch s {
+	case CommentStyleDim:
+		return Dim + color
+	case CommentStyleBold:
+		return Bold + color
+	default:
+		return color
+	}
+}
@@ -0,0 +1,180 @@
+package highlighter
+
+import (
+	"io"
+	"strconv"
+	"strings"
+)
+
+// Windows console character attributes (wincon.h)
+const (
+	consoleBlue      uint16 = 0x1
+	consoleGreen     uint16 = 0x2
+	consoleRed       uint16 = 0x4
+	consoleIntensity uint16 = 0x8
+	consoleFgMask    uint16 = 0x0f
+	consoleBgMask    uint16 = 0xf0
+	consoleBgShift          = 4
+)
+
+// consolePalette is the RGB value of each of the 16 legacy console colors,
+// indexed by attribute value
+var consolePalette = [16][3]int{
+	{0, 0, 0}, {0, 0, 128}, {0, 128, 0}, {0, 128, 128},
+	{128, 0, 0}, {128, 0, 128}, {128, 128, 0}, {192, 192, 192},
+	{128, 128, 128}, {0, 0, 255}, {0, 255, 0}, {0, 255, 255},
+	{255, 0, 0}, {255, 0, 255}, {255, 255, 0}, {255, 255, 255},
+}
+
+// ansiToConsole maps the 8 ANSI color numbers (30-37 minus 30) to console
+// attribute bits
+var ansiToConsole = [8]uint16{
+	0, consoleRed, consoleGreen, consoleRed | consoleGreen,
+	consoleBlue, consoleRed | consoleBlue, consoleGreen | consoleBlue,
+	consoleRed | consoleGreen | consoleBlue,
+}
+
+// consoleWriter renders highlighted output on a legacy Windows console that
+// doesn't understand ANSI escape codes. Text is written to w and each SGR
+// sequence becomes a call to setAttr with the equivalent console attributes.
+// Other escape sequences are dropped. Sequences must not be split across
+// Write calls, which holds for Highlighter output written line by line.
+type consoleWriter struct {
+	w       io.Writer
+	setAttr func(attr uint16) error
+	base    uint16 // attributes in effect before highlighting, restored on reset
+	attr    uint16
+}
+
+func newConsoleWriter(w io.Writer, base uint16, setAttr func(uint16) error) *consoleWriter {
+	return &consoleWriter{w: w, setAttr: setAttr, base: base, attr: base}
+}
+
+// Write implements io.Writer
+func (c *consoleWriter) Write(p []byte) (int, error) {
+	for _, seg := range extractSegments(string(p)) {
+		if !seg.isEscape {
+			if _, err := io.WriteString(c.w, seg.text); err != nil {
+				return 0, err
+			}
+			continue
+		}
+		if len(seg.text) < 3 || seg.text[1] != csiBracket || seg.text[len(seg.text)-1] != 'm' {
+			continue
+		}
+		c.attr = applySGR(c.attr, c.base, seg.text[2:len(seg.text)-1])
+		if err := c.setAttr(c.attr); err != nil {
+			return 0, err
+		}
+	}
+	return len(p), nil
+}
+
+// applySGR returns attr updated by the parameters of one SGR sequence
+func applySGR(attr, base uint16, params string) uint16 {
+	fields := strings.Split(params, ";")
+	for i := 0; i < len(fields); i++ {
+		n, err := strconv.Atoi(fields[i])
+		if err != nil && fields[i] != "" {
+			continue
+		}
+		switch {
+		case n == 0:
+			attr = base
+		case n == 1:
+			attr |= consoleIntensity
+		case n == 7:
+			attr = (attr&consoleFgMask)<<consoleBgShift | (attr&consoleBgMask)>>consoleBgShift
+		case n >= 30 && n <= 37:
+			attr = attr&^consoleFgMask | ansiToConsole[n-30]
+		case n >= 90 && n <= 97:
+			attr = attr&^consoleFgMask | ansiToConsole[n-90] | consoleIntensity
+		case n == 39:
+			attr = attr&^consoleFgMask | base&consoleFgMask
+		case n >= 40 && n <= 47:
+			attr = attr&^consoleBgMask | ansiToConsole[n-40]<<consoleBgShift
+		case n == 49:
+			attr = attr&^consoleBgMask | base&consoleBgMask
+		case n == 38 || n == 48:
+			color, used, ok := parseExtendedColor(fields[i+1:])
+			i += used
+			if !ok {
+				continue
+			}
+			if n == 38 {
+				attr = attr&^consoleFgMask | color
+			} else {
+				attr = attr&^consoleBgMask | color<<consoleBgShift
+			}
+		}
+	}
+	return attr
+}
+
+// parseExtendedColor parses the arguments of a 38/48 SGR parameter
+// ("5;N" or "2;R;G;B") into the nearest console color. used is the number
+// of fields consumed. Values outside 0-255 make the color unknown.
+func parseExtendedColor(fields []string) (color uint16, used int, ok bool) {
+	nums := make([]int, 0, 4)
+	for _, f := range fields {
+		n, err := strconv.Atoi(f)
+		if err != nil {
+			break
+		}
+		nums = append(nums, n)
+	}
+	switch {
+	case len(nums) >= 2 && nums[0] == 5:
+		if !isColorByte(nums[1]) {
+			return 0, 2, false
+		}
+		r, g, b := color256ToRGB(nums[1])
+		return nearestConsoleColor(r, g, b), 2, true
+	case len(nums) >= 4 && nums[0] == 2:
+		if !isColorByte(nums[1]) || !isColorByte(nums[2]) || !isColorByte(nums[3]) {
+			return 0, 4, false
+		}
+		return nearestConsoleColor(nums[1], nums[2], nums[3]), 4, true
+	default:
+		return 0, len(nums), false
+	}
+}
+
+// isColorByte reports whether n is a valid palette index or RGB component
+func isColorByte(n int) bool {
+	return n >= 0 && n <= 255
+}
+
+// color256ToRGB returns the RGB value of an xterm 256-color palette index
+func color256ToRGB(n int) (r, g, b int) {
+	switch {
+	case n < 16:
+		// Standard and bright colors: the console color with the same meaning
+		c := consolePalette[ansiToConsole[n%8]|uint16(n/8)*consoleIntensity]
+		return c[0], c[1], c[2]
+	case n < 232:
+		n -= 16
+		level := func(v int) int {
+			if v == 0 {
+				return 0
+			}
+			return 55 + v*40
+		}
+		return level(n / 36), level(n / 6 % 6), level(n % 6)
+	default:
+		gray := 8 + (n-232)*10
+		return gray, gray, gray
+	}
+}
+
+// nearestConsoleColor returns the console color closest to an RGB value
+func nearestConsoleColor(r, g, b int) uint16 {
+	best, bestDist := 0, -1
+	for i, c := range consolePalette {
+		dr, dg, db := r-c[0], g-c[1], b-c[2]
+		if dist := dr*dr + dg*dg + db*db; bestDist < 0 || dist < bestDist {
+			best, bestDist = i, dist
+		}
+	}
+	return uint16(best)
+}
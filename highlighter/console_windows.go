@@ -0,0 +1,57 @@
+//go:build windows
+
+package highlighter
+
+import (
+	"io"
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+var (
+	kernel32                       = syscall.NewLazyDLL("kernel32.dll")
+	procGetConsoleMode             = kernel32.NewProc("GetConsoleMode")
+	procSetConsoleMode             = kernel32.NewProc("SetConsoleMode")
+	procGetConsoleScreenBufferInfo = kernel32.NewProc("GetConsoleScreenBufferInfo")
+	procSetConsoleTextAttribute    = kernel32.NewProc("SetConsoleTextAttribute")
+)
+
+// enableVirtualTerminalProcessing is the console mode flag for ANSI support
+const enableVirtualTerminalProcessing = 0x0004
+
+// consoleScreenBufferInfo mirrors CONSOLE_SCREEN_BUFFER_INFO
+type consoleScreenBufferInfo struct {
+	size              [2]int16
+	cursorPosition    [2]int16
+	attributes        uint16
+	window            [4]int16
+	maximumWindowSize [2]int16
+}
+
+// NewConsoleWriter returns a writer for highlighted output to f. If f is a
+// Windows console that can't enable ANSI escape code processing (consoles
+// before Windows 10), colors are set with SetConsoleTextAttribute instead.
+// Otherwise f is returned as is.
+func NewConsoleWriter(f *os.File) io.Writer {
+	handle := syscall.Handle(f.Fd())
+
+	var mode uint32
+	if r, _, _ := procGetConsoleMode.Call(uintptr(handle), uintptr(unsafe.Pointer(&mode))); r == 0 {
+		return f // not a console
+	}
+	if r, _, _ := procSetConsoleMode.Call(uintptr(handle), uintptr(mode|enableVirtualTerminalProcessing)); r != 0 {
+		return f // the console understands ANSI
+	}
+
+	var info consoleScreenBufferInfo
+	if r, _, _ := procGetConsoleScreenBufferInfo.Call(uintptr(handle), uintptr(unsafe.Pointer(&info))); r == 0 {
+		return f
+	}
+	return newConsoleWriter(f, info.attributes, func(attr uint16) error {
+		if r, _, err := procSetConsoleTextAttribute.Call(uintptr(handle), uintptr(attr)); r == 0 {
+			return err
+		}
+		return nil
+	})
+}
@@ -0,0 +1,16 @@
+package highlighter
+
+import "errors"
+
+// Errors returned by the theme functions and NewWriter's writer. The theme
+// errors are wrapped with details, so test for them with errors.Is.
+var (
+	// ErrUnknownTheme is returned for a theme name that isn't built in.
+	ErrUnknownTheme = errors.New("unknown theme")
+
+	// ErrInvalidThemeFile is returned for a theme file that can't be parsed.
+	ErrInvalidThemeFile = errors.New("invalid theme file")
+
+	// ErrWriterClosed is returned for a Write after Close.
+	ErrWriterClosed = errors.New("write to closed highlighting writer")
+)
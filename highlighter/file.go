@@ -0,0 +1,79 @@
+package highlighter
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/lasseh/jink/lexer"
+)
+
+// fileChunkSize is roughly how much of a file HighlightFile reads and
+// highlights at a time. Chunks always end on a line boundary.
+const fileChunkSize = 64 * 1024
+
+// HighlightFile reads the file at path and returns it highlighted with theme
+// (the default theme if nil). The parse mode is detected from the start of the
+// file: configuration, show output, or a "show | compare" diff, which is
+// highlighted as configuration. Large files are read and highlighted in
+// chunks of whole lines rather than all at once.
+func HighlightFile(path string, theme *Theme) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer func() { _ = f.Close() }()
+
+	if theme == nil {
+		theme = DefaultTheme()
+	}
+	h := NewWithTheme(theme)
+	stream := h.NewStream()
+
+	r := bufio.NewReaderSize(f, fileChunkSize)
+	var out strings.Builder
+	detected := false
+	for {
+		chunk, err := readLines(r, fileChunkSize)
+		if chunk != "" {
+			if !detected {
+				h.SetParseMode(detectFileMode(chunk))
+				detected = true
+			}
+			out.WriteString(stream.HighlightForced(chunk))
+		}
+		if err == io.EOF {
+			return out.String(), nil
+		}
+		if err != nil {
+			return "", fmt.Errorf("reading %s: %w", path, err)
+		}
+	}
+}
+
+// readLines reads whole lines from r until at least n bytes have been read,
+// the input ends or an error occurs
+func readLines(r *bufio.Reader, n int) (string, error) {
+	var sb strings.Builder
+	for sb.Len() < n {
+		line, err := r.ReadString('\n')
+		sb.WriteString(line)
+		if err != nil {
+			return sb.String(), err
+		}
+	}
+	return sb.String(), nil
+}
+
+// detectFileMode picks the parse mode for a file from its first chunk
+func detectFileMode(sample string) lexer.ParseMode {
+	sample = StripANSI(sample)
+	for _, line := range strings.Split(sample, "\n") {
+		if lexer.ClassifyLine(line) == lexer.LineDiff {
+			return lexer.ParseModeConfig
+		}
+	}
+	return lexer.New(sample).DetectionReport().Mode
+}
@@ -0,0 +1,56 @@
+package highlighter
+
+import (
+	"strings"
+)
+
+// indentGuideStyle dims the indent guide characters
+const indentGuideStyle = "\033[2m"
+
+// indentGuideChar marks each level of a config hierarchy
+const indentGuideChar = "│"
+
+// SetIndentGuides draws a dim vertical guide in the indentation of config
+// lines for each enclosing brace block, replacing spaces so line widths are
+// unchanged. Each Highlight call starts at depth 0; highlight line-by-line
+// input (a pipe, an SSH session) with a Stream so the block depth carries
+// over from one line to the next. Off by default.
+func (h *Highlighter) SetIndentGuides(on bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.guides = on
+}
+
+// splitIndentGuide splits the indentation that ends a whitespace token off
+// its last piece and marks it with the guides for depth blocks. The
+// indentation must start a line: follow a newline, or be at the start of
+// the input (lineStart).
+func splitIndentGuide(parts []textPiece, depth int, lineStart bool) []textPiece {
+	last := parts[len(parts)-1]
+	nl := strings.LastIndexAny(last.text, "\r\n")
+	indent := last.text[nl+1:]
+	if (nl < 0 && !lineStart) || indent == "" || strings.Trim(indent, " ") != "" {
+		return parts
+	}
+	guide := indentGuide(len(indent), depth)
+	if guide == "" {
+		return parts
+	}
+
+	parts = parts[:len(parts)-1]
+	if nl >= 0 {
+		parts = append(parts, textPiece{text: last.text[:nl+1], trailing: last.trailing})
+	}
+	return append(parts, textPiece{text: indent, guide: guide})
+}
+
+// indentGuide returns width columns of indentation with a guide at the start
+// of each of depth equal steps, or "" if the indentation is too narrow.
+func indentGuide(width, depth int) string {
+	if depth <= 0 || width < depth {
+		return ""
+	}
+	step := width / depth
+	level := indentGuideChar + strings.Repeat(" ", step-1)
+	return strings.Repeat(level, depth) + strings.Repeat(" ", width-step*depth)
+}
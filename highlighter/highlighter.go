@@ -2,6 +2,7 @@ package highlighter
 
 import (
 	"bytes"
+	"regexp"
 	"strings"
 	"sync"
 
@@ -18,24 +19,87 @@ func Highlight(input string) string {
 // It supports multiple color themes and can be toggled on/off at runtime.
 // All methods are safe for concurrent use.
 type Highlighter struct {
-	theme   *Theme
-	enabled bool
-	mu      sync.RWMutex
+	theme      *Theme
+	enabled    bool
+	threshold  DetectionThreshold
+	parseMode  lexer.ParseMode
+	match      *regexp.Regexp
+	matchColor string
+	trailing   bool
+	comments   []string
+	depth      ColorDepth
+	rendered   *Theme // theme converted to depth
+	renderedAt int    // theme version rendered was converted from
+	maxLine    int
+	skipANSI   bool
+	colorFunc  func(token lexer.Token, defaultColor string) string
+	mu         sync.RWMutex
+
+	// columnColors tint the plain cells of tabular output, column by column
+	columnColors []string
+
+	// percentBands color percentages by value (nil = theme color only)
+	percentBands *PercentageBands
+
+	// wrapWidth soft-wraps long comments and values (0 = off)
+	wrapWidth int
+
+	// Indent guides (see SetIndentGuides)
+	guides bool
+
+	// caseSensitive limits config keywords to lower case
+	caseSensitive bool
+}
+
+// DefaultMaxLineLength is the longest line (in bytes) highlighted by default.
+// Longer lines, such as base64 blobs in a config, are passed through as is.
+const DefaultMaxLineLength = 16 * 1024
+
+// DetectionThreshold controls how confident Highlight must be that input is
+// JunOS before coloring it.
+type DetectionThreshold int
+
+const (
+	// DetectionNormal highlights input with at least one JunOS indicator.
+	DetectionNormal DetectionThreshold = iota
+
+	// DetectionLoose also accepts weak indicators such as IPv4 addresses or
+	// brace/semicolon structure.
+	DetectionLoose
+
+	// DetectionStrict requires at least two independent JunOS indicators
+	// (a CLI prompt alone is still enough).
+	DetectionStrict
+)
+
+// String returns the threshold's name as used by the --detect flag
+func (d DetectionThreshold) String() string {
+	switch d {
+	case DetectionNormal:
+		return "normal"
+	case DetectionLoose:
+		return "loose"
+	case DetectionStrict:
+		return "strict"
+	default:
+		return "unknown"
+	}
 }
 
 // New creates a new Highlighter with the default theme (Tokyo Night).
 func New() *Highlighter {
-	return &Highlighter{
-		theme:   DefaultTheme(),
-		enabled: true,
-	}
+	return NewWithTheme(DefaultTheme())
 }
 
 // NewWithTheme creates a new Highlighter with a specific theme
 func NewWithTheme(theme *Theme) *Highlighter {
+	bands := DefaultPercentageBands
 	return &Highlighter{
-		theme:   theme,
-		enabled: true,
+		theme:        theme,
+		rendered:     theme,
+		enabled:      true,
+		maxLine:      DefaultMaxLineLength,
+		percentBands: &bands,
 	}
 }
 
@@ -44,6 +108,40 @@ func (h *Highlighter) SetTheme(theme *Theme) {
 	h.mu.Lock()
 	defer h.mu.Unlock()
 	h.theme = theme
+	h.rendered, h.renderedAt = theme.Degrade(h.depth), theme.version
+}
+
+// SetColorDepth sets the terminal's color depth. With ColorDepth256, RGB
+// themes are converted to the 256-color palette (see Theme.Degrade). The
+// default is ColorDepthTrueColor; DetectColorDepth reads it from the environment.
+func (h *Highlighter) SetColorDepth(depth ColorDepth) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.depth = depth
+	h.rendered, h.renderedAt = h.theme.Degrade(depth), h.theme.version
+}
+
+// renderedTheme returns the theme converted to the color depth, converting
+// it again if its colors were changed with Theme.SetColor since
+func (h *Highlighter) renderedTheme() *Theme {
+	h.mu.RLock()
+	rendered, stale := h.rendered, h.renderedAt != h.theme.version
+	h.mu.RUnlock()
+	if !stale {
+		return rendered
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.rendered, h.renderedAt = h.theme.Degrade(h.depth), h.theme.version
+	return h.rendered
+}
+
+// ColorDepth returns the terminal color depth set with SetColorDepth.
+func (h *Highlighter) ColorDepth() ColorDepth {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return h.depth
 }
 
 // Enable turns highlighting on.
@@ -60,6 +158,102 @@ func (h *Highlighter) Disable() {
 	h.enabled = false
 }
 
+// SetDetectionThreshold changes how many JunOS indicators Highlight requires.
+func (h *Highlighter) SetDetectionThreshold(level DetectionThreshold) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.threshold = level
+}
+
+// DetectionThreshold returns the threshold set with SetDetectionThreshold.
+func (h *Highlighter) DetectionThreshold() DetectionThreshold {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return h.threshold
+}
+
+// SetParseMode forces the lexer to use config or show rules instead of
+// auto-detecting them. lexer.ParseModeAuto restores auto-detection.
+func (h *Highlighter) SetParseMode(mode lexer.ParseMode) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.parseMode = mode
+}
+
+// SetHighlightPattern overlays color on every part of the visible text that
+// matches re, on top of the normal token colors. An empty color uses reverse
+// video; a nil re turns match highlighting off.
+func (h *Highlighter) SetHighlightPattern(re *regexp.Regexp, color string) {
+	if color == "" {
+		color = Reverse
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.match = re
+	h.matchColor = color
+}
+
+// SetShowTrailingWhitespace marks spaces and tabs at the end of a line with a
+// dim background so they stand out. Off by default.
+func (h *Highlighter) SetShowTrailingWhitespace(on bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.trailing = on
+}
+
+// SetCommentPrefixes replaces the prefixes that start a line comment ("#" by
+// default), e.g. SetCommentPrefixes("#", "!") for mixed Cisco/Juniper snippets.
+func (h *Highlighter) SetCommentPrefixes(prefixes ...string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.comments = prefixes
+}
+
+// SetCaseSensitive controls whether config keywords must be lower case (see
+// lexer.Lexer.SetCaseSensitive). Off by default, so upper case keywords are
+// colored too.
+func (h *Highlighter) SetCaseSensitive(on bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.caseSensitive = on
+}
+
+// SetMaxLineLength sets the longest line, in bytes, that is highlighted.
+// Longer lines are passed through unhighlighted so a single huge line can't
+// stall the output. n <= 0 removes the limit.
+func (h *Highlighter) SetMaxLineLength(n int) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.maxLine = n
+}
+
+// SetSkipIfColored makes Highlight, HighlightForced, HighlightShowOutput and
+// HighlightWithSpans return input that already contains ANSI escape codes
+// unchanged, so output piped through jink twice isn't highlighted again. Off
+// by default.
+func (h *Highlighter) SetSkipIfColored(on bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.skipANSI = on
+}
+
+// SetColorFunc sets a function that picks each token's color at render time,
+// e.g. to color interfaces by speed. It gets the token and the color the theme
+// would use, and returns the color to use instead ("" for none). A nil fn
+// restores the theme colors.
+func (h *Highlighter) SetColorFunc(fn func(token lexer.Token, defaultColor string) string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.colorFunc = fn
+}
+
+// skipInput reports whether input should be returned unhighlighted
+func (h *Highlighter) skipInput(input string) bool {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return !h.enabled || input == "" || (h.skipANSI && HasANSI(input))
+}
+
 // IsEnabled returns whether highlighting is enabled.
 func (h *Highlighter) IsEnabled() bool {
 	h.mu.RLock()
@@ -79,7 +273,12 @@ func (h *Highlighter) Toggle() bool {
 // Returns input unchanged if highlighting is disabled, input is empty,
 // or input doesn't look like JunOS config/output (uses heuristic detection).
 func (h *Highlighter) Highlight(input string) string {
-	if !h.IsEnabled() || input == "" {
+	return h.highlight(input, nil)
+}
+
+// highlight implements Highlight, continuing from state (nil for a fresh input)
+func (h *Highlighter) highlight(input string, state *renderState) string {
+	if h.skipInput(input) {
 		return input
 	}
 
@@ -87,79 +286,371 @@ func (h *Highlighter) Highlight(input string) string {
 	// that interferes with pattern matching
 	cleaned := StripANSI(input)
 
+	// XML (NETCONF replies, "| display xml") only gets its markup colored
+	if looksLikeXML(cleaned) {
+		return h.highlightXML(cleaned)
+	}
+
 	// Check if this looks like JunOS config (simple heuristics)
 	if !h.looksLikeJunOS(cleaned) {
 		return input
 	}
 
-	return h.highlightTokensCleaned(cleaned)
+	return h.highlightTokensCleaned(cleaned, state)
 }
 
 // HighlightForced applies syntax highlighting without checking if input looks like JunOS.
+// Colors already present in the input (SGR sequences emitted by the router) are
+// dropped so they don't mix with ours; cursor control sequences are preserved.
 func (h *Highlighter) HighlightForced(input string) string {
-	if !h.IsEnabled() || input == "" {
+	return h.highlightForced(input, nil)
+}
+
+// highlightForced implements HighlightForced, continuing from state (nil for
+// a fresh input)
+func (h *Highlighter) highlightForced(input string, state *renderState) string {
+	if h.skipInput(input) {
 		return input
 	}
-	return h.highlightTokens(input)
+	if looksLikeXML(StripANSI(input)) {
+		return highlightSegments(stripSGR(input), h.highlightXML)
+	}
+	return h.highlightTokens(stripSGR(input), state)
 }
 
 // highlightTokens tokenizes and colorizes the input while preserving cursor control sequences
-func (h *Highlighter) highlightTokens(input string) string {
-	// Extract cursor control sequences and text segments separately
-	// We need to preserve cursor movement/clear sequences for proper terminal rendering
-	segments := extractSegments(input)
+func (h *Highlighter) highlightTokens(input string, state *renderState) string {
+	return highlightSegments(input, func(text string) string {
+		return h.highlightTokensCleaned(text, state)
+	})
+}
 
+// highlightSegments applies highlight to the text between the escape
+// sequences of input, passing the escape sequences through unchanged. We
+// need to preserve cursor movement/clear sequences for proper terminal
+// rendering.
+func highlightSegments(input string, highlight func(string) string) string {
 	var buf bytes.Buffer
-	for _, seg := range segments {
+	for _, seg := range extractSegments(input) {
 		if seg.isEscape {
-			// Pass through escape sequences unchanged
 			buf.WriteString(seg.text)
 		} else {
-			// Highlight text segments
-			highlighted := h.highlightTokensCleaned(seg.text)
-			buf.WriteString(highlighted)
+			buf.WriteString(highlight(seg.text))
 		}
 	}
 	return buf.String()
 }
 
-// highlightTokensCleaned tokenizes and colorizes already-cleaned input
-func (h *Highlighter) highlightTokensCleaned(cleaned string) string {
-	lex := lexer.New(cleaned)
-	tokens := lex.Tokenize()
-	return h.renderTokens(tokens)
+// highlightTokensCleaned tokenizes and colorizes already-cleaned input,
+// continuing from state (nil for a fresh input)
+func (h *Highlighter) highlightTokensCleaned(cleaned string, state *renderState) string {
+	h.mu.RLock()
+	mode := h.parseMode
+	h.mu.RUnlock()
+
+	return h.highlightShortLines(cleaned, func(_ int, text string) string {
+		lex := h.newLexer(text)
+		if mode != lexer.ParseModeAuto {
+			lex.SetParseMode(mode)
+		}
+		return h.render(lex.Tokenize(), state, nil)
+	})
 }
 
-// renderTokens applies theme colors to a slice of tokens and returns the colorized string
+// highlightShortLines applies highlight to input, except for lines longer
+// than the maximum line length, which are copied unchanged. Runs of short
+// lines are highlighted together so the lexer keeps its context across them.
+// highlight gets each run and the offset in input where it starts.
+func (h *Highlighter) highlightShortLines(input string, highlight func(start int, text string) string) string {
+	h.mu.RLock()
+	maxLine := h.maxLine
+	h.mu.RUnlock()
+
+	if maxLine <= 0 || !hasLineLongerThan(input, maxLine) {
+		return highlight(0, input)
+	}
+
+	var buf bytes.Buffer
+	start := 0 // start of the current run of short lines
+	for pos := 0; pos < len(input); {
+		end := strings.IndexByte(input[pos:], '\n')
+		if end < 0 {
+			end = len(input)
+		} else {
+			end += pos + 1
+		}
+
+		if len(strings.TrimSuffix(input[pos:end], "\n")) > maxLine {
+			if start < pos {
+				buf.WriteString(highlight(start, input[start:pos]))
+			}
+			buf.WriteString(input[pos:end])
+			start = end
+		}
+		pos = end
+	}
+	if start < len(input) {
+		buf.WriteString(highlight(start, input[start:]))
+	}
+	return buf.String()
+}
+
+// hasLineLongerThan reports whether input has a line longer than n bytes
+func hasLineLongerThan(input string, n int) bool {
+	for len(input) > n {
+		i := strings.IndexByte(input, '\n')
+		if i < 0 || i > n {
+			return true
+		}
+		input = input[i+1:]
+	}
+	return false
+}
+
+// newLexer creates a lexer for input with the highlighter's lexer options
+func (h *Highlighter) newLexer(input string) *lexer.Lexer {
+	h.mu.RLock()
+	comments := h.comments
+	caseSensitive := h.caseSensitive
+	h.mu.RUnlock()
+
+	lex := lexer.New(input)
+	if comments != nil {
+		lex.SetCommentPrefixes(comments...)
+	}
+	lex.SetCaseSensitive(caseSensitive)
+	return lex
+}
+
+// renderTokens applies theme colors to a slice of tokens and returns the colorized string.
+// A color is only written when it differs from the one in effect, so runs of
+// same-colored tokens (and the spaces between them) share one escape sequence.
 func (h *Highlighter) renderTokens(tokens []lexer.Token) string {
+	return h.render(tokens, nil, nil)
+}
+
+// renderState is what rendering carries from one chunk of an input to the
+// next (see Stream)
+type renderState struct {
+	// depth is the brace depth for indent guides
+	depth int
+}
+
+// render colorizes tokens like renderTokens, continuing from state (nil for a
+// fresh input) and updating it. If onToken is set, it is called for each
+// token with the byte range of its text in the colorized string.
+func (h *Highlighter) render(tokens []lexer.Token, state *renderState, onToken func(token lexer.Token, start, end int)) string {
+	theme := h.renderedTheme()
 	h.mu.RLock()
-	theme := h.theme
+	match, matchColor := h.match, h.matchColor
+	showTrailing := h.trailing
+	columnColors := h.columnColors
+	colorFunc := h.colorFunc
+	bands := h.percentBands
+	wrapWidth := h.wrapWidth
+	guides := h.guides
 	h.mu.RUnlock()
 
+	if state == nil {
+		state = &renderState{}
+	}
+	depth := state.depth
+
+	if wrapWidth > 0 {
+		tokens = wrapTokens(tokens, wrapWidth)
+	}
+
+	// Match spans and table columns are found on the visible text, so
+	// matches can cross token boundaries
+	var matchSpans [][]int
+	var tints *columnTints
+	if match != nil || len(columnColors) > 0 {
+		var text strings.Builder
+		for _, token := range tokens {
+			text.WriteString(token.Value)
+		}
+		if match != nil {
+			matchSpans = match.FindAllStringIndex(text.String(), -1)
+		}
+		tints = newColumnTints(text.String(), columnColors)
+	}
+
 	var buf bytes.Buffer
-	for _, token := range tokens {
+	current := ""
+	offset := 0
+	for i, token := range tokens {
 		color := theme.GetColor(token.Type)
-		if color != "" {
-			buf.WriteString(color)
-			buf.WriteString(token.Value)
-			buf.WriteString(Reset)
-		} else {
-			buf.WriteString(token.Value)
+		if token.Type == lexer.TokenPercentage {
+			color = bands.color(theme, token.Value, color)
+		}
+		if tint := tints.tint(token); tint != "" {
+			color = tint
+		}
+		if colorFunc != nil {
+			color = colorFunc(token, color)
 		}
+
+		parts := []textPiece{{text: token.Value}}
+		if showTrailing && token.Type == lexer.TokenText {
+			parts = splitTrailingWhitespace(token.Value, i == len(tokens)-1)
+		}
+		if guides && token.Type == lexer.TokenText && i+1 < len(tokens) {
+			// A line that closes a block gets the guides outside it
+			lineDepth := depth
+			if strings.HasPrefix(tokens[i+1].Value, "}") {
+				lineDepth--
+			}
+			parts = splitIndentGuide(parts, lineDepth, i == 0)
+		}
+
+		start := -1
+		for _, part := range parts {
+			pieces := splitMatches(part.text, offset, matchSpans)
+			if part.guide != "" {
+				pieces = []textPiece{{text: part.guide}}
+			}
+			for _, piece := range pieces {
+				style := color
+				if part.guide != "" {
+					style = indentGuideStyle
+				}
+				if part.trailing {
+					style += trailingWhitespaceStyle
+				}
+				if piece.matched {
+					style += matchColor
+				}
+
+				// Plain whitespace on the same line can stay inside the current run
+				keepRun := style == "" && current != "" && isInlineWhitespace(piece.text) && !styleShowsOnWhitespace(current)
+				if style != current && !keepRun {
+					if current != "" {
+						buf.WriteString(Reset)
+					}
+					buf.WriteString(style)
+					current = style
+				}
+				if start < 0 {
+					start = buf.Len()
+				}
+				buf.WriteString(piece.text)
+			}
+			offset += len(part.text)
+		}
+		if onToken != nil && start >= 0 {
+			onToken(token, start, buf.Len())
+		}
+
+		switch {
+		case !guides:
+		case token.Value == "{":
+			depth++
+		case token.Value == "}" && depth > 0:
+			depth--
+		}
+	}
+	if current != "" {
+		buf.WriteString(Reset)
 	}
+
+	state.depth = depth
 	return buf.String()
 }
 
+// trailingWhitespaceStyle is the dim background used to mark trailing whitespace
+const trailingWhitespaceStyle = "\033[48;5;238m"
+
+// textPiece is part of a token's value, marked if it lies inside a match span
+// or is trailing whitespace. An indentation piece drawn with indent guides
+// has the guide text to write in its place.
+type textPiece struct {
+	text     string
+	matched  bool
+	trailing bool
+	guide    string
+}
+
+// splitTrailingWhitespace splits a whitespace token so runs of spaces and tabs
+// that end a line are marked trailing. A run at the end of the token only ends
+// a line if the token is the last one (last).
+func splitTrailingWhitespace(value string, last bool) []textPiece {
+	if strings.Trim(value, " \t\r\n") != "" {
+		return []textPiece{{text: value}}
+	}
+
+	var pieces []textPiece
+	start, runStart := 0, -1
+	for i := 0; i <= len(value); i++ {
+		if i < len(value) && (value[i] == ' ' || value[i] == '\t') {
+			if runStart < 0 {
+				runStart = i
+			}
+			continue
+		}
+		endsLine := (i == len(value) && last) || (i < len(value) && (value[i] == '\n' || value[i] == '\r'))
+		if runStart >= 0 && endsLine {
+			if runStart > start {
+				pieces = append(pieces, textPiece{text: value[start:runStart]})
+			}
+			pieces = append(pieces, textPiece{text: value[runStart:i], trailing: true})
+			start = i
+		}
+		runStart = -1
+	}
+	if start < len(value) || len(pieces) == 0 {
+		pieces = append(pieces, textPiece{text: value[start:]})
+	}
+	return pieces
+}
+
+// splitMatches splits a token value that starts at offset in the rendered text
+// at the boundaries of the (sorted, non-overlapping) match spans.
+func splitMatches(value string, offset int, spans [][]int) []textPiece {
+	var pieces []textPiece
+	pos := 0
+	for _, span := range spans {
+		start, end := span[0]-offset, span[1]-offset
+		if start == end || end <= pos || start >= len(value) {
+			continue
+		}
+		start, end = max(start, pos), min(end, len(value))
+		if start > pos {
+			pieces = append(pieces, textPiece{text: value[pos:start]})
+		}
+		pieces = append(pieces, textPiece{text: value[start:end], matched: true})
+		pos = end
+	}
+	if pos < len(value) || len(pieces) == 0 {
+		pieces = append(pieces, textPiece{text: value[pos:]})
+	}
+	return pieces
+}
+
+// isInlineWhitespace checks if s consists only of spaces and tabs
+func isInlineWhitespace(s string) bool {
+	return s != "" && strings.Trim(s, " \t") == ""
+}
+
+// styleShowsOnWhitespace checks if a style is visible on spaces
+// (underline, reverse video or a background color)
+func styleShowsOnWhitespace(style string) bool {
+	return strings.Contains(style, Underline) ||
+		strings.Contains(style, Reverse) ||
+		strings.Contains(style, "\033[48;")
+}
+
 // HighlightLine highlights a single line (useful for streaming)
 func (h *Highlighter) HighlightLine(line string) string {
 	return h.Highlight(line)
 }
 
-// HighlightLines highlights multiple lines preserving line structure
+// HighlightLines highlights multiple lines preserving line structure. The
+// lines are treated as one input, like with a Stream.
 func (h *Highlighter) HighlightLines(lines []string) []string {
+	stream := h.NewStream()
 	result := make([]string, len(lines))
 	for i, line := range lines {
-		result[i] = h.Highlight(line)
+		result[i] = stream.Highlight(line)
 	}
 	return result
 }
@@ -174,6 +665,9 @@ var (
 		"ge-", "xe-", "et-", "ae", "lo0",
 		"family inet", "unit ", "vlan-id",
 		"ospf", "bgp", "neighbor", "group",
+		// Edit context lines ("[edit]") and configuration mode banners
+		"[edit", "entering configuration mode", "exiting configuration mode",
+		"configuration has been changed but not committed",
 	}
 
 	showIndicators = []string{
@@ -186,33 +680,78 @@ var (
 	}
 
 	commandPrefixes = []string{"set ", "delete ", "show ", "edit ", "request ", "##"}
+
+	// navigationLinePattern finds configuration mode navigation lines, which
+	// "| display set relative" blocks and edit sessions are interleaved with
+	// ("up 2", "top edit system", "up set disable")
+	navigationLinePattern = regexp.MustCompile(`(?im)^\s*(up\s+\d+|(up(\s+\d+)?|top)\s+(set|delete|edit|show)\s.*)\s*$`)
+
+	// bareNavigationLinePattern finds bare "up", "top" and "exit" lines,
+	// which plain text has too, so they only add to other indicators
+	bareNavigationLinePattern = regexp.MustCompile(`(?im)^\s*(up|top|exit)\s*$`)
+
+	// weakIPv4Pattern finds IPv4 addresses anywhere in the input (loose detection)
+	weakIPv4Pattern = regexp.MustCompile(`\b\d{1,3}(\.\d{1,3}){3}\b`)
 )
 
 // looksLikeJunOS performs a quick check to see if text appears to be JunOS config or show output
 func (h *Highlighter) looksLikeJunOS(input string) bool {
+	if looksLikeXML(input) {
+		return false
+	}
 	if h.isPromptLine(input) {
 		return true
 	}
 
+	h.mu.RLock()
+	threshold := h.threshold
+	h.mu.RUnlock()
+
 	lower := strings.ToLower(input)
+	score := h.indicatorScore(input, lower)
 
-	if h.hasConfigIndicators(lower) {
-		return true
+	switch threshold {
+	case DetectionStrict:
+		return score >= 2
+	case DetectionLoose:
+		return score >= 1 || h.hasWeakIndicators(input)
+	default:
+		return score >= 1
 	}
+}
 
-	if h.hasShowIndicators(lower) {
-		return true
+// indicatorScore counts the JunOS indicators present in the input
+func (h *Highlighter) indicatorScore(input, lower string) int {
+	score := countIndicators(lower, configIndicators) + countIndicators(lower, showIndicators)
+	if h.hasStructuralPatterns(input, lower) {
+		score++
+	}
+	if h.startsWithCommand(input) {
+		score++
+	}
+	if navigationLinePattern.MatchString(input) || (score > 0 && bareNavigationLinePattern.MatchString(input)) {
+		score++
 	}
+	return score
+}
 
-	if h.hasStructuralPatterns(input, lower) {
-		return true
+// countIndicators returns how many of the indicators appear in lower
+func countIndicators(lower string, indicators []string) int {
+	count := 0
+	for _, indicator := range indicators {
+		if strings.Contains(lower, indicator) {
+			count++
+		}
 	}
+	return count
+}
 
-	if h.startsWithCommand(input) {
+// hasWeakIndicators checks for patterns common in JunOS but also elsewhere
+func (h *Highlighter) hasWeakIndicators(input string) bool {
+	if weakIPv4Pattern.MatchString(input) {
 		return true
 	}
-
-	return false
+	return strings.Contains(input, "{") || strings.HasSuffix(strings.TrimSpace(input), ";")
 }
 
 // isPromptLine checks if the input looks like a JunOS CLI prompt
@@ -246,26 +785,6 @@ func (h *Highlighter) isPromptLine(input string) bool {
 	return validBefore && validAfter
 }
 
-// hasConfigIndicators checks for common JunOS config keywords/patterns
-func (h *Highlighter) hasConfigIndicators(lower string) bool {
-	for _, indicator := range configIndicators {
-		if strings.Contains(lower, indicator) {
-			return true
-		}
-	}
-	return false
-}
-
-// hasShowIndicators checks for show command output patterns
-func (h *Highlighter) hasShowIndicators(lower string) bool {
-	for _, indicator := range showIndicators {
-		if strings.Contains(lower, indicator) {
-			return true
-		}
-	}
-	return false
-}
-
 // hasStructuralPatterns checks for typical JunOS structure patterns
 func (h *Highlighter) hasStructuralPatterns(input, lower string) bool {
 	if !strings.Contains(input, "{\n") && !strings.Contains(input, ";") {
@@ -301,14 +820,15 @@ func isAlphanumericOrDash(ch byte) bool {
 
 // HighlightShowOutput highlights show command output specifically using show mode.
 func (h *Highlighter) HighlightShowOutput(input string) string {
-	if !h.IsEnabled() || input == "" {
+	if h.skipInput(input) {
 		return input
 	}
 
-	lex := lexer.New(input)
-	lex.SetParseMode(lexer.ParseModeShow)
-	tokens := lex.Tokenize()
-	return h.renderTokens(tokens)
+	return h.highlightShortLines(input, func(_ int, text string) string {
+		lex := h.newLexer(text)
+		lex.SetParseMode(lexer.ParseModeShow)
+		return h.renderTokens(lex.Tokenize())
+	})
 }
 
 // segment represents either an escape sequence or text content
@@ -324,8 +844,11 @@ const (
 	csiFinalStart = 0x40 // @ - start of final bytes
 	csiFinalEnd   = 0x7E // ~ - end of final bytes
 	csiIntermEnd  = 0x2F // / - end of intermediate bytes
+	escFinalStart = 0x30 // 0 - start of final bytes of non-CSI escape sequences
 	escapeChar    = '\033'
 	csiBracket    = '['
+	oscBracket    = ']'
+	bellChar      = '\007'
 )
 
 // isCSIParamByte checks if byte is a CSI parameter or intermediate byte (0x20-0x3F)
@@ -357,15 +880,29 @@ func skipCSISequence(input string, i int) int {
 	return i
 }
 
-// skipOtherEscapeSequence skips non-CSI escape sequences
-// Returns the new position after the sequence
+// skipOtherEscapeSequence skips non-CSI escape sequences starting at position i
+// (after \033). Returns the new position after the sequence.
 func skipOtherEscapeSequence(input string, i int) int {
+	// OSC (\033]...): runs until BEL or ST (\033\), or the end of input
+	if i < len(input) && input[i] == oscBracket {
+		for i++; i < len(input); i++ {
+			if input[i] == bellChar {
+				return i + 1
+			}
+			if input[i] == escapeChar && i+1 < len(input) && input[i+1] == '\\' {
+				return i + 2
+			}
+		}
+		return i
+	}
+
 	// Skip intermediate bytes (0x20-0x2F)
 	for i < len(input) && isCSIIntermediateByte(input[i]) {
 		i++
 	}
-	// Skip final byte
-	if i < len(input) {
+	// Skip the final byte (0x30-0x7E); anything else, such as another ESC,
+	// starts new input
+	if i < len(input) && input[i] >= escFinalStart && input[i] <= csiFinalEnd {
 		i++
 	}
 	return i
@@ -392,6 +929,18 @@ func extractSegments(input string) []segment {
 			segments = append(segments, segment{text: input[start:i], isEscape: true})
 			continue
 		}
+		if input[i] == escapeChar {
+			// Other escape sequences (OSC, keypad mode, a lone ESC at the end)
+			// pass through too, so they never end up inside a token
+			if textBuf.Len() > 0 {
+				segments = append(segments, segment{text: textBuf.String(), isEscape: false})
+				textBuf.Reset()
+			}
+			start := i
+			i = skipOtherEscapeSequence(input, i+1) // +1 to skip \033
+			segments = append(segments, segment{text: input[start:i], isEscape: true})
+			continue
+		}
 		textBuf.WriteByte(input[i])
 		i++
 	}
@@ -428,6 +977,86 @@ func StripANSI(input string) string {
 	return buf.String()
 }
 
+// Sanitize passes input through without jink coloring, keeping the colors the
+// router emitted but making sure they are balanced: a Reset is appended if the
+// input leaves a color open, and truncated escape sequences are dropped.
+func (h *Highlighter) Sanitize(input string) string {
+	if !HasANSI(input) {
+		return input
+	}
+
+	var buf bytes.Buffer
+	open := false // whether an SGR color or attribute is in effect
+	for _, seg := range extractSegments(input) {
+		if !seg.isEscape || seg.text[0] != escapeChar || len(seg.text) < 2 || seg.text[1] != csiBracket {
+			buf.WriteString(seg.text)
+			continue
+		}
+
+		// CSI sequence cut off before its final byte
+		if len(seg.text) == 2 || !isCSIFinalByte(seg.text[len(seg.text)-1]) {
+			continue
+		}
+		if seg.text[len(seg.text)-1] == 'm' {
+			open = sgrLeavesOpen(seg.text[2 : len(seg.text)-1])
+		}
+		buf.WriteString(seg.text)
+	}
+	if open {
+		buf.WriteString(Reset)
+	}
+	return buf.String()
+}
+
+// sgrLeavesOpen reports whether an SGR sequence with the given parameters
+// leaves a color or attribute in effect. Empty and "0" parameters reset; any
+// other parameter, including the arguments of extended colors
+// ("38;5;0", "48;2;0;0;0"), sets something.
+func sgrLeavesOpen(params string) bool {
+	open := false
+	fields := strings.Split(params, ";")
+	for i := 0; i < len(fields); i++ {
+		switch fields[i] {
+		case "38", "48", "58":
+			open = true
+			if i+1 < len(fields) && fields[i+1] == "2" {
+				i += 4
+			} else {
+				i += 2
+			}
+		default:
+			open = strings.Trim(fields[i], "0") != ""
+		}
+	}
+	return open
+}
+
+// stripSGR removes SGR (color/attribute) sequences, i.e. CSI sequences ending in 'm',
+// while leaving every other escape sequence untouched.
+func stripSGR(input string) string {
+	if !HasANSI(input) {
+		return input
+	}
+
+	var buf bytes.Buffer
+	i := 0
+
+	for i < len(input) {
+		if input[i] == escapeChar && i+1 < len(input) && input[i+1] == csiBracket {
+			end := skipCSISequence(input, i+2) // +2 to skip \033[
+			if input[end-1] != 'm' {
+				buf.WriteString(input[i:end])
+			}
+			i = end
+			continue
+		}
+		buf.WriteByte(input[i])
+		i++
+	}
+
+	return buf.String()
+}
+
 // HasANSI checks if the input contains ANSI escape codes
 func HasANSI(input string) bool {
 	return strings.Contains(input, "\033[")
@@ -1,8 +1,19 @@
 package highlighter
 
 import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"runtime"
 	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/lasseh/jink/lexer"
 )
@@ -178,6 +189,10 @@ func TestLooksLikeJunOS(t *testing.T) {
 		"## Last commit",
 		"ospf area 0.0.0.0",
 		"bgp group external",
+		"[edit]",
+		"[edit interfaces ge-0/0/0]",
+		"Entering configuration mode",
+		"The configuration has been changed but not committed",
 	}
 
 	for _, input := range positives {
@@ -403,6 +418,33 @@ func TestDefaultThemeIsTokyoNight(t *testing.T) {
 	}
 }
 
+func TestMonochromeTheme(t *testing.T) {
+	theme := ThemeByName("mono")
+	attributesOnly := regexp.MustCompile(`^(\033\[[1-7]m)*$`)
+	for tokenType := lexer.TokenText; tokenType <= lexer.TokenOID; tokenType++ {
+		if color := theme.GetColor(tokenType); !attributesOnly.MatchString(color) {
+			t.Errorf("%v: expected attributes only, got %q", tokenType, color)
+		}
+	}
+
+	if got := theme.GetColor(lexer.TokenCommand); got != Bold {
+		t.Errorf("Command = %q, want bold", got)
+	}
+	if got := theme.GetColor(lexer.TokenSection); !strings.Contains(got, Underline) {
+		t.Errorf("Section = %q, want underline", got)
+	}
+	for _, tokenType := range []lexer.TokenType{lexer.TokenStateGood, lexer.TokenStateBad, lexer.TokenStateWarning} {
+		if got := theme.GetColor(tokenType); !strings.Contains(got, Reverse) {
+			t.Errorf("%v = %q, want reverse", tokenType, got)
+		}
+	}
+
+	out := NewWithTheme(theme).HighlightForced("set interfaces ge-0/0/0 description \"uplink\"\n")
+	if colorCode := regexp.MustCompile(`\033\[(3|4|9|10)\d`); colorCode.MatchString(out) {
+		t.Errorf("expected no color codes, got %q", out)
+	}
+}
+
 func TestThemeSetColor(t *testing.T) {
 	theme := DefaultTheme()
 
@@ -414,6 +456,72 @@ func TestThemeSetColor(t *testing.T) {
 	}
 }
 
+func TestThemeHash(t *testing.T) {
+	hash := DefaultTheme().Hash()
+	if len(hash) != 16 {
+		t.Errorf("expected a 16 digit hash, got %q", hash)
+	}
+	if got := DefaultTheme().Hash(); got != hash {
+		t.Errorf("identical themes hash differently: %q and %q", hash, got)
+	}
+	if got := NewTheme(DefaultTheme().Palette()).Hash(); got != hash {
+		t.Errorf("rebuilt theme hashes differently: %q and %q", hash, got)
+	}
+
+	modified := DefaultTheme()
+	modified.SetColor(lexer.TokenCommand, Magenta)
+	if modified.Hash() == hash {
+		t.Error("expected a modified theme to hash differently")
+	}
+
+	seen := make(map[string]string)
+	for _, name := range ThemeNames() {
+		h := ThemeByName(name).Hash()
+		if other, ok := seen[h]; ok {
+			t.Errorf("themes %s and %s hash the same", name, other)
+		}
+		seen[h] = name
+	}
+}
+
+func TestThemeClone(t *testing.T) {
+	theme := DefaultTheme()
+	clone := theme.Clone()
+	clone.SetColor(lexer.TokenCommand, Magenta)
+	if theme.GetColor(lexer.TokenCommand) == Magenta {
+		t.Error("SetColor on a clone changed the original")
+	}
+	if clone.GetColor(lexer.TokenSection) != theme.GetColor(lexer.TokenSection) || clone.Palette() != theme.Palette() {
+		t.Error("expected the clone to keep the theme's colors")
+	}
+}
+
+func TestMinimalProfile(t *testing.T) {
+	theme := DefaultTheme()
+	minimal := MinimalProfile(theme)
+
+	for _, tokenType := range []lexer.TokenType{lexer.TokenCommand, lexer.TokenSection, lexer.TokenKeyword, lexer.TokenNumber} {
+		if got := minimal.GetColor(tokenType); got != "" {
+			t.Errorf("expected %v to be uncolored, got %q", tokenType, got)
+		}
+	}
+	for _, tokenType := range []lexer.TokenType{lexer.TokenStateGood, lexer.TokenStateBad, lexer.TokenIPv4, lexer.TokenInterface, lexer.TokenTimeDuration} {
+		if got, want := minimal.GetColor(tokenType), theme.GetColor(tokenType); got != want || got == "" {
+			t.Errorf("expected %v to keep %q, got %q", tokenType, want, got)
+		}
+	}
+	if theme.GetColor(lexer.TokenCommand) == "" {
+		t.Error("MinimalProfile changed the original theme")
+	}
+
+	h := NewWithTheme(minimal)
+	h.SetParseMode(lexer.ParseModeShow)
+	out := h.HighlightShowOutput("ge-0/0/0    up    down\n")
+	if !strings.Contains(out, theme.GetColor(lexer.TokenStateGood)+"up") {
+		t.Errorf("expected states to stay colored, got %q", out)
+	}
+}
+
 func TestThemeGetColorUnknown(t *testing.T) {
 	theme := DefaultTheme()
 
@@ -503,3 +611,1386 @@ set protocols ospf area 0.0.0.0 interface ge-0/0/0.0`
 		t.Errorf("content not preserved")
 	}
 }
+
+func TestHighlightForcedStripsIncomingColors(t *testing.T) {
+	h := New()
+
+	plain := "set interfaces ge-0/0/0 unit 0 family inet address 10.0.0.1/24"
+	// Router-colored version of the same line
+	colored := "\033[1;31mset\033[0m interfaces \033[32mge-0/0/0\033[0m unit 0 family inet address 10.0.0.1/24"
+
+	result := h.HighlightForced(colored)
+
+	for _, code := range []string{"\033[1;31m", "\033[32m"} {
+		if strings.Contains(result, code) {
+			t.Errorf("router color %q should not leak into output: %q", code, result)
+		}
+	}
+	if result != h.HighlightForced(plain) {
+		t.Errorf("pre-colored input should highlight like plain input:\n got:  %q\n want: %q", result, h.HighlightForced(plain))
+	}
+}
+
+func TestHighlightForcedKeepsCursorControlWhenStripping(t *testing.T) {
+	h := New()
+
+	result := h.HighlightForced("\033[K\033[31muser@router>\033[0m show route")
+
+	if !strings.HasPrefix(result, "\033[K") {
+		t.Errorf("cursor control should be preserved, got %q", result)
+	}
+	if strings.Contains(result, "\033[31m") {
+		t.Errorf("router color should be stripped, got %q", result)
+	}
+}
+
+func TestHighlightLegend(t *testing.T) {
+	h := New()
+	legend := h.HighlightLegend()
+
+	theme := DefaultTheme()
+	entries := map[string]lexer.TokenType{
+		"Command":    lexer.TokenCommand,
+		"Section":    lexer.TokenSection,
+		"Interface":  lexer.TokenInterface,
+		"State good": lexer.TokenStateGood,
+		"State bad":  lexer.TokenStateBad,
+	}
+	for name, tokenType := range entries {
+		if !strings.Contains(legend, theme.GetColor(tokenType)+name) {
+			t.Errorf("legend should contain %q colored with its theme color", name)
+		}
+	}
+
+	lines := strings.Split(strings.TrimSuffix(StripANSI(legend), "\n"), "\n")
+	if len(lines) != len(legendEntries) {
+		t.Errorf("expected %d legend lines, got %d", len(legendEntries), len(lines))
+	}
+}
+
+func TestHighlightLegendDisabled(t *testing.T) {
+	h := New()
+	h.Disable()
+
+	if HasANSI(h.HighlightLegend()) {
+		t.Error("legend should not contain ANSI codes when highlighting is disabled")
+	}
+}
+
+func TestDetectionThreshold(t *testing.T) {
+	tests := []struct {
+		name   string
+		input  string
+		loose  bool
+		normal bool
+		strict bool
+	}{
+		{"single indicator", "ge-0/0/0 is the uplink", true, true, false},
+		{"weak indicator only", "gateway is 10.1.1.1", true, false, false},
+		{"plain text", "Hello world", false, false, false},
+		{"strong config", "set interfaces ge-0/0/0 unit 0", true, true, true},
+		{"prompt", "user@router> ", true, true, true},
+	}
+
+	levels := map[DetectionThreshold]string{
+		DetectionLoose:  "loose",
+		DetectionNormal: "normal",
+		DetectionStrict: "strict",
+	}
+
+	for _, tt := range tests {
+		expected := map[DetectionThreshold]bool{
+			DetectionLoose:  tt.loose,
+			DetectionNormal: tt.normal,
+			DetectionStrict: tt.strict,
+		}
+		for level, name := range levels {
+			t.Run(tt.name+"/"+name, func(t *testing.T) {
+				h := New()
+				h.SetDetectionThreshold(level)
+				highlighted := HasANSI(h.Highlight(tt.input))
+				if highlighted != expected[level] {
+					t.Errorf("%s detection of %q: highlighted=%v, want %v", name, tt.input, highlighted, expected[level])
+				}
+			})
+		}
+	}
+}
+
+// visibleStyles returns, for each non-whitespace character of ANSI-colored
+// text, the SGR sequences in effect when it is printed
+func visibleStyles(s string) []string {
+	var styles []string
+	active := ""
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\033' && i+1 < len(s) && s[i+1] == '[' {
+			end := skipCSISequence(s, i+2)
+			code := s[i:end]
+			if code == Reset {
+				active = ""
+			} else {
+				active += code
+			}
+			i = end - 1
+			continue
+		}
+		if s[i] != ' ' && s[i] != '\t' && s[i] != '\n' {
+			styles = append(styles, active)
+		}
+	}
+	return styles
+}
+
+func TestRenderTokensDeduplicatesColors(t *testing.T) {
+	h := New()
+	input := `protocols {
+    bgp {
+        group internal {
+            type internal;
+            local-address 10.255.255.1;
+            neighbor 10.255.255.2;
+            neighbor 10.255.255.3;
+        }
+    }
+}
+policy-options {
+    prefix-list internal-networks {
+        192.168.0.0/16;
+        10.0.0.0/8;
+    }
+    community my-community members [ 65001:100 65001:200 ];
+}`
+
+	tokens := lexer.New(input).Tokenize()
+
+	// Naive rendering: every colored token wrapped in its own color + reset
+	theme := DefaultTheme()
+	var naive strings.Builder
+	for _, tok := range tokens {
+		if color := theme.GetColor(tok.Type); color != "" {
+			naive.WriteString(color + tok.Value + Reset)
+		} else {
+			naive.WriteString(tok.Value)
+		}
+	}
+
+	optimized := h.renderTokens(tokens)
+
+	if len(optimized) >= naive.Len() {
+		t.Errorf("optimized output (%d bytes) should be smaller than naive output (%d bytes)", len(optimized), naive.Len())
+	}
+	if StripANSI(optimized) != input {
+		t.Error("optimized output should preserve content")
+	}
+
+	want := visibleStyles(naive.String())
+	got := visibleStyles(optimized)
+	if len(got) != len(want) {
+		t.Fatalf("visible character count differs: got %d, want %d", len(got), len(want))
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("visible character %d styled %q, want %q", i, got[i], want[i])
+		}
+	}
+}
+
+func TestRenderTokensClosesColorAtEndOfLine(t *testing.T) {
+	h := New()
+	result := h.renderTokens(lexer.New("neighbor 10.0.0.1\nneighbor 10.0.0.2").Tokenize())
+
+	for _, line := range strings.Split(result, "\n") {
+		if !strings.HasSuffix(line, Reset) {
+			t.Errorf("each line should end with a reset, got %q", line)
+		}
+	}
+}
+
+func TestRenderTokensUnderlineDoesNotSpanWhitespace(t *testing.T) {
+	theme := DefaultTheme()
+	theme.SetColor(lexer.TokenIPv4, Underline+Red)
+	h := NewWithTheme(theme)
+
+	l := lexer.New("10.0.0.1 10.0.0.2")
+	l.SetParseMode(lexer.ParseModeConfig)
+	result := h.renderTokens(l.Tokenize())
+
+	if !strings.Contains(result, Reset+" ") {
+		t.Errorf("underline should be reset before whitespace, got %q", result)
+	}
+}
+
+func TestDestructiveCommandColor(t *testing.T) {
+	theme := DefaultTheme()
+	if theme.GetColor(lexer.TokenCommandDestructive) == theme.GetColor(lexer.TokenCommand) {
+		t.Error("destructive commands should have a different color than other commands")
+	}
+
+	h := New()
+	result := h.Highlight("delete system services ftp")
+	if !strings.HasPrefix(result, theme.GetColor(lexer.TokenCommandDestructive)+"delete") {
+		t.Errorf("delete should use the destructive command color, got %q", result)
+	}
+}
+
+func TestSetParseMode(t *testing.T) {
+	input := "Peer State standby"
+
+	hl := New()
+	hl.SetParseMode(lexer.ParseModeShow)
+	show := hl.HighlightForced(input)
+	want := hl.theme.GetColor(lexer.TokenStateNeutral) + "standby"
+	if !strings.Contains(show, want) {
+		t.Errorf("expected show mode to color 'standby' as a state, got %q", show)
+	}
+
+	hl.SetParseMode(lexer.ParseModeConfig)
+	if config := hl.HighlightForced(input); config == show {
+		t.Error("expected config mode to highlight differently from show mode")
+	}
+
+	hl.SetParseMode(lexer.ParseModeAuto)
+	if auto := hl.HighlightForced(input); auto != New().HighlightForced(input) {
+		t.Error("expected ParseModeAuto to restore auto-detection")
+	}
+}
+
+func TestSetHighlightPattern(t *testing.T) {
+	input := "set protocols bgp group ebgp-peers neighbor 10.0.0.1 description bgp-uplink"
+
+	h := New()
+	h.SetHighlightPattern(regexp.MustCompile("bgp"), "")
+	result := h.Highlight(input)
+
+	if got := StripANSI(result); got != input {
+		t.Fatalf("match highlighting should not change the text, got %q", got)
+	}
+
+	// Mark the visible (non-space) characters that fall inside a match
+	var want []bool
+	matched := make([]bool, len(input))
+	for _, span := range regexp.MustCompile("bgp").FindAllStringIndex(input, -1) {
+		for i := span[0]; i < span[1]; i++ {
+			matched[i] = true
+		}
+	}
+	for i := range input {
+		if input[i] != ' ' {
+			want = append(want, matched[i])
+		}
+	}
+
+	styles := visibleStyles(result)
+	if len(styles) != len(want) {
+		t.Fatalf("expected %d visible characters, got %d", len(want), len(styles))
+	}
+	count := 0
+	for i, style := range styles {
+		if got := strings.Contains(style, Reverse); got != want[i] {
+			t.Errorf("visible char %d: reverse=%v, want %v (result %q)", i, got, want[i], result)
+		}
+		if want[i] {
+			count++
+		}
+	}
+	if count != 9 {
+		t.Errorf("expected all 3 occurrences of bgp to be marked, got %d characters", count)
+	}
+}
+
+func TestSetHighlightPatternAcrossTokens(t *testing.T) {
+	h := New()
+	h.SetHighlightPattern(regexp.MustCompile("protocols bgp"), Underline)
+	result := h.HighlightForced("set protocols bgp")
+
+	if !strings.Contains(result, Underline+" ") {
+		t.Errorf("expected the space inside the match to be underlined, got %q", result)
+	}
+
+	h.SetHighlightPattern(nil, "")
+	if strings.Contains(h.HighlightForced("set protocols bgp"), Underline) {
+		t.Error("expected a nil pattern to turn match highlighting off")
+	}
+}
+
+func TestShowTrailingWhitespace(t *testing.T) {
+	input := "    set system  host-name r1  \t\nset system services ssh \n"
+
+	h := New()
+	if strings.Contains(h.HighlightForced(input), trailingWhitespaceStyle) {
+		t.Fatal("trailing whitespace should not be marked by default")
+	}
+
+	h.SetShowTrailingWhitespace(true)
+	result := h.HighlightForced(input)
+
+	if got := StripANSI(result); got != input {
+		t.Fatalf("marking should not change the text, got %q", got)
+	}
+	if n := strings.Count(result, trailingWhitespaceStyle); n != 2 {
+		t.Errorf("expected 2 marked trailing runs, got %d in %q", n, result)
+	}
+	for _, run := range []string{"  \t", " "} {
+		if !strings.Contains(result, trailingWhitespaceStyle+run+Reset+"\n") {
+			t.Errorf("expected trailing %q to be marked, got %q", run, result)
+		}
+	}
+	if strings.HasPrefix(result, trailingWhitespaceStyle) {
+		t.Error("leading whitespace should not be marked")
+	}
+	if strings.Contains(result, trailingWhitespaceStyle+"  host") {
+		t.Error("internal whitespace should not be marked")
+	}
+}
+
+func TestShowTrailingWhitespaceAtEndOfInput(t *testing.T) {
+	h := New()
+	h.SetShowTrailingWhitespace(true)
+
+	result := h.HighlightForced("set system host-name r1   ")
+	if !strings.HasSuffix(result, trailingWhitespaceStyle+"   "+Reset) {
+		t.Errorf("expected whitespace at the end of input to be marked, got %q", result)
+	}
+}
+
+func TestSetCommentPrefixes(t *testing.T) {
+	input := "! uplink to core\n"
+	comment := DefaultTheme().GetColor(lexer.TokenComment)
+
+	h := New()
+	if strings.Contains(h.HighlightForced(input), comment+"!") {
+		t.Error("expected ! not to start a comment by default")
+	}
+
+	h.SetCommentPrefixes("#", "!")
+	if result := h.HighlightForced(input); !strings.Contains(result, comment+"! uplink to core") {
+		t.Errorf("expected ! line to be colored as a comment, got %q", result)
+	}
+}
+
+func TestHighlightWithSpans(t *testing.T) {
+	input := "set interfaces ge-0/0/0 unit 0\nset protocols bgp group ebgp neighbor 10.0.0.1\n"
+
+	h := New()
+	result, lines := h.HighlightWithSpans(input)
+
+	if got := StripANSI(result); got != input {
+		t.Fatalf("expected text to be preserved, got %q", got)
+	}
+	if len(lines) != 3 {
+		t.Fatalf("expected spans for 3 lines (including the empty last one), got %d", len(lines))
+	}
+
+	want := [][]struct {
+		value string
+		typ   lexer.TokenType
+	}{
+		{{"set", lexer.TokenCommand}, {"interfaces", lexer.TokenSection}, {"ge-0/0/0", lexer.TokenInterface}, {"unit", lexer.TokenKeyword}, {"0", lexer.TokenUnit}},
+		{{"set", lexer.TokenCommand}, {"protocols", lexer.TokenSection}, {"bgp", lexer.TokenProtocol}, {"group", lexer.TokenKeyword}, {"ebgp", lexer.TokenIdentifier}, {"neighbor", lexer.TokenKeyword}, {"10.0.0.1", lexer.TokenIPv4}},
+	}
+
+	for i, line := range want {
+		if len(lines[i]) != len(line) {
+			t.Errorf("line %d: expected %d spans, got %d", i+1, len(line), len(lines[i]))
+			continue
+		}
+		for j, w := range line {
+			span := lines[i][j]
+			if got := result[span.Start:span.End]; got != w.value {
+				t.Errorf("line %d span %d: offsets point at %q, want %q", i+1, j, got, w.value)
+			}
+			if span.Type != w.typ {
+				t.Errorf("line %d span %d (%q): type %v, want %v", i+1, j, w.value, span.Type, w.typ)
+			}
+			if !strings.HasSuffix(result[:span.Start], h.theme.GetColor(span.Type)) && span.Type != lexer.TokenIdentifier {
+				t.Errorf("line %d span %d (%q): expected its color just before it", i+1, j, w.value)
+			}
+		}
+	}
+	if len(lines[2]) != 0 {
+		t.Errorf("expected no spans on the empty last line, got %v", lines[2])
+	}
+}
+
+func TestHighlightWithSpansDisabled(t *testing.T) {
+	h := New()
+	h.Disable()
+	result, lines := h.HighlightWithSpans("set system host-name r1")
+	if result != "set system host-name r1" || lines != nil {
+		t.Errorf("expected unchanged input and nil spans when disabled, got %q %v", result, lines)
+	}
+}
+
+func TestHighlightWithSpansSkipsColoredInput(t *testing.T) {
+	input := "\033[1mset\033[0m system host-name r1"
+
+	h := New()
+	h.SetSkipIfColored(true)
+	result, lines := h.HighlightWithSpans(input)
+	if result != input || lines != nil {
+		t.Errorf("expected colored input unchanged with nil spans, got %q %v", result, lines)
+	}
+}
+
+func TestHighlightWithSpansMaxLineLength(t *testing.T) {
+	long := "set system host-name " + strings.Repeat("r", 100)
+	input := "set system host-name r1\n" + long + "\nset interfaces ge-0/0/0 unit 0\n"
+
+	h := New()
+	h.SetMaxLineLength(80)
+	result, lines := h.HighlightWithSpans(input)
+
+	if !strings.Contains(result, "\n"+long+"\n") {
+		t.Errorf("expected the long line to be copied unchanged, got %q", result)
+	}
+	if len(lines) != 4 {
+		t.Fatalf("expected spans for 4 lines, got %d", len(lines))
+	}
+	if len(lines[1]) != 0 {
+		t.Errorf("expected no spans on the long line, got %v", lines[1])
+	}
+	if len(lines[0]) == 0 || len(lines[2]) == 0 {
+		t.Fatalf("expected spans on the short lines, got %v", lines)
+	}
+	if first := lines[0][0]; result[first.Start:first.End] != "set" {
+		t.Errorf("line 1: first span points at %q, want \"set\"", result[first.Start:first.End])
+	}
+	last := lines[2][len(lines[2])-1]
+	if got := result[last.Start:last.End]; got != "0" || last.Type != lexer.TokenUnit {
+		t.Errorf("line 3: last span is %q (%v), want unit 0", got, last.Type)
+	}
+}
+
+func FuzzHighlight(f *testing.F) {
+	seeds := []string{
+		"set interfaces ge-0/0/0 unit 0 family inet address 192.168.1.1/24",
+		"user@router> show bgp summary\n",
+		"\033[1mbold\033[0m set system host-name r1",
+		"\033[K\033[?1h\033=user@router# ",
+		"set system host-name r1\033",
+		"set system host-name r1\033[",
+		"set system host-name r1\033[38;5",
+		"\033]0;title\007show route",
+		"\033\033[m",
+	}
+	for _, seed := range seeds {
+		f.Add(seed)
+	}
+
+	f.Fuzz(func(t *testing.T, input string) {
+		h := New()
+		want := StripANSI(input)
+		for name, out := range map[string]string{
+			"Highlight":           h.Highlight(input),
+			"HighlightForced":     h.HighlightForced(input),
+			"HighlightShowOutput": h.HighlightShowOutput(want),
+		} {
+			if got := StripANSI(out); got != want {
+				t.Fatalf("%s changed the visible text\ninput: %q\n  got: %q\n want: %q", name, input, got, want)
+			}
+		}
+	})
+}
+
+func TestStripANSIOtherSequences(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{"OSC title with BEL", "\033]0;router\007show route", "show route"},
+		{"OSC title with ST", "\033]0;router\033\\show route", "show route"},
+		{"unterminated OSC", "show route\033]0;rou", "show route"},
+		{"lone ESC at end", "show route\033", "show route"},
+		{"ESC before CSI", "\033\033[1mshow", "show"},
+		{"truncated CSI", "show route\033[38;5", "show route"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := StripANSI(tt.input); got != tt.want {
+				t.Errorf("StripANSI(%q) = %q, want %q", tt.input, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHighlightForcedPassesOtherEscapesThrough(t *testing.T) {
+	input := "\033]0;router\007set system host-name r1\033"
+	result := New().HighlightForced(input)
+
+	if !strings.HasPrefix(result, "\033]0;router\007") {
+		t.Errorf("expected the OSC sequence to pass through first, got %q", result)
+	}
+	if !strings.HasSuffix(result, Reset+"\033") {
+		t.Errorf("expected the lone ESC to stay after the colored text, got %q", result)
+	}
+}
+
+func TestSetColorDepth256(t *testing.T) {
+	h := NewWithTheme(TokyoNightTheme())
+	if h.ColorDepth() != ColorDepthTrueColor {
+		t.Fatalf("default ColorDepth() = %v, want truecolor", h.ColorDepth())
+	}
+
+	h.SetColorDepth(ColorDepth256)
+	if h.ColorDepth() != ColorDepth256 {
+		t.Fatalf("ColorDepth() = %v, want 256", h.ColorDepth())
+	}
+
+	result := h.HighlightForced("set system host-name router1;")
+	if strings.Contains(result, "38;2;") {
+		t.Errorf("256-color output contains RGB codes: %q", result)
+	}
+	if !strings.Contains(result, "38;5;") {
+		t.Errorf("256-color output has no 256-color codes: %q", result)
+	}
+	if StripANSI(result) != "set system host-name router1;" {
+		t.Errorf("text changed: %q", StripANSI(result))
+	}
+
+	// Back to true color restores RGB output
+	h.SetColorDepth(ColorDepthTrueColor)
+	if result := h.HighlightForced("set system"); !strings.Contains(result, "38;2;") {
+		t.Errorf("truecolor output has no RGB codes: %q", result)
+	}
+}
+
+func TestSetColorAfterColorDepth256(t *testing.T) {
+	theme := TokyoNightTheme()
+	h := NewWithTheme(theme)
+	h.SetColorDepth(ColorDepth256)
+
+	// Changing the theme after the depth is set still takes effect, degraded
+	theme.SetColor(lexer.TokenCommand, "\033[38;2;255;0;0m")
+	result := h.HighlightForced("set system host-name router1;")
+	if !strings.HasPrefix(result, "\033[38;5;196mset") {
+		t.Errorf("expected the new command color in 256 colors, got %q", result)
+	}
+	if strings.Contains(result, "38;2;") {
+		t.Errorf("256-color output contains RGB codes: %q", result)
+	}
+}
+
+func TestThemeColorDepth(t *testing.T) {
+	tests := []struct {
+		name  string
+		theme *Theme
+		want  ColorDepth
+	}{
+		{"tokyonight", TokyoNightTheme(), ColorDepthTrueColor},
+		{"solarized", SolarizedDarkTheme(), ColorDepth256},
+		{"monokai", MonokaiTheme(), ColorDepth256},
+		{"degraded", TokyoNightTheme().Degrade(ColorDepth256), ColorDepth256},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.theme.ColorDepth(); got != tt.want {
+				t.Errorf("ColorDepth() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDegradeKeeps256ColorTheme(t *testing.T) {
+	theme := SolarizedDarkTheme()
+	if theme.Degrade(ColorDepth256) != theme {
+		t.Error("Degrade() copied a theme that is already 256-color")
+	}
+}
+
+func TestWithCommentStyle(t *testing.T) {
+	comment := TokyoNightTheme().Palette().Comment
+	tests := []struct {
+		style CommentStyle
+		want  string
+	}{
+		{CommentStyleTheme, Italic + comment},
+		{CommentStyleDim, Dim + comment},
+		{CommentStyleNormal, comment},
+		{CommentStyleBold, Bold + comment},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.style.String(), func(t *testing.T) {
+			theme := TokyoNightTheme().WithCommentStyle(tt.style)
+			for _, tokenType := range []lexer.TokenType{lexer.TokenComment, lexer.TokenAnnotation} {
+				if got := theme.GetColor(tokenType); got != tt.want {
+					t.Errorf("%v color = %q, want %q", tokenType, got, tt.want)
+				}
+			}
+			if got, want := theme.GetColor(lexer.TokenCommand), TokyoNightTheme().GetColor(lexer.TokenCommand); got != want {
+				t.Errorf("Command color changed to %q, want %q", got, want)
+			}
+
+			out := NewWithTheme(theme).HighlightForced("# uplinks\n")
+			if !strings.Contains(out, tt.want+"# uplinks") {
+				t.Errorf("comment not rendered with %q: %q", tt.want, out)
+			}
+		})
+	}
+}
+
+func TestRGBTo256(t *testing.T) {
+	tests := []struct {
+		r, g, b int
+		want    int
+	}{
+		{0, 0, 0, 16},
+		{255, 255, 255, 231},
+		{255, 0, 0, 196},
+		{0, 255, 0, 46},
+		{0, 0, 255, 21},
+		{128, 128, 128, 244},
+		{95, 135, 175, 67},
+	}
+
+	for _, tt := range tests {
+		if got := rgbTo256(tt.r, tt.g, tt.b); got != tt.want {
+			t.Errorf("rgbTo256(%d, %d, %d) = %d, want %d", tt.r, tt.g, tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestDetectColorDepth(t *testing.T) {
+	tests := []struct {
+		colorterm string
+		want      ColorDepth
+	}{
+		{"truecolor", ColorDepthTrueColor},
+		{"24bit", ColorDepthTrueColor},
+		{"TrueColor", ColorDepthTrueColor},
+		{"", ColorDepth256},
+		{"yes", ColorDepth256},
+	}
+
+	for _, tt := range tests {
+		getenv := func(string) string { return tt.colorterm }
+		if got := detectColorDepth(getenv); got != tt.want {
+			t.Errorf("COLORTERM=%q: got %v, want %v", tt.colorterm, got, tt.want)
+		}
+	}
+}
+
+func TestMaxLineLengthPassesLongLineThrough(t *testing.T) {
+	blob := strings.Repeat("QUJDRA== ", 1024*1024/9)
+	input := "set system host-name r1\n" + blob + "\nset interfaces ge-0/0/0\n"
+
+	h := New()
+	var result string
+	done := make(chan struct{})
+	go func() {
+		result = h.HighlightForced(input)
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("highlighting a 1MB line took too long")
+	}
+
+	if !strings.Contains(result, "\n"+blob+"\n") {
+		t.Error("expected the long line to be passed through unchanged")
+	}
+	if StripANSI(result) != input {
+		t.Error("expected the text to be unchanged")
+	}
+	if !strings.HasPrefix(result, "\033[") || !strings.HasSuffix(result, "\033[0m\n") {
+		t.Errorf("expected the short lines around it to be highlighted, got %q...", result[:40])
+	}
+}
+
+func TestSetMaxLineLength(t *testing.T) {
+	input := "set interfaces ge-0/0/0 description uplink"
+
+	h := New()
+	h.SetMaxLineLength(10)
+	if result := h.HighlightForced(input); result != input {
+		t.Errorf("expected a line over the limit unchanged, got %q", result)
+	}
+	if result := h.HighlightShowOutput(input); result != input {
+		t.Errorf("expected a show line over the limit unchanged, got %q", result)
+	}
+
+	h.SetMaxLineLength(0)
+	if result := h.HighlightForced(input); !HasANSI(result) {
+		t.Errorf("expected highlighting with no limit, got %q", result)
+	}
+}
+
+func TestNewConsoleWriter(t *testing.T) {
+	// Outside Windows the file is used directly
+	if runtime.GOOS != "windows" {
+		if w := NewConsoleWriter(os.Stdout); w != io.Writer(os.Stdout) {
+			t.Errorf("expected os.Stdout back, got %T", w)
+		}
+	}
+}
+
+func TestConsoleWriter(t *testing.T) {
+	const base = 0x07 // light gray on black
+
+	var text strings.Builder
+	var attrs []uint16
+	w := newConsoleWriter(&text, base, func(attr uint16) error {
+		attrs = append(attrs, attr)
+		return nil
+	})
+
+	input := Bold + Color256(196) + "set" + Reset + " " + RGB(0, 0, 255) + "ge-0/0/0" + Reset + "\033[2K\n"
+	if _, err := w.Write([]byte(input)); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+
+	if text.String() != "set ge-0/0/0\n" {
+		t.Errorf("expected plain text, got %q", text.String())
+	}
+
+	want := []uint16{
+		base | consoleIntensity, // bold
+		consoleRed | consoleIntensity,
+		base,
+		consoleBlue | consoleIntensity,
+		base,
+	}
+	if len(attrs) != len(want) {
+		t.Fatalf("got attributes %#x, want %#x", attrs, want)
+	}
+	for i := range want {
+		if attrs[i] != want[i] {
+			t.Errorf("attribute %d: got %#x, want %#x", i, attrs[i], want[i])
+		}
+	}
+}
+
+func TestApplySGR(t *testing.T) {
+	const base = 0x07
+	tests := []struct {
+		params string
+		want   uint16
+	}{
+		{"0", base},
+		{"", base},
+		{"31", consoleRed},
+		{"92", consoleGreen | consoleIntensity},
+		{"44", base | consoleBlue<<consoleBgShift},
+		{"38;5;15", consoleFgMask},
+		{"48;2;0;128;0", base | consoleGreen<<consoleBgShift},
+		{"7", base << consoleBgShift},
+		{"38;5;-1", base},
+		{"38;5;256", base},
+		{"48;5;-8;31", consoleRed},
+		{"38;2;0;-1;300", base},
+	}
+
+	for _, tt := range tests {
+		if got := applySGR(base, base, tt.params); got != tt.want {
+			t.Errorf("applySGR(%q) = %#x, want %#x", tt.params, got, tt.want)
+		}
+	}
+}
+
+func TestSetSkipIfColored(t *testing.T) {
+	input := "set interfaces ge-0/0/0 unit 0 family inet address 10.0.0.1/24\n"
+
+	h := New()
+	h.SetSkipIfColored(true)
+
+	once := h.Highlight(input)
+	if !HasANSI(once) {
+		t.Fatalf("expected plain input to be highlighted, got %q", once)
+	}
+	if twice := h.Highlight(once); twice != once {
+		t.Errorf("Highlight: expected highlighted input unchanged, got %q", twice)
+	}
+	if twice := h.HighlightForced(once); twice != once {
+		t.Errorf("HighlightForced: expected highlighted input unchanged, got %q", twice)
+	}
+	if twice := h.HighlightShowOutput(once); twice != once {
+		t.Errorf("HighlightShowOutput: expected highlighted input unchanged, got %q", twice)
+	}
+
+	// Without the option the input is stripped and highlighted again
+	h.SetSkipIfColored(false)
+	colored := "\033[31m" + input
+	if again := h.HighlightForced(colored); strings.Contains(again, "\033[31m") {
+		t.Errorf("expected the incoming color to be replaced, got %q", again)
+	}
+}
+
+func TestHighlightFile(t *testing.T) {
+	const config = `set system host-name core-01
+set interfaces ge-0/0/0 unit 0 family inet address 10.0.0.1/30
+set protocols bgp group external neighbor 10.0.0.2 peer-as 65001
+`
+	path := filepath.Join(t.TempDir(), "router.conf")
+	if err := os.WriteFile(path, []byte(config), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := HighlightFile(path, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	h := New()
+	h.SetParseMode(lexer.ParseModeConfig)
+	if want := h.HighlightForced(config); got != want {
+		t.Errorf("HighlightFile() = %q, want %q", got, want)
+	}
+}
+
+func TestHighlightFileLarge(t *testing.T) {
+	var sb strings.Builder
+	for sb.Len() < 3*fileChunkSize {
+		sb.WriteString("set interfaces ge-0/0/0 unit 100 description \"customer uplink\"\n")
+	}
+	path := filepath.Join(t.TempDir(), "large.conf")
+	if err := os.WriteFile(path, []byte(sb.String()), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := HighlightFile(path, DefaultTheme())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !HasANSI(got) {
+		t.Error("expected the file to be highlighted")
+	}
+	if StripANSI(got) != sb.String() {
+		t.Error("expected the highlighted file to keep its text across chunks")
+	}
+}
+
+func TestHighlightFileModes(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    lexer.ParseMode
+	}{
+		{"config", "system {\n    host-name r1;\n}\n", lexer.ParseModeConfig},
+		{"show", "Peer                     AS      InPkt     OutPkt    OutQ   Flaps Last Up/Dwn State\n10.0.0.1              65001      12345      12340       0       2     1w2d3h Establ\n", lexer.ParseModeShow},
+		{"diff", "[edit interfaces ge-0/0/0]\n-   description old;\n+   description new;\n", lexer.ParseModeConfig},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := detectFileMode(tt.content); got != tt.want {
+				t.Errorf("detectFileMode() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHighlightFileMissing(t *testing.T) {
+	_, err := HighlightFile(filepath.Join(t.TempDir(), "missing.conf"), nil)
+	if !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("expected a not-exist error, got %v", err)
+	}
+}
+
+func TestSanitize(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{"plain", "ge-0/0/0 up up\n", "ge-0/0/0 up up\n"},
+		{"balanced", "\033[32mup\033[0m\n", "\033[32mup\033[0m\n"},
+		{"unbalanced", "\033[31mdown\n", "\033[31mdown\n" + Reset},
+		{"short reset", "\033[1;31mdown\033[m", "\033[1;31mdown\033[m"},
+		{"set after reset", "\033[0;33mwarn", "\033[0;33mwarn" + Reset},
+		{"256 color black", "\033[38;5;0mtext", "\033[38;5;0mtext" + Reset},
+		{"rgb black", "\033[38;2;0;0;0mtext", "\033[38;2;0;0;0mtext" + Reset},
+		{"truncated", "\033[31mdown\033[3", "\033[31mdown" + Reset},
+		{"cursor control", "\033[2Kline\033[0m", "\033[2Kline\033[0m"},
+	}
+
+	h := New()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := h.Sanitize(tt.input); got != tt.want {
+				t.Errorf("Sanitize(%q) = %q, want %q", tt.input, got, tt.want)
+			}
+		})
+	}
+}
+
+const interfaceTerseFixture = `Interface               Admin Link Proto    Local                 Remote
+ge-0/0/0                up    up
+ge-0/0/0.0              up    up   inet     203.0.113.1/30
+ge-0/0/1.0              up    down inet     192.168.1.1/24
+lo0.0                   up    up   inet     10.255.255.1/32
+`
+
+func TestGetColumns(t *testing.T) {
+	want := []int{0, 24, 30, 35, 44, 66}
+	got := GetColumns(interfaceTerseFixture)
+	if len(got) != len(want) {
+		t.Fatalf("GetColumns() = %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("GetColumns() = %v, want %v", got, want)
+		}
+	}
+
+	if got := GetColumns("set system host-name r1\n"); got != nil {
+		t.Errorf("expected no columns in config, got %v", got)
+	}
+}
+
+func TestSetTabularColumnColors(t *testing.T) {
+	even, odd := Color256(250), Color256(245)
+	theme := DefaultTheme()
+
+	h := NewWithTheme(theme)
+	h.SetTabularColumnColors(even, odd)
+	out := h.HighlightShowOutput(interfaceTerseFixture)
+
+	// "inet" is an identifier in the fourth column (Proto)
+	if !strings.Contains(out, odd+"inet") {
+		t.Errorf("expected the Proto cells to be tinted, got %q", out)
+	}
+	// Semantic tokens keep their colors
+	if !strings.Contains(out, theme.GetColor(lexer.TokenStateGood)+"up") {
+		t.Errorf("expected states to keep their color, got %q", out)
+	}
+	if !strings.Contains(out, theme.GetColor(lexer.TokenIPv4Prefix)+"203.0.113.1/30") {
+		t.Errorf("expected prefixes to keep their color, got %q", out)
+	}
+
+	// Neutral cells alternate from column to column
+	out = h.HighlightShowOutput("Owner       Group     Site\nalpha       widget    oslo\nbeta        gadget    bergen\n")
+	for _, cell := range []string{even + "alpha", odd + "widget", even + "oslo", even + "beta", odd + "gadget", even + "bergen"} {
+		if !strings.Contains(out, cell) {
+			t.Errorf("expected %q in %q", cell, out)
+		}
+	}
+
+	h.SetTabularColumnColors()
+	if out := h.HighlightShowOutput(interfaceTerseFixture); strings.Contains(out, odd) {
+		t.Error("expected no tints after turning tinting off")
+	}
+}
+
+func TestSetColorFunc(t *testing.T) {
+	theme := DefaultTheme()
+	h := NewWithTheme(theme)
+	h.SetParseMode(lexer.ParseModeConfig)
+	input := "set interfaces ge-0/0/0 unit 0\nset interfaces xe-0/0/1 unit 0\n"
+	plain := h.HighlightForced(input)
+
+	tenGig := Color256(208)
+	h.SetColorFunc(func(token lexer.Token, defaultColor string) string {
+		if token.Type == lexer.TokenInterface && strings.HasPrefix(token.Value, "xe-") {
+			return tenGig
+		}
+		return defaultColor
+	})
+	out := h.HighlightForced(input)
+
+	if !strings.Contains(out, tenGig+"xe-0/0/1") {
+		t.Errorf("expected xe- interfaces in the color func's color, got %q", out)
+	}
+	if !strings.Contains(out, theme.GetColor(lexer.TokenInterface)+"ge-0/0/0") {
+		t.Errorf("expected other interfaces to keep the theme color, got %q", out)
+	}
+
+	h.SetColorFunc(nil)
+	if out := h.HighlightForced(input); out != plain {
+		t.Errorf("expected a nil color func to restore theme colors, got %q", out)
+	}
+}
+
+func TestHighlightEditContextLine(t *testing.T) {
+	theme := DefaultTheme()
+	h := NewWithTheme(theme)
+	for _, input := range []string{"[edit]\n", "[edit interfaces ge-0/0/0]\n", "[edit protocols bgp]"} {
+		out := h.Highlight(input)
+		if !strings.HasPrefix(out, theme.GetColor(lexer.TokenDiffContext)+"[edit") {
+			t.Errorf("expected %q to be highlighted as edit context, got %q", input, out)
+		}
+	}
+}
+
+// displaySetRelativeFixture is "show | display set relative" output with the
+// edit session navigation around it
+const displaySetRelativeFixture = `edit interfaces
+set ge-0/0/0 description "uplink"
+set ge-0/0/0 unit 0 family inet address 10.0.0.1/30
+up
+edit protocols bgp
+set group ebgp neighbor 10.0.0.2
+up 2
+top edit system
+top
+exit
+`
+
+func TestHighlightDisplaySetRelative(t *testing.T) {
+	theme := DefaultTheme()
+	h := NewWithTheme(theme)
+	command := theme.GetColor(lexer.TokenCommand)
+
+	// Every line but the bare navigation ones is detected on its own and
+	// starts with a command; those need the rest of the block
+	for _, line := range strings.SplitAfter(strings.TrimSuffix(displaySetRelativeFixture, "\n"), "\n") {
+		out := h.Highlight(line)
+		switch bare := bareNavigationLinePattern.MatchString(line); {
+		case bare && out != line:
+			t.Errorf("expected bare %q not to be detected on its own, got %q", line, out)
+		case !bare && !strings.HasPrefix(out, command):
+			t.Errorf("expected %q to start with a command, got %q", line, out)
+		}
+	}
+	if out := h.Highlight(displaySetRelativeFixture); !strings.Contains(out, command+"exit") || !strings.Contains(out, command+"top"+Reset+"\n") {
+		t.Errorf("expected bare navigation lines in the block to be commands, got %q", out)
+	}
+
+	l := lexer.New(displaySetRelativeFixture)
+	for _, tok := range l.Tokenize() {
+		switch tok.Value {
+		case "edit", "up", "top", "exit", "set":
+			if tok.Type != lexer.TokenCommand {
+				t.Errorf("line %d: expected %q to be a command, got %v", tok.Line, tok.Value, tok.Type)
+			}
+		case "ge-0/0/0":
+			if tok.Type != lexer.TokenInterface {
+				t.Errorf("line %d: expected %q to be an interface, got %v", tok.Line, tok.Value, tok.Type)
+			}
+		}
+	}
+
+	for _, text := range []string{"the system is up\n", "Chapter 1\nup\n", "Menu:\ntop\nexit\n"} {
+		if h.Highlight(text) != text {
+			t.Errorf("expected %q not to be detected", text)
+		}
+	}
+}
+
+func TestPercentageBands(t *testing.T) {
+	theme := DefaultTheme()
+	good := theme.GetColor(lexer.TokenPercentage)
+	warning := theme.GetColor(lexer.TokenStateWarning)
+	bad := theme.GetColor(lexer.TokenStateBad)
+
+	tests := []struct {
+		value string
+		want  string
+	}{
+		{"5%", good},
+		{"69.9%", good},
+		{"70%", warning},
+		{"85%", warning},
+		{"90%", bad},
+		{"95%", bad},
+		{"100%", bad},
+	}
+
+	h := NewWithTheme(theme)
+	for _, tt := range tests {
+		out := h.HighlightShowOutput("Filesystem  Size  Used  Avail  Capacity\n/dev/gpt/var  20G  17G  3G  " + tt.value + "\n")
+		if !strings.Contains(out, tt.want+tt.value) {
+			t.Errorf("expected %s in color %q, got %q", tt.value, tt.want, out)
+		}
+	}
+
+	// Custom bands
+	h.SetPercentageBands(&PercentageBands{Warning: 50, Bad: 60})
+	if out := h.HighlightShowOutput("Capacity 55%\n"); !strings.Contains(out, warning+"55%") {
+		t.Errorf("expected 55%% to be a warning with custom bands, got %q", out)
+	}
+
+	// No bands
+	h.SetPercentageBands(nil)
+	if out := h.HighlightShowOutput("Capacity 95%\n"); !strings.Contains(out, good+"95%") {
+		t.Errorf("expected 95%% in the percentage color without bands, got %q", out)
+	}
+}
+
+func TestPercentageBandsSystemStorage(t *testing.T) {
+	theme := DefaultTheme()
+	warning := theme.GetColor(lexer.TokenStateWarning)
+	bad := theme.GetColor(lexer.TokenStateBad)
+	input := "Filesystem              Size       Used      Avail  Capacity   Mounted on\n" +
+		"/dev/ada0s1a            7.0G       5.5G       1.5G       78%  /.mount/var\n" +
+		"procfs                  4.0K       4.0K         0B      100%  /proc\n"
+
+	h := NewWithTheme(theme)
+	out := h.HighlightShowOutput(input)
+	if !strings.Contains(out, warning+"78%") {
+		t.Errorf("expected 78%% to be a warning with the default bands, got %q", out)
+	}
+	if strings.Contains(out, bad+"100%") {
+		t.Errorf("expected an always-full pseudo filesystem not to be flagged, got %q", out)
+	}
+
+	h.SetPercentageBands(&PercentageBands{Warning: 80, Bad: 95})
+	if out := h.HighlightShowOutput(input); strings.Contains(out, warning+"78%") {
+		t.Errorf("expected custom bands to apply to filesystem capacity, got %q", out)
+	}
+}
+
+func TestHighlightBatch(t *testing.T) {
+	var inputs []string
+	for i := 0; i < 200; i++ {
+		switch i % 3 {
+		case 0:
+			inputs = append(inputs, fmt.Sprintf("set interfaces ge-0/0/%d unit 0 family inet address 10.0.%d.1/30", i, i))
+		case 1:
+			inputs = append(inputs, fmt.Sprintf("10.0.0.%d              65001      12345      12340       0       2     1w2d3h Establ", i))
+		default:
+			inputs = append(inputs, fmt.Sprintf("plain text line %d", i))
+		}
+	}
+
+	theme := DraculaTheme()
+	h := NewWithTheme(theme)
+	for _, workers := range []int{0, 1, 4, 500} {
+		got := HighlightBatch(inputs, theme, workers)
+		if len(got) != len(inputs) {
+			t.Fatalf("workers=%d: got %d results, want %d", workers, len(got), len(inputs))
+		}
+		for i, input := range inputs {
+			if want := h.Highlight(input); got[i] != want {
+				t.Errorf("workers=%d: result %d = %q, want %q", workers, i, got[i], want)
+			}
+		}
+	}
+
+	if got := HighlightBatch(nil, nil, 4); len(got) != 0 {
+		t.Errorf("expected no results for no inputs, got %v", got)
+	}
+}
+
+func TestSetWrapWidth(t *testing.T) {
+	theme := DefaultTheme()
+	h := NewWithTheme(theme)
+	comment := "# uplink to the provider edge in the north data center, circuit 4711\n"
+
+	// Off by default
+	if out := StripANSI(h.HighlightForced(comment)); out != comment {
+		t.Errorf("expected no wrapping by default, got %q", out)
+	}
+
+	h.SetWrapWidth(30)
+	out := h.HighlightForced(comment)
+	lines := strings.Split(strings.TrimSuffix(out, "\n"), "\n")
+	if len(lines) < 2 {
+		t.Fatalf("expected the comment to wrap, got %q", out)
+	}
+	for _, line := range lines {
+		if n := len(StripANSI(line)); n > 30 {
+			t.Errorf("line is %d columns, want at most 30: %q", n, StripANSI(line))
+		}
+		if !strings.HasPrefix(line, theme.GetColor(lexer.TokenComment)) {
+			t.Errorf("expected each wrapped line to start in the comment color, got %q", line)
+		}
+	}
+	if got := strings.Join(strings.Fields(StripANSI(out)), " "); got != strings.TrimSpace(comment) {
+		t.Errorf("wrapping changed the words: %q", got)
+	}
+
+	// Values are indented to where they started; other tokens are never broken
+	h.SetWrapWidth(40)
+	out = StripANSI(h.HighlightForced("set system host-name core-router-01\nset snmp location \"rack 12 row 4 in the north data center\"\n"))
+	want := "set system host-name core-router-01\nset snmp location \"rack 12 row 4 in the\n                  north data center\"\n"
+	if out != want {
+		t.Errorf("expected\n%s\ngot\n%s", want, out)
+	}
+}
+
+func TestNewWriter(t *testing.T) {
+	input := "set system host-name r1\nset interfaces ge-0/0/0 unit 0 family inet address 10.0.0.1/30\nplain text\nset protocols bgp group ebgp"
+	h := New()
+
+	var want strings.Builder
+	for _, line := range strings.SplitAfter(input, "\n") {
+		want.WriteString(h.Highlight(line))
+	}
+
+	var out bytes.Buffer
+	w := NewWriter(&out, h)
+	// Chunks split lines and words at arbitrary points
+	for rest := input; rest != ""; {
+		n := min(7, len(rest))
+		if written, err := w.Write([]byte(rest[:n])); err != nil || written != n {
+			t.Fatalf("Write() = %d, %v", written, err)
+		}
+		rest = rest[n:]
+	}
+	if strings.Contains(out.String(), "ebgp") {
+		t.Error("expected the unterminated last line to be held until Close")
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close() = %v", err)
+	}
+	if out.String() != want.String() {
+		t.Errorf("expected\n%q\ngot\n%q", want.String(), out.String())
+	}
+	if !strings.Contains(out.String(), "\033[") {
+		t.Error("expected highlighted output")
+	}
+
+	if _, err := fmt.Fprintf(w, "set system host-name r2\n"); !errors.Is(err, ErrWriterClosed) {
+		t.Errorf("expected ErrWriterClosed after Close, got %v", err)
+	}
+}
+
+// rpcReplyFixture is a NETCONF reply, as from "show interfaces terse | display xml"
+const rpcReplyFixture = `<rpc-reply xmlns:junos="http://xml.juniper.net/junos/21.4R0/junos">
+    <interface-information junos:style="terse">
+        <physical-interface>
+            <name>ge-0/0/0</name>
+            <!-- link is down -->
+            <oper-status>down</oper-status>
+        </physical-interface>
+    </interface-information>
+</rpc-reply>
+`
+
+func TestHighlightXML(t *testing.T) {
+	theme := DefaultTheme()
+	h := NewWithTheme(theme)
+	tag := theme.GetColor(lexer.TokenSection)
+	attr := theme.GetColor(lexer.TokenKeyword)
+
+	if h.looksLikeJunOS(rpcReplyFixture) {
+		t.Error("expected XML not to be detected as JunOS")
+	}
+
+	for _, highlight := range []func(string) string{h.Highlight, h.HighlightForced} {
+		out := highlight(rpcReplyFixture)
+		for _, want := range []string{
+			tag + "rpc-reply",
+			tag + "physical-interface",
+			attr + "xmlns:junos",
+			attr + "junos:style",
+			theme.GetColor(lexer.TokenString) + `"terse"`,
+			theme.GetColor(lexer.TokenComment) + "<!-- link is down -->",
+			Reset + "ge-0/0/0" + theme.GetColor(lexer.TokenBrace) + "</",
+		} {
+			if !strings.Contains(out, want) {
+				t.Errorf("expected %q in %q", want, out)
+			}
+		}
+		for _, unwanted := range []lexer.TokenType{lexer.TokenInterface, lexer.TokenStateBad} {
+			if strings.Contains(out, theme.GetColor(unwanted)+"ge-0/0/0") || strings.Contains(out, theme.GetColor(unwanted)+"down") {
+				t.Errorf("expected element content to stay uncolored, got %q", out)
+			}
+		}
+		if StripANSI(out) != rpcReplyFixture {
+			t.Errorf("expected only colors to be added, got %q", StripANSI(out))
+		}
+	}
+
+	// A single indented line, as piped input is highlighted line by line
+	if out := h.Highlight("    <name>ge-0/0/0</name>\n"); !strings.Contains(out, tag+"name") {
+		t.Errorf("expected an indented element to be colored as XML, got %q", out)
+	}
+	// Cursor control sequences around XML in PTY output are kept
+	input := "\r\033[K" + "    <name>ge-0/0/0</name>\033[K\r\n"
+	out := h.HighlightForced(input)
+	if !strings.Contains(out, "\r\033[K") || !strings.Contains(out, "\033[K\r\n") || !strings.Contains(out, tag+"name") {
+		t.Errorf("expected XML coloring with cursor sequences kept, got %q", out)
+	}
+	if StripANSI(out) != StripANSI(input) {
+		t.Errorf("expected only colors to be added, got %q", StripANSI(out))
+	}
+	detection := map[string]bool{
+		"<?xml version=\"1.0\"?>":              true,
+		"<rpc-reply>\n  <ok/>\n</rpc-reply>\n": true,
+		"<*> is a wildcard":                    false,
+		"    <ae1> {":                          false,
+		"<ge-0/0/*> {":                         false,
+		"a <b> c":                              false,
+		"<ae1> {\n    mtu 9192;\n}\n":          false,
+		"\n<ge-*> {\n    mtu 9192;\n}":         false,
+		"<ae1> mtu 9192;\n<ae2> mtu 1500;\n":   false,
+	}
+	for in, want := range detection {
+		if got := looksLikeXML(in); got != want {
+			t.Errorf("looksLikeXML(%q) = %v, want %v", in, got, want)
+		}
+	}
+}
+
+func TestSetIndentGuides(t *testing.T) {
+	input := "system {\n    host-name r1;\n    services {\n        ssh;\n    }\n}\ninterfaces {\n    ge-0/0/0 {\n        unit 0 {\n            family inet;\n        }\n    }\n}\n"
+	depths := []int{0, 1, 1, 2, 1, 0, 0, 1, 2, 3, 2, 1, 0}
+	unguide := strings.NewReplacer(indentGuideChar, " ")
+
+	h := New()
+	if out := h.Highlight(input); strings.Contains(out, indentGuideChar) {
+		t.Errorf("expected no guides by default, got %q", out)
+	}
+
+	h.SetIndentGuides(true)
+	check := func(name, out string) {
+		t.Helper()
+		plain := StripANSI(out)
+		for i, line := range strings.Split(strings.TrimSuffix(plain, "\n"), "\n") {
+			if got := strings.Count(line, indentGuideChar); got != depths[i] {
+				t.Errorf("%s: line %d %q: expected %d guides, got %d", name, i+1, line, depths[i], got)
+			}
+		}
+		if unguide.Replace(plain) != input {
+			t.Errorf("%s: expected guides to keep the indentation width, got %q", name, plain)
+		}
+		if !strings.Contains(out, indentGuideStyle+indentGuideChar) {
+			t.Errorf("%s: expected dim guides, got %q", name, out)
+		}
+	}
+	check("whole input", h.Highlight(input))
+
+	// Depth carries across the lines of a stream
+	stream := h.NewStream()
+	var out strings.Builder
+	for _, line := range strings.SplitAfter(input, "\n") {
+		out.WriteString(stream.HighlightForced(line))
+	}
+	check("line by line", out.String())
+
+	// but not from one Highlight call to the next, so a reused or shared
+	// Highlighter doesn't inherit a stale depth
+	h.Highlight("system {\n")
+	if out := h.Highlight("    host-name r1;\n"); strings.Contains(out, indentGuideChar) {
+		t.Errorf("expected a new input to start at depth 0, got %q", out)
+	}
+
+	total := 0
+	for _, depth := range depths {
+		total += depth
+	}
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 20; j++ {
+				if out := h.Highlight(input); unguide.Replace(StripANSI(out)) != input || strings.Count(StripANSI(out), indentGuideChar) != total {
+					t.Errorf("concurrent Highlight: unexpected guides in %q", StripANSI(out))
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func TestSetCaseSensitive(t *testing.T) {
+	theme := DefaultTheme()
+	h := NewWithTheme(theme)
+	h.SetParseMode(lexer.ParseModeConfig)
+	command := theme.GetColor(lexer.TokenCommand) + "SET"
+
+	if out := h.Highlight("SET SYSTEM HOST-NAME R1\n"); !strings.Contains(out, command) {
+		t.Errorf("expected upper case keywords to be colored by default, got %q", out)
+	}
+	h.SetCaseSensitive(true)
+	if out := h.Highlight("SET SYSTEM HOST-NAME R1\n"); strings.Contains(out, command) {
+		t.Errorf("expected upper case keywords not to be colored when case sensitive, got %q", out)
+	}
+}
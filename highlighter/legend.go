@@ -0,0 +1,88 @@
+package highlighter
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/lasseh/jink/lexer"
+)
+
+// legendEntry describes one token category shown in the legend
+type legendEntry struct {
+	name      string
+	tokenType lexer.TokenType
+	examples  string
+}
+
+// legendEntries lists the token categories in the order they appear in the legend
+var legendEntries = []legendEntry{
+	// Config
+	{"Command", lexer.TokenCommand, "set, show, commit"},
+	{"Destructive", lexer.TokenCommandDestructive, "delete, deactivate"},
+	{"Section", lexer.TokenSection, "system, interfaces, protocols"},
+	{"Protocol", lexer.TokenProtocol, "ospf, bgp, inet, tcp"},
+	{"Action", lexer.TokenAction, "accept, reject, discard"},
+	{"Keyword", lexer.TokenKeyword, "host-name, unit, family, neighbor"},
+	{"Interface", lexer.TokenInterface, "ge-0/0/0, ae0, lo0.0, irb.100"},
+	{"IP address", lexer.TokenIPv4, "192.168.1.1, 10.0.0.0/24, 2001:db8::1"},
+	{"MAC address", lexer.TokenMAC, "00:11:22:33:44:55"},
+	{"Path", lexer.TokenPath, "/var/tmp/config, ftp://host/file"},
+	{"Number", lexer.TokenNumber, "100, 1000m, 10g"},
+	{"String", lexer.TokenString, `"quoted string"`},
+	{"Value", lexer.TokenValue, "description text, host names"},
+	{"AS number", lexer.TokenASN, "AS65000"},
+	{"Community", lexer.TokenCommunity, "65000:100"},
+	{"Wildcard", lexer.TokenWildcard, "<*>, *"},
+	{"Comment", lexer.TokenComment, "# comment, /* comment */"},
+
+	// Show output
+	{"State good", lexer.TokenStateGood, "up, Establ, Full, Master"},
+	{"State bad", lexer.TokenStateBad, "down, Idle, Active, Connect"},
+	{"State warning", lexer.TokenStateWarning, "Init, 2Way, ExStart"},
+	{"State neutral", lexer.TokenStateNeutral, "inactive, standby, backup"},
+	{"Column header", lexer.TokenColumnHeader, "Peer, State, Interface"},
+	{"Duration", lexer.TokenTimeDuration, "1w2d3h, 0:45:30"},
+	{"Percentage", lexer.TokenPercentage, "50%, 99.9%"},
+	{"Byte size", lexer.TokenByteSize, "1.5G, 500M"},
+	{"Route protocol", lexer.TokenRouteProtocol, "[BGP/170], [OSPF/10]"},
+	{"Table name", lexer.TokenTableName, "inet.0, mpls.0"},
+	{"IS-IS level", lexer.TokenLevel, "L1, L2, L1L2"},
+	{"SNMP OID", lexer.TokenOID, "1.3.6.1.2.1.1.3, IF-MIB::ifDescr"},
+	{"Timestamp", lexer.TokenTimestamp, "2024-01-15 10:30:00 UTC"},
+
+	// Prompt and diff
+	{"Prompt user", lexer.TokenPromptUser, "admin@"},
+	{"Prompt host", lexer.TokenPromptHostOper, "router> (operational)"},
+	{"Prompt host", lexer.TokenPromptHostConf, "router# (configuration)"},
+	{"Diff add", lexer.TokenDiffAdd, "+ added line"},
+	{"Diff remove", lexer.TokenDiffRemove, "- removed line"},
+	{"Diff context", lexer.TokenDiffContext, "[edit interfaces]"},
+}
+
+// legendNameWidth is the column width of category names in the legend
+const legendNameWidth = 16
+
+// HighlightLegend returns a legend mapping each token category to its color
+// in the current theme, one category per line with example values.
+func (h *Highlighter) HighlightLegend() string {
+	theme := h.renderedTheme()
+	h.mu.RLock()
+	enabled := h.enabled
+	h.mu.RUnlock()
+
+	var buf bytes.Buffer
+	for _, entry := range legendEntries {
+		name := fmt.Sprintf("%-*s", legendNameWidth, entry.name)
+		color := theme.GetColor(entry.tokenType)
+		if enabled && color != "" {
+			buf.WriteString(color)
+			buf.WriteString(name)
+			buf.WriteString(Reset)
+		} else {
+			buf.WriteString(name)
+		}
+		buf.WriteString(entry.examples)
+		buf.WriteByte('\n')
+	}
+	return buf.String()
+}
@@ -0,0 +1,54 @@
+package highlighter
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/lasseh/jink/lexer"
+)
+
+// PercentageBands color percentages ("95%") by how high they are, so high
+// utilization stands out: values below Warning use the theme's percentage
+// color, values from Warning up use the StateWarning color and values from
+// Bad up use the StateBad color.
+type PercentageBands struct {
+	Warning float64
+	Bad     float64
+}
+
+// DefaultPercentageBands flag percentages from 70% as warnings and from 90% as bad.
+var DefaultPercentageBands = PercentageBands{Warning: 70, Bad: 90}
+
+// SetPercentageBands sets the bands used to color percentages. nil colors
+// every percentage with the theme's percentage color. The default is
+// DefaultPercentageBands.
+func (h *Highlighter) SetPercentageBands(bands *PercentageBands) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if bands == nil {
+		h.percentBands = nil
+		return
+	}
+	copied := *bands
+	h.percentBands = &copied
+}
+
+// color returns the color for a percentage token, or defaultColor if its
+// value is below the warning band or can't be parsed
+func (b *PercentageBands) color(theme *Theme, value, defaultColor string) string {
+	if b == nil {
+		return defaultColor
+	}
+	percent, err := strconv.ParseFloat(strings.TrimSuffix(value, "%"), 64)
+	if err != nil {
+		return defaultColor
+	}
+	switch {
+	case percent >= b.Bad:
+		return theme.GetColor(lexer.TokenStateBad)
+	case percent >= b.Warning:
+		return theme.GetColor(lexer.TokenStateWarning)
+	default:
+		return defaultColor
+	}
+}
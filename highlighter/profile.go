@@ -0,0 +1,30 @@
+package highlighter
+
+import "github.com/lasseh/jink/lexer"
+
+// minimalTokens are the token types the minimal profile keeps colored
+var minimalTokens = map[lexer.TokenType]bool{
+	lexer.TokenStateGood:    true,
+	lexer.TokenStateBad:     true,
+	lexer.TokenStateWarning: true,
+	lexer.TokenStateNeutral: true,
+	lexer.TokenIPv4:         true,
+	lexer.TokenIPv4Prefix:   true,
+	lexer.TokenIPv6:         true,
+	lexer.TokenIPv6Prefix:   true,
+	lexer.TokenInterface:    true,
+	lexer.TokenTimeDuration: true,
+}
+
+// MinimalProfile returns a copy of the theme that only colors states,
+// addresses, interfaces and durations, for show output with little visual
+// noise. Commands, sections, keywords and all other tokens are left uncolored.
+func MinimalProfile(t *Theme) *Theme {
+	minimal := t.Clone()
+	for tokenType := range t.colors {
+		if !minimalTokens[tokenType] {
+			minimal.SetColor(tokenType, "")
+		}
+	}
+	return minimal
+}
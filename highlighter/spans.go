@@ -0,0 +1,62 @@
+package highlighter
+
+import (
+	"strings"
+
+	"github.com/lasseh/jink/lexer"
+)
+
+// Span locates one token in highlighted output. Start and End are byte
+// offsets into the highlighted string, so highlighted[Start:End] is the
+// token's text (with any match or whitespace markers inside it).
+type Span struct {
+	Type  lexer.TokenType
+	Start int
+	End   int
+}
+
+// HighlightWithSpans highlights input like HighlightForced and also returns,
+// for each input line, the spans of its tokens in the highlighted string.
+// Whitespace tokens are omitted. Incoming ANSI sequences are stripped first.
+// Input that HighlightForced would return unchanged (highlighting disabled,
+// or colored input with SetSkipIfColored) is returned with nil spans, and
+// lines over the maximum line length are copied without spans.
+func (h *Highlighter) HighlightWithSpans(input string) (string, [][]Span) {
+	if h.skipInput(input) {
+		return input, nil
+	}
+
+	cleaned := StripANSI(input)
+	h.mu.RLock()
+	mode := h.parseMode
+	h.mu.RUnlock()
+
+	lines := make([][]Span, strings.Count(cleaned, "\n")+1)
+	outputLen := 0 // length of the output before the current run
+	inputEnd := 0  // end of the previous run in cleaned
+	firstLine := 0 // index of the current run's first line
+	output := h.highlightShortLines(cleaned, func(offset int, text string) string {
+		// Long lines before this run are copied unchanged
+		outputLen += offset - inputEnd
+		firstLine += strings.Count(cleaned[inputEnd:offset], "\n")
+		inputEnd = offset + len(text)
+
+		lex := h.newLexer(text)
+		if mode != lexer.ParseModeAuto {
+			lex.SetParseMode(mode)
+		}
+		out := h.render(lex.Tokenize(), nil, func(token lexer.Token, start, end int) {
+			if token.Type == lexer.TokenText && strings.TrimSpace(token.Value) == "" {
+				return
+			}
+			line := firstLine + token.Line - 1
+			if token.Line >= 1 && line < len(lines) {
+				lines[line] = append(lines[line], Span{Type: token.Type, Start: outputLen + start, End: outputLen + end})
+			}
+		})
+		outputLen += len(out)
+		firstLine += strings.Count(text, "\n")
+		return out
+	})
+	return output, lines
+}
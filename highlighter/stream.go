@@ -0,0 +1,26 @@
+package highlighter
+
+// Stream highlights one input that arrives in pieces, such as the lines of a
+// pipe or the output of an SSH session, carrying state from one piece to the
+// next: the brace depth for indent guides. Highlighter settings still apply.
+// A Stream is not safe for concurrent use; create one per input.
+type Stream struct {
+	h     *Highlighter
+	state renderState
+}
+
+// NewStream returns a Stream that highlights with h
+func (h *Highlighter) NewStream() *Stream {
+	return &Stream{h: h}
+}
+
+// Highlight highlights the next piece of the input like Highlighter.Highlight
+func (s *Stream) Highlight(input string) string {
+	return s.h.highlight(input, &s.state)
+}
+
+// HighlightForced highlights the next piece of the input like
+// Highlighter.HighlightForced
+func (s *Stream) HighlightForced(input string) string {
+	return s.h.highlightForced(input, &s.state)
+}
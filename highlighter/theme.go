@@ -1,6 +1,9 @@
 package highlighter
 
 import (
+	"fmt"
+	"hash/fnv"
+	"sort"
 	"strconv"
 
 	"github.com/lasseh/jink/lexer"
@@ -13,6 +16,7 @@ const (
 	Dim       = "\033[2m"
 	Italic    = "\033[3m"
 	Underline = "\033[4m"
+	Reverse   = "\033[7m"
 
 	// Foreground colors
 	Black   = "\033[30m"
@@ -93,9 +97,16 @@ type Palette struct {
 	PromptEdit     string // [edit ...] prefix
 }
 
+// NewTheme creates a Theme from a Palette, e.g. one returned by Theme.Palette
+// with some colors changed.
+func NewTheme(p Palette) *Theme {
+	return buildTheme(p)
+}
+
 // buildTheme creates a Theme from a Palette by mapping semantic colors to token types.
 func buildTheme(p Palette) *Theme {
 	return &Theme{
+		palette: p,
 		colors: map[lexer.TokenType]string{
 			// Config tokens
 			lexer.TokenCommand:    Bold + p.Command,
@@ -124,6 +135,15 @@ func buildTheme(p Palette) *Theme {
 			lexer.TokenValue:      p.Value,
 			lexer.TokenText:       "",
 
+			// Command variants
+			lexer.TokenCommandDestructive: Bold + p.StateBad,
+
+			// File locations
+			lexer.TokenPath: Italic + p.String,
+
+			// SNMP
+			lexer.TokenOID: p.Number,
+
 			// Show output tokens
 			lexer.TokenStateGood:     Bold + p.StateGood,
 			lexer.TokenStateBad:      Bold + p.StateBad,
@@ -136,6 +156,8 @@ func buildTheme(p Palette) *Theme {
 			lexer.TokenByteSize:      p.Protocol,
 			lexer.TokenRouteProtocol: Bold + p.RouteProtocol,
 			lexer.TokenTableName:     Bold + p.TableName,
+			lexer.TokenLevel:         Bold + p.Keyword,
+			lexer.TokenTimestamp:     Italic + p.Duration,
 
 			// Prompt tokens
 			lexer.TokenPromptUser:     p.PromptUser,
@@ -158,7 +180,9 @@ func buildTheme(p Palette) *Theme {
 // Use ThemeByName() to get a theme by name, or create custom themes
 // by modifying an existing theme with SetColor().
 type Theme struct {
-	colors map[lexer.TokenType]string
+	colors  map[lexer.TokenType]string
+	palette Palette // the palette the theme was built from
+	version int     // bumped by SetColor, so converted copies can be redone
 }
 
 // DefaultTheme returns the default theme (Tokyo Night)
@@ -168,6 +192,12 @@ func DefaultTheme() *Theme {
 
 // TokyoNightTheme returns a Tokyo Night inspired theme
 func TokyoNightTheme() *Theme {
+	return buildTheme(tokyoNightPalette())
+}
+
+// tokyoNightPalette returns the Tokyo Night palette. It is also the fallback
+// for fields left unset in theme files.
+func tokyoNightPalette() Palette {
 	foreground := RGB(192, 202, 245) // #c0caf5
 	comment := RGB(86, 95, 137)      // #565f89
 	red := RGB(247, 118, 142)        // #f7768e
@@ -180,7 +210,7 @@ func TokyoNightTheme() *Theme {
 	purple := RGB(157, 124, 216)     // #9d7cd8
 	teal := RGB(115, 218, 202)       // #73daca
 
-	return buildTheme(Palette{
+	return Palette{
 		Foreground:     foreground,
 		Comment:        comment,
 		Command:        magenta,
@@ -211,7 +241,7 @@ func TokyoNightTheme() *Theme {
 		PromptOper:     Color256(128),
 		PromptConf:     Color256(35),
 		PromptEdit:     Dim + comment,
-	})
+	}
 }
 
 // VibrantTheme returns a vibrant color theme (original default)
@@ -583,6 +613,44 @@ func OneDarkTheme() *Theme {
 	})
 }
 
+// MonochromeTheme returns a theme of text attributes only, for terminals
+// or settings where color is off but emphasis is wanted: commands are bold,
+// sections underlined and states reversed.
+func MonochromeTheme() *Theme {
+	return buildTheme(Palette{
+		Foreground:     "",
+		Comment:        Dim,
+		Command:        "",
+		Section:        Underline,
+		Protocol:       Underline,
+		Action:         "",
+		Interface:      Italic,
+		IP:             Underline,
+		Number:         "",
+		String:         Italic,
+		Keyword:        "",
+		Operator:       "",
+		ASN:            Underline,
+		Community:      Italic,
+		Value:          Italic,
+		Wildcard:       Reverse,
+		MAC:            Underline,
+		StateGood:      Reverse,
+		StateBad:       Reverse + Underline,
+		StateWarning:   Reverse + Italic,
+		Duration:       "",
+		RouteProtocol:  "",
+		TableName:      Underline,
+		PromptUser:     Bold,
+		PromptAt:       "",
+		PromptHostOper: Bold,
+		PromptHostConf: Bold + Underline,
+		PromptOper:     Bold,
+		PromptConf:     Bold + Reverse,
+		PromptEdit:     Underline,
+	})
+}
+
 // GetColor returns the color string for a token type
 func (t *Theme) GetColor(tokenType lexer.TokenType) string {
 	if color, ok := t.colors[tokenType]; ok {
@@ -593,37 +661,94 @@ func (t *Theme) GetColor(tokenType lexer.TokenType) string {
 
 // ThemeNames returns a list of available theme names.
 func ThemeNames() []string {
-	return []string{"tokyonight", "vibrant", "solarized", "monokai", "nord", "catppuccin", "dracula", "gruvbox", "onedark"}
+	return []string{"tokyonight", "vibrant", "solarized", "monokai", "nord", "catppuccin", "dracula", "gruvbox", "onedark", "mono"}
 }
 
 // ThemeByName returns a theme by its name. Returns DefaultTheme for unknown names.
-// Supported names: tokyonight, vibrant, solarized, monokai, nord, catppuccin, dracula, gruvbox, onedark
+// Supported names: tokyonight, vibrant, solarized, monokai, nord, catppuccin, dracula, gruvbox, onedark, mono
 func ThemeByName(name string) *Theme {
+	if theme, ok := builtinTheme(name); ok {
+		return theme
+	}
+	return DefaultTheme()
+}
+
+// ThemeByNameStrict is like ThemeByName but returns an error wrapping
+// ErrUnknownTheme for names that aren't built in, instead of the default theme.
+func ThemeByNameStrict(name string) (*Theme, error) {
+	theme, ok := builtinTheme(name)
+	if !ok {
+		return nil, fmt.Errorf("%w %q", ErrUnknownTheme, name)
+	}
+	return theme, nil
+}
+
+// builtinTheme returns the built-in theme with the given name or alias
+func builtinTheme(name string) (*Theme, bool) {
 	switch name {
+	case "default":
+		return DefaultTheme(), true
 	case "tokyonight", "tokyo-night", "tokyo":
-		return TokyoNightTheme()
+		return TokyoNightTheme(), true
 	case "vibrant":
-		return VibrantTheme()
+		return VibrantTheme(), true
 	case "solarized":
-		return SolarizedDarkTheme()
+		return SolarizedDarkTheme(), true
 	case "monokai":
-		return MonokaiTheme()
+		return MonokaiTheme(), true
 	case "nord":
-		return NordTheme()
+		return NordTheme(), true
 	case "catppuccin", "catppuccin-mocha", "mocha":
-		return CatppuccinMochaTheme()
+		return CatppuccinMochaTheme(), true
 	case "dracula":
-		return DraculaTheme()
+		return DraculaTheme(), true
 	case "gruvbox", "gruvbox-dark":
-		return GruvboxDarkTheme()
+		return GruvboxDarkTheme(), true
 	case "onedark", "one-dark":
-		return OneDarkTheme()
+		return OneDarkTheme(), true
+	case "mono", "monochrome":
+		return MonochromeTheme(), true
 	default:
-		return DefaultTheme()
+		return nil, false
 	}
 }
 
+// Palette returns the palette the theme was built from, so its colors can be
+// read or changed by semantic name and the theme rebuilt with NewTheme.
+// Changes made with SetColor are not reflected.
+func (t *Theme) Palette() Palette {
+	return t.palette
+}
+
 // SetColor allows customizing a color for a token type
 func (t *Theme) SetColor(tokenType lexer.TokenType, color string) {
 	t.colors[tokenType] = color
+	t.version++
+}
+
+// Hash returns a stable fingerprint of the theme's colors, e.g. as a key for
+// cached highlighted output. Themes with the same colors for every token
+// type hash the same; the palette itself is not included.
+func (t *Theme) Hash() string {
+	tokenTypes := make([]lexer.TokenType, 0, len(t.colors))
+	for tokenType := range t.colors {
+		tokenTypes = append(tokenTypes, tokenType)
+	}
+	sort.Slice(tokenTypes, func(i, j int) bool { return tokenTypes[i] < tokenTypes[j] })
+
+	h := fnv.New64a()
+	for _, tokenType := range tokenTypes {
+		fmt.Fprintf(h, "%d=%q;", tokenType, t.colors[tokenType])
+	}
+	return fmt.Sprintf("%016x", h.Sum64())
+}
+
+// Clone returns a copy of the theme whose colors can be changed with
+// SetColor without affecting the original.
+func (t *Theme) Clone() *Theme {
+	colors := make(map[lexer.TokenType]string, len(t.colors))
+	for tokenType, color := range t.colors {
+		colors[tokenType] = color
+	}
+	return &Theme{colors: colors, palette: t.palette}
 }
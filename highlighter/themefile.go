@@ -0,0 +1,156 @@
+package highlighter
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// Theme file format
+//
+// A theme file sets Palette fields, one per line:
+//
+//	# Comments start with '#'
+//	command   = #bb9af7
+//	section   = bold 33
+//	stategood = #9ece6a
+//
+// Keys are Palette field names (case-insensitive, '-' and '_' ignored).
+// A color is either "#rrggbb" (true color) or a 256-color index (0-255),
+// optionally preceded by the attributes bold, dim, italic or underline.
+// Fields not set in the file fall back to the default theme.
+
+// themeFileAttributes maps attribute words in theme files to ANSI codes
+var themeFileAttributes = map[string]string{
+	"bold":      Bold,
+	"dim":       Dim,
+	"italic":    Italic,
+	"underline": Underline,
+}
+
+// LoadThemeFile reads a theme file and returns the theme along with the names
+// of Palette fields the file did not set (those use the default theme's colors).
+func LoadThemeFile(path string) (*Theme, []string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, nil, fmt.Errorf("opening theme file: %w", err)
+	}
+	defer func() { _ = f.Close() }()
+
+	p, missing, err := parseThemeFile(f)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%s: %w: %w", path, ErrInvalidThemeFile, err)
+	}
+	return buildTheme(p), missing, nil
+}
+
+// parseThemeFile parses theme file content on top of the default palette.
+// Returns the resulting palette and the fields left at their default.
+func parseThemeFile(r io.Reader) (Palette, []string, error) {
+	p := tokyoNightPalette()
+	fields := paletteFields(&p)
+	set := make(map[string]bool)
+
+	scanner := bufio.NewScanner(r)
+	lineNum := 0
+	for scanner.Scan() {
+		lineNum++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		key, value, ok := strings.Cut(line, "=")
+		if !ok {
+			return p, nil, fmt.Errorf("line %d: expected \"field = color\"", lineNum)
+		}
+
+		name := normalizeFieldName(key)
+		field, ok := fields[name]
+		if !ok {
+			return p, nil, fmt.Errorf("line %d: unknown palette field %q", lineNum, strings.TrimSpace(key))
+		}
+
+		color, err := parseThemeColor(value)
+		if err != nil {
+			return p, nil, fmt.Errorf("line %d: %w", lineNum, err)
+		}
+		field.SetString(color)
+		set[name] = true
+	}
+	if err := scanner.Err(); err != nil {
+		return p, nil, err
+	}
+
+	var missing []string
+	for _, name := range PaletteFieldNames() {
+		if !set[normalizeFieldName(name)] {
+			missing = append(missing, name)
+		}
+	}
+	return p, missing, nil
+}
+
+// parseThemeColor converts a theme file color spec to an ANSI escape sequence
+func parseThemeColor(spec string) (string, error) {
+	words := strings.Fields(spec)
+	if len(words) == 0 {
+		return "", fmt.Errorf("missing color")
+	}
+
+	var sb strings.Builder
+	for _, attr := range words[:len(words)-1] {
+		code, ok := themeFileAttributes[strings.ToLower(attr)]
+		if !ok {
+			return "", fmt.Errorf("unknown attribute %q", attr)
+		}
+		sb.WriteString(code)
+	}
+
+	color := words[len(words)-1]
+	switch {
+	case strings.HasPrefix(color, "#") && len(color) == 7:
+		rgb, err := strconv.ParseUint(color[1:], 16, 32)
+		if err != nil {
+			return "", fmt.Errorf("invalid hex color %q", color)
+		}
+		sb.WriteString(RGB(int(rgb>>16), int(rgb>>8&0xff), int(rgb&0xff)))
+	default:
+		n, err := strconv.Atoi(color)
+		if err != nil || n < 0 || n > 255 {
+			return "", fmt.Errorf("invalid color %q (want #rrggbb or 0-255)", color)
+		}
+		sb.WriteString(Color256(n))
+	}
+	return sb.String(), nil
+}
+
+// PaletteFieldNames returns the names of all Palette fields in declaration order.
+func PaletteFieldNames() []string {
+	typ := reflect.TypeOf(Palette{})
+	names := make([]string, typ.NumField())
+	for i := range names {
+		names[i] = typ.Field(i).Name
+	}
+	return names
+}
+
+// paletteFields returns settable Palette fields keyed by normalized name
+func paletteFields(p *Palette) map[string]reflect.Value {
+	v := reflect.ValueOf(p).Elem()
+	fields := make(map[string]reflect.Value, v.NumField())
+	for i := 0; i < v.NumField(); i++ {
+		fields[normalizeFieldName(v.Type().Field(i).Name)] = v.Field(i)
+	}
+	return fields
+}
+
+// normalizeFieldName lowercases a field name and drops '-' and '_'
+func normalizeFieldName(name string) string {
+	name = strings.ToLower(strings.TrimSpace(name))
+	return strings.NewReplacer("-", "", "_", "").Replace(name)
+}
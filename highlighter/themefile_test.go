@@ -0,0 +1,145 @@
+package highlighter
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/lasseh/jink/lexer"
+)
+
+func writeThemeFile(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "custom.theme")
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatalf("failed to write theme file: %v", err)
+	}
+	return path
+}
+
+func TestLoadThemeFile(t *testing.T) {
+	path := writeThemeFile(t, `# partial theme
+command = bold #ff0000
+section = 33
+state-good = #00ff00
+`)
+
+	theme, missing, err := LoadThemeFile(path)
+	if err != nil {
+		t.Fatalf("LoadThemeFile failed: %v", err)
+	}
+
+	// buildTheme adds Bold to commands and sections on top of the file's color
+	if got, want := theme.GetColor(lexer.TokenCommand), Bold+Bold+RGB(255, 0, 0); got != want {
+		t.Errorf("command color = %q, want %q", got, want)
+	}
+	if got, want := theme.GetColor(lexer.TokenSection), Bold+Color256(33); got != want {
+		t.Errorf("section color = %q, want %q", got, want)
+	}
+	if got, want := theme.GetColor(lexer.TokenStateGood), Bold+RGB(0, 255, 0); got != want {
+		t.Errorf("state good color = %q, want %q", got, want)
+	}
+
+	// Unset fields fall back to the default theme
+	if theme.GetColor(lexer.TokenInterface) != DefaultTheme().GetColor(lexer.TokenInterface) {
+		t.Error("unset Interface field should use the default theme color")
+	}
+
+	if len(missing) != len(PaletteFieldNames())-3 {
+		t.Errorf("expected %d missing fields, got %d: %v", len(PaletteFieldNames())-3, len(missing), missing)
+	}
+	for _, name := range []string{"Command", "Section", "StateGood"} {
+		for _, m := range missing {
+			if m == name {
+				t.Errorf("%s is set in the file and should not be reported missing", name)
+			}
+		}
+	}
+}
+
+func TestLoadThemeFileErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		errText string
+	}{
+		{"unknown field", "bogus = #ffffff\n", "unknown palette field"},
+		{"bad hex", "command = #zzzzzz\n", "invalid hex color"},
+		{"out of range", "command = 300\n", "invalid color"},
+		{"unknown attribute", "command = blink 33\n", "unknown attribute"},
+		{"missing separator", "command #ffffff\n", "line 1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, _, err := LoadThemeFile(writeThemeFile(t, tt.content))
+			if err == nil || !strings.Contains(err.Error(), tt.errText) {
+				t.Errorf("expected error containing %q, got %v", tt.errText, err)
+			}
+			if !errors.Is(err, ErrInvalidThemeFile) {
+				t.Errorf("expected error to wrap ErrInvalidThemeFile, got %v", err)
+			}
+		})
+	}
+
+	_, _, err := LoadThemeFile(filepath.Join(t.TempDir(), "missing.theme"))
+	if err == nil {
+		t.Error("expected error for nonexistent file")
+	}
+	if !errors.Is(err, fs.ErrNotExist) || errors.Is(err, ErrInvalidThemeFile) {
+		t.Errorf("expected a not-exist error for a missing file, got %v", err)
+	}
+}
+
+func TestThemeByNameStrict(t *testing.T) {
+	for _, name := range append(ThemeNames(), "default") {
+		if theme, err := ThemeByNameStrict(name); err != nil || theme == nil {
+			t.Errorf("ThemeByNameStrict(%q) = %v, %v", name, theme, err)
+		}
+	}
+
+	theme, err := ThemeByNameStrict("no-such-theme")
+	if theme != nil || !errors.Is(err, ErrUnknownTheme) {
+		t.Errorf("expected ErrUnknownTheme, got %v, %v", theme, err)
+	}
+	if err != nil && !strings.Contains(err.Error(), `"no-such-theme"`) {
+		t.Errorf("expected the error to name the theme, got %v", err)
+	}
+}
+
+func TestThemePaletteRoundTrip(t *testing.T) {
+	for _, name := range ThemeNames() {
+		theme := ThemeByName(name)
+		rebuilt := NewTheme(theme.Palette())
+		if rebuilt.Palette() != theme.Palette() {
+			t.Errorf("%s: palette did not round-trip", name)
+		}
+		for tokenType := lexer.TokenText; tokenType <= lexer.TokenOID; tokenType++ {
+			if got, want := rebuilt.GetColor(tokenType), theme.GetColor(tokenType); got != want {
+				t.Errorf("%s: %v color = %q, want %q", name, tokenType, got, want)
+			}
+		}
+	}
+
+	// Tweak a color by its semantic name
+	p := DefaultTheme().Palette()
+	p.Interface = Color256(208)
+	if got := NewTheme(p).GetColor(lexer.TokenInterface); got != Bold+Color256(208) {
+		t.Errorf("interface color = %q, want %q", got, Bold+Color256(208))
+	}
+
+	// Theme files and degraded themes keep their palette
+	theme, _, err := LoadThemeFile(writeThemeFile(t, "stategood = #00ff00\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := theme.Palette().StateGood; got != RGB(0, 255, 0) {
+		t.Errorf("theme file StateGood = %q, want %q", got, RGB(0, 255, 0))
+	}
+	if got := theme.Degrade(ColorDepth256).Palette().StateGood; got != Color256(46) {
+		t.Errorf("degraded StateGood = %q, want %q", got, Color256(46))
+	}
+}
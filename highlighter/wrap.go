@@ -0,0 +1,73 @@
+package highlighter
+
+import (
+	"strings"
+	"unicode/utf8"
+
+	"github.com/lasseh/jink/lexer"
+)
+
+// wrappedTokens are the token types SetWrapWidth breaks across lines
+var wrappedTokens = map[lexer.TokenType]bool{
+	lexer.TokenComment:    true,
+	lexer.TokenAnnotation: true,
+	lexer.TokenString:     true,
+	lexer.TokenValue:      true,
+}
+
+// SetWrapWidth soft-wraps comments, strings and values that would run past
+// width columns, breaking them at spaces. Continuation lines are indented to
+// where the token started, and each piece keeps the token's color. Other
+// tokens are never broken. width <= 0 turns wrapping off, the default.
+func (h *Highlighter) SetWrapWidth(width int) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.wrapWidth = width
+}
+
+// wrapTokens splits the wrappable tokens that run past width into pieces
+// separated by a newline and indentation
+func wrapTokens(tokens []lexer.Token, width int) []lexer.Token {
+	wrapped := make([]lexer.Token, 0, len(tokens))
+	col := 0 // visible column the next token starts at
+	for _, token := range tokens {
+		if !wrappedTokens[token.Type] || strings.Contains(token.Value, "\n") ||
+			col+utf8.RuneCountInString(token.Value) <= width {
+			wrapped = append(wrapped, token)
+			col = columnAfter(col, token.Value)
+			continue
+		}
+
+		// Wide indents would leave little room per line; start those at column 0
+		indent := col
+		if indent > width/2 {
+			indent = 0
+		}
+
+		words := strings.Split(token.Value, " ")
+		piece := words[0]
+		for _, word := range words[1:] {
+			if col+utf8.RuneCountInString(piece)+1+utf8.RuneCountInString(word) <= width || strings.TrimSpace(piece) == "" {
+				piece += " " + word
+				continue
+			}
+			wrapped = append(wrapped,
+				lexer.Token{Type: token.Type, Value: piece, Line: token.Line, Column: token.Column},
+				lexer.Token{Type: lexer.TokenText, Value: "\n" + strings.Repeat(" ", indent), Line: token.Line, Column: token.Column},
+			)
+			col = indent
+			piece = word
+		}
+		wrapped = append(wrapped, lexer.Token{Type: token.Type, Value: piece, Line: token.Line, Column: token.Column})
+		col += utf8.RuneCountInString(piece)
+	}
+	return wrapped
+}
+
+// columnAfter returns the visible column after text that starts at col
+func columnAfter(col int, text string) int {
+	if i := strings.LastIndexByte(text, '\n'); i >= 0 {
+		return utf8.RuneCountInString(text[i+1:])
+	}
+	return col + utf8.RuneCountInString(text)
+}
@@ -0,0 +1,59 @@
+package highlighter
+
+import (
+	"bytes"
+	"io"
+)
+
+// writer highlights what is written to it line by line and forwards the
+// result to w. A partial line is held until its newline or Close.
+type writer struct {
+	w       io.Writer
+	stream  *Stream
+	pending []byte // start of a line not yet terminated by a newline
+	closed  bool
+}
+
+// NewWriter returns a writer that highlights everything written to it with h
+// and writes the result to w, e.g. for fmt.Fprintf(hw, ...). Each line is
+// highlighted like h.Highlight once its newline is written, so a line may
+// span several Write calls; the lines are one Stream. Close flushes a final
+// line without a newline; it doesn't close w.
+func NewWriter(w io.Writer, h *Highlighter) io.WriteCloser {
+	return &writer{w: w, stream: h.NewStream()}
+}
+
+// Write implements io.Writer
+func (hw *writer) Write(p []byte) (int, error) {
+	if hw.closed {
+		return 0, ErrWriterClosed
+	}
+
+	hw.pending = append(hw.pending, p...)
+	for {
+		i := bytes.IndexByte(hw.pending, '\n')
+		if i < 0 {
+			return len(p), nil
+		}
+		line := string(hw.pending[:i+1])
+		hw.pending = hw.pending[i+1:]
+		if _, err := io.WriteString(hw.w, hw.stream.Highlight(line)); err != nil {
+			return 0, err
+		}
+	}
+}
+
+// Close highlights and writes a final partial line, if any
+func (hw *writer) Close() error {
+	if hw.closed {
+		return nil
+	}
+	hw.closed = true
+	if len(hw.pending) == 0 {
+		return nil
+	}
+	line := string(hw.pending)
+	hw.pending = nil
+	_, err := io.WriteString(hw.w, hw.stream.Highlight(line))
+	return err
+}
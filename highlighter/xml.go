@@ -0,0 +1,95 @@
+package highlighter
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/lasseh/jink/lexer"
+)
+
+var (
+	// xmlStartPattern matches input that opens with an XML declaration or
+	// tag, like NETCONF "<rpc-reply ...>" or "| display xml" output
+	xmlStartPattern = regexp.MustCompile(`^\s*<(\?xml|!--|[A-Za-z_][\w.:-]*(\s|>|/>))`)
+
+	// xmlMarkupPattern finds comments and tags; the text between them is
+	// element content
+	xmlMarkupPattern = regexp.MustCompile(`<!--[\s\S]*?-->|<[^<>]*>`)
+
+	// xmlTagPattern splits a tag into its opening bracket, name, attributes
+	// and closing bracket
+	xmlTagPattern = regexp.MustCompile(`^(<[/?]?)([^\s/?>]*)([\s\S]*?)([/?]?>)$`)
+
+	// xmlAttributePattern finds name="value" attributes in a tag
+	xmlAttributePattern = regexp.MustCompile(`([^\s=]+)(\s*=\s*)("[^"]*"|'[^']*')`)
+)
+
+// looksLikeXML reports whether input is XML rather than JunOS text, e.g. a
+// NETCONF reply piped in by mistake. Config that starts with a wildcard
+// ("<ge-*> {", "<ae1> {\n    mtu 9192;\n}") is not XML: its first line opens
+// a block or ends a statement.
+func looksLikeXML(input string) bool {
+	if !xmlStartPattern.MatchString(input) {
+		return false
+	}
+	first := strings.TrimSpace(input)
+	if i := strings.IndexByte(first, '\n'); i >= 0 {
+		first = strings.TrimSpace(first[:i])
+	}
+	return !strings.HasSuffix(first, "{") && !strings.HasSuffix(first, ";")
+}
+
+// highlightXML colors the markup of XML input: tag names, attribute names
+// and values, and comments. Element content is left uncolored, since it is
+// data rather than JunOS text.
+func (h *Highlighter) highlightXML(input string) string {
+	return h.renderTokens(xmlTokens(input))
+}
+
+// xmlTokens splits XML input into tokens: brackets as TokenBrace, tag names
+// as TokenSection, attribute names as TokenKeyword with TokenString values,
+// comments as TokenComment and everything else as TokenText.
+func xmlTokens(input string) []lexer.Token {
+	var tokens []lexer.Token
+	line, col := 1, 1
+	emit := func(typ lexer.TokenType, value string) {
+		if value == "" {
+			return
+		}
+		tokens = append(tokens, lexer.Token{Type: typ, Value: value, Line: line, Column: col})
+		if i := strings.LastIndexByte(value, '\n'); i >= 0 {
+			line += strings.Count(value, "\n")
+			col = len(value) - i
+		} else {
+			col += len(value)
+		}
+	}
+
+	pos := 0
+	for _, loc := range xmlMarkupPattern.FindAllStringIndex(input, -1) {
+		emit(lexer.TokenText, input[pos:loc[0]])
+		pos = loc[1]
+
+		markup := input[loc[0]:loc[1]]
+		tag := xmlTagPattern.FindStringSubmatch(markup)
+		if strings.HasPrefix(markup, "<!--") || tag == nil {
+			emit(lexer.TokenComment, markup)
+			continue
+		}
+
+		emit(lexer.TokenBrace, tag[1])
+		emit(lexer.TokenSection, tag[2])
+		attrs, at := tag[3], 0
+		for _, attr := range xmlAttributePattern.FindAllStringSubmatchIndex(attrs, -1) {
+			emit(lexer.TokenText, attrs[at:attr[2]])
+			emit(lexer.TokenKeyword, attrs[attr[2]:attr[3]])
+			emit(lexer.TokenOperator, attrs[attr[4]:attr[5]])
+			emit(lexer.TokenString, attrs[attr[6]:attr[7]])
+			at = attr[1]
+		}
+		emit(lexer.TokenText, attrs[at:])
+		emit(lexer.TokenBrace, tag[4])
+	}
+	emit(lexer.TokenText, input[pos:])
+	return tokens
+}
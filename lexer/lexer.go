@@ -1,7 +1,9 @@
 package lexer
 
 import (
+	"fmt"
 	"regexp"
+	"strconv"
 	"strings"
 )
 
@@ -9,6 +11,35 @@ import (
 const (
 	// parseModeDetectionSampleSize is the number of characters sampled for auto-detection
 	parseModeDetectionSampleSize = 500
+
+	// sessionArrow separates source and destination in flow session output
+	sessionArrow = "-->"
+
+	// sessionHeader starts the first line of each flow session
+	sessionHeader = "Session ID:"
+
+	// highLoadAverage is the load average at which uptime output flags the
+	// system as busy (more runnable processes than a single-core RE can run)
+	highLoadAverage = 1.0
+
+	// secretMarker follows encrypted values in exported configs
+	// ("encrypted-password \"$6$...\"; ## SECRET-DATA")
+	secretMarker = "## SECRET-DATA"
+
+	// routeProtocolNames are the protocols in "[BGP/170]" route entries
+	routeProtocolNames = `BGP|OSPF|OSPF3|ISIS|RIP|Static|Direct|Local|Aggregate|MPLS|LDP|RSVP`
+
+	// tableFamilies are the families that end a routing table name; the
+	// name may be scoped by a routing instance or another family
+	// ("CUST-A.inet.0", "bgp.l3vpn.0", "__default_evpn__.evpn.0")
+	tableFamilies = `inet|inet6|inetflow|inet6flow|inetcolor|inet6color|mpls|bgp|iso|l2vpn|l3vpn|l3vpn-inet6|l2circuit|evpn|rtarget|mdt|lsdist|ccc`
+
+	// minBase64Blob is the shortest word treated as a base64 blob
+	minBase64Blob = 40
+
+	// ipv4Octet matches an IPv4 address octet from 0 to 255, leading zeros
+	// allowed ("010")
+	ipv4Octet = `(25[0-5]|2[0-4]\d|[01]?\d?\d)`
 )
 
 // Lexer tokenizes JunOS configuration text
@@ -22,6 +53,93 @@ type Lexer struct {
 	expectingValue bool   // true after keywords like "description" that take a value
 	expectingUnit  bool   // true after "unit" keyword to classify numbers as TokenUnit
 	lastToken      string // tracks the last non-whitespace token value for context
+	inMPLSTable    bool   // true after an "mpls.N:" table header in show output
+	expectingLabel bool   // true after an MPLS label operation (Swap/Push)
+	expectingPort  bool   // true after a session endpoint address ("10.0.0.5" of "10.0.0.5/51234")
+	afterLevel     bool   // true right after an IS-IS level ("2" or "L2" in "r2  2  Up  23")
+	inTimestamp    bool   // true after the date of a timestamp, until its time and zone
+	braceDepth     int    // number of open { blocks
+	instanceDepth  int    // brace depth inside a routing-instances/logical-systems/tenants block (0 = none)
+	expectingHold  bool   // true after the state of an IS-IS adjacency, before its hold time
+	arrowLine      int    // line number the cached arrowOnLine answer belongs to (0 = none)
+	arrowOnLine    bool   // whether line arrowLine is a flow session line
+	confedLine     int    // line number the cached confedOnLine answer belongs to (0 = none)
+	confedOnLine   bool   // whether line confedLine contains "confederation"
+	inDiscardStats bool   // true after a "... discard statistics:" header in show output
+	dropLine       int    // line number the cached dropCounter match belongs to (0 = none)
+	dropCounter    []int  // dropCounterPattern match on line dropLine as input offsets (nil = none)
+	routeLine      int    // line number the cached inactiveRoute answer belongs to (0 = none)
+	inactiveRoute  bool   // whether line routeLine is an inactive route entry
+	reasonLine     int    // line number of the last "Inactive reason:" seen (0 = none)
+	replaceLine    int    // line number of the last "replace" command seen (0 = none)
+	progressLine   int    // line number the cached onProgress answer belongs to (0 = none)
+	onProgress     bool   // whether line progressLine is shutdown/reboot progress
+	secretLine     int    // line number the cached secretAt answer belongs to (0 = none)
+	secretAt       int    // input offset of the SECRET-DATA marker on line secretLine (-1 = none)
+
+	// commentPrefix lists the prefixes that start a line comment ("#" by default)
+	commentPrefix []string
+
+	// firstWordIsCommand tags unknown verb-like words at column 1 as commands
+	firstWordIsCommand bool
+
+	// inReferenceList is true inside "[ ... ]" after a zone or address keyword
+	inReferenceList bool
+
+	// expectingType is the type of the value after a typedValueKeywords
+	// keyword ("peer-as"), or TokenText when no typed value is expected
+	expectingType TokenType
+
+	// defaultsDepth is the brace depth inside a "groups junos-defaults" block
+	// (0 = none), whose factory-default config is dimmed
+	defaultsDepth int
+
+	// hopLine is the line number the cached hopTimeout answer belongs to
+	// (0 = none), hopTimeout the type of "*" probe timeouts on that line
+	hopLine    int
+	hopTimeout TokenType
+
+	// inPEMBlock is true between the "-----BEGIN" and "-----END" lines of a
+	// PEM block, such as a certificate
+	inPEMBlock bool
+
+	// pipeArgument is the type of the argument expected after a pipe filter
+	// ("bgp" in "| match bgp"), or TokenText when none is expected
+	pipeArgument TokenType
+
+	// caseSensitive limits config keywords to lower case (see SetCaseSensitive)
+	caseSensitive bool
+
+	// attributeLine is the line number the cached route attribute label
+	// belongs to (0 = none): its name in lower case ("" = none), and the
+	// input offsets where the protocol before it and the label itself end
+	attributeLine     int
+	attributeLabel    string
+	attributeProtoEnd int
+	attributeLabelEnd int
+
+	// gresLine is the line number the cached gresStateAt answer belongs to
+	// (0 = none), gresStateAt the input offset of the state after a
+	// high-availability feature label on that line (-1 = none)
+	gresLine    int
+	gresStateAt int
+
+	// headersLine is the line number the cached lineHeaders answer belongs
+	// to (0 = none), lineHeaders the column headers of the tableHeaders
+	// entry that line is the header line of (nil = none)
+	headersLine int
+	lineHeaders map[string]bool
+
+	// table is the show table whose header line was seen last, until a
+	// blank line ("" = none)
+	table string
+
+	// commitLine is the line number the cached commit history entry offsets
+	// belong to (0 = none): the input offsets where its username and access
+	// method start (-1 = none)
+	commitLine   int
+	commitUser   int
+	commitMethod int
 }
 
 // ParseMode determines which classification rules to use for tokenization.
@@ -41,12 +159,50 @@ const (
 	ParseModeShow
 )
 
+// String returns the name of the parse mode ("auto", "config" or "show")
+func (m ParseMode) String() string {
+	switch m {
+	case ParseModeAuto:
+		return "auto"
+	case ParseModeConfig:
+		return "config"
+	case ParseModeShow:
+		return "show"
+	default:
+		return "unknown"
+	}
+}
+
+// ParseModeFromString converts a mode name ("auto", "config" or "show",
+// case-insensitive) to a ParseMode.
+func ParseModeFromString(s string) (ParseMode, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "auto":
+		return ParseModeAuto, nil
+	case "config":
+		return ParseModeConfig, nil
+	case "show":
+		return ParseModeShow, nil
+	default:
+		return ParseModeAuto, fmt.Errorf("unknown parse mode %q (want auto, config or show)", s)
+	}
+}
+
+// tableHeader is the column header line of a show table: a line containing
+// every one of marks, on which the words in headers are column headers.
+// table names the table whose rows follow.
+type tableHeader struct {
+	table   string
+	marks   []string
+	headers map[string]bool
+}
+
 // Keyword sets for classification
 var (
 	commands = map[string]bool{
 		"set": true, "delete": true, "deactivate": true, "activate": true,
 		"protect": true, "unprotect": true, "edit": true, "show": true,
-		"request": true, "run": true, "insert": true, "rename": true,
+		"request": true, "run": true, "insert": true, "rename": true, "replace": true,
 		"copy": true, "top": true, "up": true, "exit": true, "quit": true,
 		"commit": true, "rollback": true, "load": true, "save": true,
 		"configure": true, "cli": true, "help": true, "clear": true,
@@ -54,6 +210,11 @@ var (
 		"ping": true, "traceroute": true, "ssh": true, "telnet": true,
 	}
 
+	// Commands that remove or disable configuration
+	destructiveCommands = map[string]bool{
+		"delete": true, "deactivate": true,
+	}
+
 	sections = map[string]bool{
 		// Core configuration sections
 		"system": true, "chassis": true, "interfaces": true,
@@ -163,8 +324,10 @@ var (
 		"ether-type": true, "vlan-ether-type": true,
 		"user-vlan-id": true, "learn-vlan-id": true,
 		"dot1q-tag": true, "dot1q-user-priority": true,
-		// Firewall match conditions - Interface/group matching
-		"interface": true, "interface-group": true, "interface-group-except": true,
+		// Firewall match conditions - Interface/group matching. Plain
+		// "interface" is left to keywords: it also names interfaces under
+		// OSPF/IS-IS areas and should color the same in both places.
+		"interface-group": true, "interface-group-except": true,
 		"interface-set": true, "ifl-number": true,
 		"input-interface": true, "output-interface": true,
 		// Firewall match conditions - Protocol fields
@@ -188,8 +351,9 @@ var (
 		"ssh-ecdsa": true, "ssh-ed25519": true,
 		"description": true, "disable": true, "enable": true,
 		"inactive": true, "apply-macro": true, "apply-path": true,
+		"apply-flags": true, "omit": true,
 		// Interface keywords
-		"unit": true, "family": true, "address": true, "vlan-id": true,
+		"unit": true, "family": true, "address": true, "vlan-id": true, "vlan-id-list": true,
 		"vlan-tagging": true, "flexible-vlan-tagging": true,
 		"native-vlan-id": true, "mtu": true, "speed": true,
 		"duplex": true, "auto-negotiation": true, "no-auto-negotiation": true,
@@ -244,6 +408,8 @@ var (
 		"host-inbound-traffic": true, "system-services": true,
 		"policies": true, "policy": true, "match": true, "application": true,
 		"source-zone": true, "destination-zone": true, "nat": true,
+		"from-zone": true, "to-zone": true, "except": true,
+		"source-address-excluded": true, "destination-address-excluded": true,
 		"source": true, "destination": true, "pool": true,
 		"rule-set": true, "rule": true,
 		"translation-type": true, "translated": true,
@@ -266,6 +432,7 @@ var (
 		"read-only": true, "read-write": true, "view": true,
 		"client-list": true, "interface-list": true,
 		"location": true, "contact": true, "community": true,
+		"oid": true,
 		// Forwarding-options keywords
 		"storm-control-profiles": true, "storm-control": true,
 		"analyzer": true, "port-mirroring": true, "helpers": true,
@@ -275,6 +442,9 @@ var (
 		"ip6-destination-address": true, "ip6-source-address": true,
 		"router-advertisement": true, "router-solicitation": true,
 		"neighbor-advertisement": true, "neighbor-solicitation": true,
+		// Flexible match fields ("flexible-match-mask mask-in-hex 0xff00")
+		"mask-in-hex": true, "bit-length": true, "bit-offset": true,
+		"byte-offset": true, "match-start": true,
 		// DHCPv6 client keywords
 		"dhcpv6-client": true, "dhcp-client": true,
 		"client-type": true, "client-ia-type": true, "ia-na": true, "ia-pd": true,
@@ -336,6 +506,7 @@ var (
 		"dhcp-attributes": true, "option": true, "option-82": true,
 		"relay-option": true, "relay-agent-information": true,
 		"subscriber-id": true, "agent-circuit-id": true, "agent-remote-id": true,
+		"relay-option-82": true, "circuit-id": true, "remote-id": true, "hex-string": true,
 		// L2TP
 		"lns": true, "lac": true, "l2tp-access-profile": true,
 		"receive-window": true, "retransmit-interval": true,
@@ -359,6 +530,12 @@ var (
 		"commit-script": true, "op-script": true, "event-script": true,
 		"slax": true, "python": true, "allow-commands": true, "deny-commands": true,
 		"extension-service": true, "request-response": true, "notification": true,
+		// Configuration command arguments ("replace pattern OLD with NEW")
+		"pattern": true, "with": true,
+		// CLI preferences ("set cli screen-length 0")
+		"screen-length": true, "screen-width": true, "terminal": true,
+		"idle-timeout": true, "timestamp": true, "complete-on-space": true,
+		"prompt": true,
 	}
 
 	// Keywords that take a value (colored as TokenValue)
@@ -373,6 +550,106 @@ var (
 		"ascii-text":         true,
 		"community-name":     true,
 		"version":            true,
+		"prompt":             true,
+	}
+
+	// Keywords whose value always gets its semantic type, even where the
+	// generic patterns miss it ("peer-as 65000", "peer-as 1.10" in asdot)
+	typedValueKeywords = map[string]TokenType{
+		"peer-as":           TokenASN,
+		"local-as":          TokenASN,
+		"autonomous-system": TokenASN,
+		"confederation":     TokenASN,
+		"router-id":         TokenIPv4,
+		"vlan-id":           TokenNumber,
+		"native-vlan-id":    TokenNumber,
+	}
+
+	// typedValuePatterns check that a typedValueKeywords value fits its type
+	typedValuePatterns = map[TokenType]*regexp.Regexp{
+		TokenASN:    asValuePattern,
+		TokenIPv4:   ipv4Pattern,
+		TokenNumber: unitNumberPattern,
+	}
+
+	// Keywords followed by the name of a counter, policer or forwarding class,
+	// both where it is defined ("then count web-hits") and where show output or
+	// a command refers to it ("show firewall filter f counter web-hits")
+	objectNameKeywords = map[string]bool{
+		"count":            true,
+		"counter":          true,
+		"policer":          true,
+		"forwarding-class": true,
+	}
+
+	// Output filters after a CLI pipe ("show route | match bgp"), mapped to
+	// the type of their argument, or TokenText when they take none
+	pipeFilters = map[string]TokenType{
+		"match":   TokenValue,
+		"except":  TokenValue,
+		"find":    TokenValue,
+		"display": TokenKeyword,
+		"last":    TokenNumber,
+		"trim":    TokenNumber,
+		"count":   TokenText,
+		"no-more": TokenText,
+		"compare": TokenText,
+		"resolve": TokenText,
+		"save":    TokenText,
+		"refresh": TokenText,
+		"hold":    TokenText,
+	}
+
+	// Ping reply fields and the type of their value
+	pingFieldTypes = map[string]TokenType{
+		"icmp_seq=": TokenNumber,
+		"ttl=":      TokenNumber,
+		"hlim=":     TokenNumber,
+		"time=":     TokenTimeDuration,
+	}
+
+	// Terminal types for "set cli terminal"
+	terminalTypes = map[string]bool{
+		"ansi": true, "vt100": true, "small-xterm": true, "xterm": true,
+	}
+
+	// Keywords followed by user-defined zone or address-book names
+	// ("from-zone trust", "source-address [ web-servers db-servers ]")
+	referenceKeywords = map[string]bool{
+		"from-zone":           true,
+		"to-zone":             true,
+		"source-zone":         true,
+		"destination-zone":    true,
+		"source-address":      true,
+		"destination-address": true,
+	}
+
+	// Address families following "family" (interfaces, BGP, firewall). They
+	// are all TokenProtocol, even names like "evpn" that are also sections.
+	addressFamilies = map[string]bool{
+		"inet": true, "inet6": true, "mpls": true, "iso": true,
+		"ccc": true, "tcc": true, "vpls": true, "bridge": true,
+		"ethernet-switching": true, "evpn": true, "any": true,
+		"inet-vpn": true, "inet6-vpn": true, "inet-mvpn": true, "inet6-mvpn": true,
+		"inet-mdt": true, "l2vpn": true, "route-target": true,
+		"traffic-engineering": true, "multiservice": true, "pppoe": true,
+		"mlppp": true, "mlfr-end-to-end": true, "mlfr-uni-nni": true,
+	}
+
+	// Sections whose children are named instances ("routing-instances
+	// CUSTOMER-A", "logical-systems LS1"); the names are values
+	instanceSections = map[string]bool{
+		"routing-instances": true,
+		"logical-systems":   true,
+		"tenants":           true,
+	}
+
+	// Keywords followed by DHCP option-82 IDs or option data in hex
+	// ("agent-circuit-id 0x000a0b0c", "option 82 hex-string 0102abcd")
+	hexValueKeywords = map[string]bool{
+		"agent-circuit-id": true,
+		"agent-remote-id":  true,
+		"hex-string":       true,
 	}
 
 	// interfacePattern matches JunOS interface naming conventions:
@@ -394,9 +671,9 @@ var (
 	//   VXLAN: vtep (VXLAN tunnel endpoint)
 	//   QFX: fti (flexible tunnel interface)
 	//   Special: all (wildcard for all interfaces)
-	interfacePattern  = regexp.MustCompile(`^([gx]e|et|so|fe|at|t1|t3|e1|e3|mge|vcp|si|lsq|rlsq)-\d+/\d+/\d+(:\d+)?(\.\d+)?$|^(ae|reth|lo|em|me|irb|vlan|fab|gr|ip|vt|lt|ms|sp|pp|pd|pe|demux|dsc|mtun|pimd|pime|tap|lsi|st|vtep|fti|jsrv|gre|ipip)\d*(\.\d+)?$|^[efm]xp\d+(\.\d+)?$|^vme(\.\d+)?$|^all$`)
-	ipv4Pattern       = regexp.MustCompile(`^(\d{1,3}\.){3}\d{1,3}$`)
-	ipv4PrefixPattern = regexp.MustCompile(`^(\d{1,3}\.){3}\d{1,3}/\d{1,2}$`)
+	interfacePattern  = regexp.MustCompile(`(?i)^([gx]e|et|so|fe|at|t1|t3|e1|e3|mge|vcp|si|lsq|rlsq|gr|ip|lt|vt|ms|sp|pd|pe|mt)-\d+/\d+/\d+(:\d+)?(\.\d+)?$|^(ae|reth|lo|em|me|irb|vlan|fab|gr|ip|vt|lt|ms|sp|pp|pd|pe|demux|dsc|mtun|pimd|pime|tap|lsi|st|vtep|fti|jsrv|gre|ipip)\d*(\.\d+)?$|^[efm]xp\d+(\.\d+)?$|^vme(\.\d+)?$|^all$`)
+	ipv4Pattern       = regexp.MustCompile(`^(` + ipv4Octet + `\.){3}` + ipv4Octet + `$`)
+	ipv4PrefixPattern = regexp.MustCompile(`^(` + ipv4Octet + `\.){3}` + ipv4Octet + `/(3[0-2]|[12]?\d)$`)
 	ipv6Pattern       = regexp.MustCompile(`^[0-9a-fA-F:]+:[0-9a-fA-F:]*$`)
 	ipv6PrefixPattern = regexp.MustCompile(`^[0-9a-fA-F:]+:[0-9a-fA-F:]*/\d{1,3}$`)
 	macPattern        = regexp.MustCompile(`^([0-9a-fA-F]{2}:){5}[0-9a-fA-F]{2}(/\d{1,2})?$`)
@@ -405,18 +682,63 @@ var (
 	asnPattern        = regexp.MustCompile(`^[Aa][Ss]\d+$`) // AS number format (AS65000)
 	unitNumberPattern = regexp.MustCompile(`^\d+$`)         // Plain numbers for unit classification
 
+	// AS numbers as values, asplain or asdot ("65000", "AS65000", "1.10").
+	// Only checked where an AS number is expected, since a bare "1.5" is as
+	// likely a decimal.
+	asValuePattern = regexp.MustCompile(`^([Aa][Ss])?\d+(\.\d+)?$`)
+
+	// Signed numbers: metric adjustments and offsets (-10, +5). Diff markers
+	// at column 1 are "- "/"+ " and never reach word classification.
+	signedNumberPattern = regexp.MustCompile(`^[+-]\d+[gmkGMK]?$`)
+
+	// Number ranges and comma lists: VLAN IDs and ports ("200-300",
+	// "100-200,300"). Every range needs both ends, so "300-" doesn't match.
+	numberRangePattern = regexp.MustCompile(`^\d{1,5}(-\d{1,5})?(,\d{1,5}(-\d{1,5})?)*$`)
+
+	// Hex strings in DHCP options, with or without a 0x prefix (0x0102abcd)
+	hexStringPattern = regexp.MustCompile(`^(0[xX])?[0-9a-fA-F]+$`)
+
+	// File locations: URLs (ftp://host/file, https://...) and absolute paths
+	// (/var/tmp/config). Interface names never start with "/" or a scheme.
+	urlPattern  = regexp.MustCompile(`^[a-zA-Z][a-zA-Z0-9+.-]*://\S+$`)
+	pathPattern = regexp.MustCompile(`^(/[\w.@+-]+)+/?$`)
+
+	// SNMP OIDs: five or more numeric arcs (1.3.6.1.2.1.1.3, .1.3.6.1.4.1.2636),
+	// or four under iso.org.dod (1.3.6.1), which IPv4 addresses never use in
+	// practice; and MIB object names (IF-MIB::ifOperStatus.523)
+	oidPattern       = regexp.MustCompile(`^\.?\d+(\.\d+){4,}$|^\.?1\.3\.6\.\d+$`)
+	mibObjectPattern = regexp.MustCompile(`^[A-Z][A-Za-z0-9-]*-MIB::[A-Za-z]\w*(\.\d+)*$`)
+
+	// Regular expression metacharacters, used to spot unquoted regex values
+	regexMetaPattern = regexp.MustCompile(`[.*+?^$|()\\]`)
+
+	// Verb-like words: lowercase letters with optional dashes ("override", "run-script")
+	verbPattern = regexp.MustCompile(`^[a-z]+(-[a-z]+)*$`)
+
+	// Hexadecimal numbers (OSPF sequence numbers, checksums, ether-types,
+	// flexible-match masks)
+	hexNumberPattern = regexp.MustCompile(`^0[xX][0-9a-fA-F]+$`)
+
+	// IS-IS levels: "L1", "L2", "L1L2", or the numeric level column (1, 2, 3)
+	isisLevelPattern        = regexp.MustCompile(`^L(1|2|1L2)$`)
+	isisNumericLevelPattern = regexp.MustCompile(`^[123]$`)
+
 	// Show output state keywords
 	statesGood = map[string]bool{
-		"up": true, "establ": true, "established": true,
+		"up": true, "establ": true, "established": true, "valid": true,
 		"full": true, "master": true, "primary": true,
 		"enabled": true, "ok": true, "online": true,
 		"running": true, "ready": true, "complete": true,
+		// Commit confirmed
+		"confirmed": true,
+		// GRES/NSR replication ("IS-IS  Synchronized")
+		"synchronized": true,
 	}
 
 	statesBad = map[string]bool{
 		"down": true, "idle": true, "failed": true,
-		"error": true, "offline": true, "disabled": true,
-		"unreachable": true, "timeout": true,
+		"error": true, "offline": true, "disabled": true, "invalid": true,
+		"unreachable": true, "timeout": true, "rejected": true,
 		// BGP non-established states
 		"active": true, "connect": true,
 		"opensent": true, "openconfirm": true,
@@ -428,12 +750,14 @@ var (
 		"exchange": true, "loading": true,
 		// General
 		"flapping": true, "pending": true, "waiting": true,
-		"starting": true, "stopping": true,
+		"starting": true, "stopping": true, "initializing": true,
 	}
 
 	statesNeutral = map[string]bool{
 		"inactive": true, "standby": true, "backup": true,
 		"n/a": true, "none": true,
+		// BFD sessions shut down by the operator
+		"admindown": true,
 	}
 
 	columnHeaders = map[string]bool{
@@ -449,6 +773,136 @@ var (
 		"outq": true, "prefixes": true, "paths": true,
 	}
 
+	// DHCP client states in "show dhcp server binding" and "show dhcp relay
+	// binding", upper case in the State column
+	dhcpBindingStates = map[string]TokenType{
+		"BOUND":      TokenStateGood,
+		"RELEASE":    TokenStateNeutral,
+		"INIT":       TokenStateWarning,
+		"SELECTING":  TokenStateWarning,
+		"REQUESTING": TokenStateWarning,
+		"RENEWING":   TokenStateWarning,
+		"REBINDING":  TokenStateWarning,
+	}
+
+	// "show dhcp server binding" column headers
+	dhcpBindingHeaders = map[string]bool{
+		"ip": true, "address": true, "session": true, "id": true,
+		"hardware": true, "expires": true, "state": true, "interface": true,
+	}
+
+	// "show chassis fpc" column headers, over two header lines
+	chassisFPCHeaders = map[string]bool{
+		"slot": true, "temp": true, "cpu": true, "memory": true,
+		"utilization": true, "dram": true, "heap": true, "buffer": true,
+	}
+
+	// "show route forwarding-table" column headers
+	forwardingTableHeaders = map[string]bool{
+		"destination": true, "rtref": true, "nhref": true, "netif": true, "index": true,
+	}
+
+	// "show ethernet-switching table" column headers
+	ethernetSwitchingHeaders = map[string]bool{
+		"mac": true, "address": true, "age": true,
+	}
+
+	// "show ospf database" column headers
+	ospfDatabaseHeaders = map[string]bool{
+		"id": true, "adv": true, "rtr": true, "seq": true, "age": true,
+		"opt": true, "cksum": true, "len": true,
+	}
+
+	// "show isis adjacency" column headers
+	isisHeaders = map[string]bool{
+		"system": true, "hold": true, "snpa": true,
+	}
+
+	// "show bfd session" column headers, over two header lines
+	bfdHeaders = map[string]bool{
+		"address": true, "detect": true, "transmit": true, "time": true,
+		"interval": true, "multiplier": true,
+	}
+
+	// "show system storage" column headers
+	storageHeaders = map[string]bool{
+		"filesystem": true, "size": true, "used": true, "avail": true,
+		"capacity": true, "use%": true, "mounted": true, "on": true,
+	}
+
+	// tableHeaders are the header lines of show tables whose column names
+	// are ordinary words ("Size", "Used"), so they are only headers on the
+	// table's own header line
+	tableHeaders = []tableHeader{
+		{"storage", []string{"Mounted on"}, storageHeaders},
+		{"dhcp binding", []string{"Hardware address", "Expires"}, dhcpBindingHeaders},
+		{"bfd session", []string{"Detect", "Transmit"}, bfdHeaders},
+		{"bfd session", []string{"Interval", "Multiplier"}, bfdHeaders},
+		{"isis adjacency", []string{"Hold", "SNPA"}, isisHeaders},
+		{"ospf database", []string{"Adv Rtr", "Cksum"}, ospfDatabaseHeaders},
+		{"ethernet switching", []string{"MAC address", "Age"}, ethernetSwitchingHeaders},
+		{"chassis fpc", []string{"CPU Utilization", "Memory"}, chassisFPCHeaders},
+		{"chassis fpc", []string{"Slot", "DRAM"}, chassisFPCHeaders},
+		{"forwarding table", []string{"RtRef", "Netif"}, forwardingTableHeaders},
+	}
+
+	// tableStates are the states of show tables whose states are ordinary
+	// words ("Static", "Flood"), keyed by the tableHeaders table they are
+	// states in, so they are only states in that table's rows
+	tableStates = map[string]map[string]TokenType{
+		// Ethernet switching table entry types
+		"ethernet switching": {
+			"learn": TokenStateGood, "flood": TokenStateWarning, "static": TokenStateNeutral,
+		},
+		// FPC/PIC states (installed but not yet online, or an empty slot)
+		"chassis fpc": {
+			"present": TokenStateWarning, "empty": TokenStateNeutral,
+		},
+		// Forwarding table next-hop types: unicast, discard and reject, and
+		// receive, local, indirect, ...
+		"forwarding table": {
+			"ucst": TokenStateGood,
+			"dscd": TokenStateBad, "rjct": TokenStateBad,
+			"recv": TokenStateNeutral, "locl": TokenStateNeutral, "indr": TokenStateNeutral,
+			"rslv": TokenStateNeutral, "bcst": TokenStateNeutral, "mcst": TokenStateNeutral,
+			"mdsc": TokenStateNeutral, "ulst": TokenStateNeutral,
+		},
+	}
+
+	// OSPF database LSA types, which start each line of "show ospf database"
+	lsaTypes = map[string]bool{
+		"router": true, "network": true, "summary": true, "asbrsum": true,
+		"extern": true, "nssa": true, "opaqarea": true, "link": true,
+	}
+
+	// Boolean leaf values in config ("graceful-restart on", "no-redirects off")
+	booleanValues = map[string]bool{
+		"on": true, "off": true, "true": true, "false": true,
+	}
+
+	// IS-IS adjacency states that are followed by a hold time
+	isisAdjacencyStates = map[string]bool{
+		"up": true, "down": true, "initializing": true, "rejected": true,
+	}
+
+	// Route attribute labels that precede a value in show output
+	// (", metric 20", "localpref 100", "Preference: 170")
+	routeAttributeLabels = map[string]bool{
+		"metric": true, "metric2": true, "localpref": true,
+		"preference": true, "preference2": true, "med": true,
+		"local-preference": true,
+	}
+
+	// Units following a timer count in show output ("in 10 minutes")
+	timerUnits = map[string]bool{
+		"second": true, "seconds": true, "minute": true, "minutes": true,
+	}
+
+	// MPLS label stack operations in next-hop lines ("Swap 299824", "Pop")
+	labelOperations = map[string]bool{
+		"swap": true, "push": true, "pop": true,
+	}
+
 	statusSymbols = map[string]bool{
 		"*": true, "+": true, "-": true, ">": true,
 		"B": true, "O": true, "I": true, "S": true,
@@ -458,30 +912,125 @@ var (
 	// Show output regex patterns
 	timeDurationPattern  = regexp.MustCompile(`^(\d+[wdhms])+$|^\d+:\d{2}(:\d{2})?$`)
 	percentagePattern    = regexp.MustCompile(`^\d+(\.\d+)?%$`)
-	byteSizePattern      = regexp.MustCompile(`^\d+(\.\d+)?[KMGTP][Bb]?$`)
-	routeProtocolPattern = regexp.MustCompile(`^\[(BGP|OSPF|OSPF3|ISIS|RIP|Static|Direct|Local|Aggregate)/\d+\]$`)
-	tableNamePattern     = regexp.MustCompile(`^(inet|inet6|mpls|bgp|iso|l2vpn)\.\d+:?$`)
-	tabularPattern       = regexp.MustCompile(`\w+\s{2,}\w+\s{2,}\w+`)
+	byteSizePattern      = regexp.MustCompile(`^\d+(\.\d+)?([KMGTP][Bb]?|B)$`)
+	routeProtocolPattern = regexp.MustCompile(`^\[(` + routeProtocolNames + `)/\d+\]$`)
+	tableNamePattern     = regexp.MustCompile(`^:?([a-z0-9_][\w-]*\.)*(` + tableFamilies + `)\.\d+:?$`)
+	mplsLabelPattern     = regexp.MustCompile(`^\d+(\(\w+\))?$`) // 299824, 300000, 299776(top)
+	// routeAttributePattern matches the label that starts an attribute line
+	// of an extensive route entry ("AS path:", "*BGP    Preference:");
+	// group 1 is the protocol, group 2 the label. "Router ID:" is left out,
+	// as indented it also starts OSPF neighbor detail lines.
+	routeAttributePattern = regexp.MustCompile(`^\s*(?:[*+-]?(` + routeProtocolNames + `)\s+)?(AS path|Communities|Localpref|MED|Origin|Next hop type|Protocol next hop|Indirect next hop|Next hop|Local AS|Peer AS|Cluster list|Originator ID|Aggregator|Age|Metric2?|Preference2?|Validation State|Task|Source|State|Session Id|Announcement bits \(\d+\)|Address|Next-hop reference count)\s*:`)
+	// fixedSizeDevicePattern matches the devices of pseudo and read-only
+	// filesystems in "show system storage"
+	fixedSizeDevicePattern = regexp.MustCompile(`^(devfs|procfs|fdescfs|/dev/md\d+\.uzip)$`)
+	// sessionEndpointPattern matches address/port pairs in flow session output;
+	// group 1 is the address
+	sessionEndpointPattern = regexp.MustCompile(`^((?:\d{1,3}\.){3}\d{1,3}|[0-9a-fA-F]*:[0-9a-fA-F:]+)/\d+$`)
+	tabularPattern         = regexp.MustCompile(`\w+\s{2,}\w+\s{2,}\w+`)
+
+	// Timestamps in show output ("2024-01-15 10:30:00 UTC", "10:30AM")
+	datePattern      = regexp.MustCompile(`^\d{4}-\d{2}-\d{2}$`)
+	clockTimePattern = regexp.MustCompile(`^\d{1,2}:\d{2}(:\d{2})?$`)
+	clockAMPMPattern = regexp.MustCompile(`^\d{1,2}:\d{2}[AaPp][Mm]$`)
+	timeZonePattern  = regexp.MustCompile(`^[A-Z]{3,4}$`)
+
+	// Route entries whose protocol lacks the active "*" or "+" marker
+	// ("10.0.1.0/24   [OSPF/10] 00:10:00", "-[BGP/170]" for last active)
+	inactiveRoutePattern = regexp.MustCompile(`(^|[\s-])\[(` + routeProtocolNames + `)/\d+\]`)
+
+	// Progress of "request system reboot/halt/power-off" ("Shutdown NOW!",
+	// "*** FINAL System shutdown message from admin@r1 ***")
+	requestProgressPattern = regexp.MustCompile(`(?i)^[\s*]*(shutdown now|system going down|final system shutdown|rebooting|halting|powering off|waiting \(max \d+ seconds\))`)
+
+	// Ping and traceroute latencies in milliseconds ("1.234" of "time=1.234 ms",
+	// "0.987/1.110/1.234/0.123" of the round-trip summary)
+	latencyPattern = regexp.MustCompile(`^\d+(\.\d+)?(/\d+(\.\d+)?)*$`)
+
+	// Ping reply fields whose value is emitted as its own token ("ttl=64")
+	pingFieldPattern = regexp.MustCompile(`^(icmp_seq|ttl|hlim|time)=\d+(\.\d+)?$`)
+
+	// Traceroute hop lines: every probe timed out ("2  * * *"), or a hop
+	// with latencies where some probes may have ("3  10.0.0.1  1.2 ms *  1.1 ms")
+	hopTimeoutPattern = regexp.MustCompile(`^\s*\d{1,2}(\s+\*)+\s*$`)
+	hopLinePattern    = regexp.MustCompile(`^\s*\d{1,2}\s+\S.*\sms\b`)
+
+	// Traceroute unreachable annotations ("!H", "!N", "!X", "!F-1500", "!<num>")
+	unreachablePattern = regexp.MustCompile(`^!([HNPSXVCAZ]|F-?\d*|\d+)$`)
+
+	// Rapid ping progress: "!" per reply, "." per lost packet
+	rapidPingPattern = regexp.MustCompile(`^[!.]*![!.]*$`)
+
+	// Base64 text, optionally padded ("MIIDdzCCAl+gAwIBAgIEAgAAuTAN...==")
+	base64BlobPattern = regexp.MustCompile(`^[A-Za-z0-9+/]+={0,2}$`)
+
+	// Start of a "show system commit" history entry, with the username and
+	// the access method ("0   2024-01-15 10:30:00 UTC by admin via cli")
+	commitEntryPattern = regexp.MustCompile(`^\s*\d+\s+\d{4}-\d{2}-\d{2}\s+\d{2}:\d{2}:\d{2}(\s+[A-Z]{2,5})?\s+by\s+(\S+)(\s+via\s+(\S+))?`)
+
+	// High-availability features whose Enabled/Disabled state is shown by
+	// "show task replication" and "show system switchover", up to the state
+	gresLabelPattern = regexp.MustCompile(`(?i)(replication|switchover|nonstop routing|nonstop bridging):\s*`)
+
+	// BFD times in seconds ("0.900", "3.000")
+	bfdTimePattern = regexp.MustCompile(`^\d+\.\d{3}$`)
+
+	// PFE and queue counter lines ("Software input low drops  :  0",
+	// "Tail-dropped packets :  12  3 pps"); group 1 is the label, group 2 the
+	// count and group 3 the optional rate
+	dropCounterPattern = regexp.MustCompile(`^\s*(\S.*?)\s*:\s*(\d+)(?:\s+(\d+)\s+[bp]ps)?\s*$`)
+	dropReasonPattern  = regexp.MustCompile(`(?i)drop|discard|error`)
+
+	// Load averages ("load averages: 0.52, 0.48, 0.45")
+	loadAveragePattern = regexp.MustCompile(`^\d+\.\d+$`)
 
 	// Prompt patterns
 	// Matches: user@hostname> or user@hostname# (with optional {master:N}[edit ...] prefix)
 	// Allows optional command after the prompt character
 	// The \n? at the end handles lines that include trailing newlines
 	// Group 3 captures leading whitespace/control chars (like \r) to preserve them
-	promptPattern = regexp.MustCompile(`^(\{[^}]+\})?(\[edit[^\]]*\])?([\s\x00-\x1f]*)([\w-]+)@([\w.-]+)([>#])(\s*)(.*?)\n?$`)
+	// Group 5 (hostname) is a dotted name (r1, fw-01.example.com, 192.168.1.1)
+	// or an IPv6 address (2001:db8::1); dotted names can't end with a dot
+	promptPattern = regexp.MustCompile(`^(\{[^}]+\})?(\[edit[^\]]*\])?([\s\x00-\x1f]*)([\w-]+)@([\w-]+(?:\.[\w-]+)*|[0-9a-fA-F]*:[0-9a-fA-F:]+)([>#])(\s*)(.*?)\n?$`)
 )
 
 // New creates a new Lexer for the given input.
 // The lexer auto-detects whether input is config syntax or show command output.
 func New(input string) *Lexer {
 	return &Lexer{
-		input: input,
-		pos:   0,
-		line:  1,
-		col:   1,
+		input:         input,
+		pos:           0,
+		line:          1,
+		col:           1,
+		commentPrefix: defaultCommentPrefixes,
 	}
 }
 
+// defaultCommentPrefixes start a line comment unless SetCommentPrefixes is used
+var defaultCommentPrefixes = []string{"#"}
+
+// SetFirstWordIsCommand makes an otherwise unclassified, verb-like word at the
+// start of a config line ("override interfaces ...") a TokenCommand, so
+// commands missing from the keyword tables still stand out.
+func (l *Lexer) SetFirstWordIsCommand(on bool) {
+	l.firstWordIsCommand = on
+}
+
+// SetCommentPrefixes replaces the prefixes that start a line comment.
+// Use SetCommentPrefixes("#", "!") for snippets pasted from Cisco-style configs.
+func (l *Lexer) SetCommentPrefixes(prefixes ...string) {
+	l.commentPrefix = prefixes
+}
+
+// SetCaseSensitive controls whether config keywords must be lower case, as
+// the JunOS CLI requires. By default they match in any case, so a config
+// pasted with upper case keywords ("SET INTERFACES GE-0/0/0") is colored like
+// the lower case original; token values always keep their original case.
+// Interfaces, addresses and show output states match in any case either way.
+func (l *Lexer) SetCaseSensitive(on bool) {
+	l.caseSensitive = on
+}
+
 // Tokenize processes the input and returns all tokens.
 // If parseMode is Auto (default), it auto-detects whether the input
 // is configuration syntax or show command output based on content heuristics.
@@ -494,7 +1043,12 @@ func (l *Lexer) Tokenize() []Token {
 	}
 
 	for l.pos < len(l.input) {
+		inDefaults := l.defaultsDepth > 0
 		token := l.nextToken()
+		// Factory defaults, braces included, are subdued so user config stands out
+		if (inDefaults || l.defaultsDepth > 0) && token.Type != TokenText {
+			token.Type = TokenStateNeutral
+		}
 		if token.Type != TokenText || token.Value != "" {
 			tokens = append(tokens, token)
 		}
@@ -614,7 +1168,10 @@ func (l *Lexer) tryTokenizePrompt(input string) []Token {
 
 	// Tokenize command after prompt if present (group 8)
 	if matches[8] != "" {
-		cmdLexer := New(strings.TrimSpace(matches[8]))
+		cmdLexer := New(matches[8])
+		cmdLexer.commentPrefix = l.commentPrefix
+		cmdLexer.firstWordIsCommand = l.firstWordIsCommand
+		cmdLexer.caseSensitive = l.caseSensitive
 		cmdTokens := cmdLexer.Tokenize()
 		for _, tok := range cmdTokens {
 			tok.Column = col
@@ -657,7 +1214,7 @@ func (l *Lexer) nextToken() Token {
 
 	// Handle different token types
 	switch {
-	case ch == '#':
+	case l.atCommentPrefix():
 		return l.scanComment()
 	case ch == '/' && l.peek(1) == '*':
 		return l.scanBlockComment()
@@ -665,28 +1222,44 @@ func (l *Lexer) nextToken() Token {
 		isValue := l.expectingValue
 		l.expectingValue = false
 		token := l.scanString('"')
-		if isValue {
+		if isValue || l.secretEndsAt(l.pos) {
 			token.Type = TokenValue
 		}
+		if tokenType, ok := l.takePipeArgument(); ok {
+			token.Type = tokenType
+		}
 		return token
 	case ch == '\'':
 		isValue := l.expectingValue
 		l.expectingValue = false
 		token := l.scanString('\'')
-		if isValue {
+		if isValue || l.secretEndsAt(l.pos) {
 			token.Type = TokenValue
 		}
+		if tokenType, ok := l.takePipeArgument(); ok {
+			token.Type = tokenType
+		}
 		return token
 	case ch == '{' || ch == '}':
 		l.expectingValue = false
+		l.expectingType = TokenText
+		l.pipeArgument = TokenText
+		l.trackInstanceBlock(ch)
 		return l.scanBrace()
 	case ch == ';':
 		l.expectingValue = false
+		l.expectingType = TokenText
+		l.pipeArgument = TokenText
+		l.inReferenceList = false
 		return l.scanSemicolon()
 	case ch == '<':
 		return l.scanWildcard()
 	case ch == '*':
+		timeout := l.hopTimeoutType()
 		l.advance()
+		if timeout != TokenText {
+			return Token{Type: timeout, Value: "*", Line: startLine, Column: startCol}
+		}
 		return Token{Type: TokenWildcard, Value: "*", Line: startLine, Column: startCol}
 	case isWhitespace(ch):
 		return l.scanWhitespace()
@@ -751,10 +1324,13 @@ func (l *Lexer) scanComment() Token {
 	startLine, startCol := l.line, l.col
 	start := l.pos
 
-	// Check for annotation (##)
+	// Check for annotation (##); SECRET-DATA markers are dimmed further
 	tokenType := TokenComment
-	if l.peek(1) == '#' {
+	if l.input[l.pos] == '#' && l.peek(1) == '#' {
 		tokenType = TokenAnnotation
+		if strings.HasPrefix(l.input[l.pos:], secretMarker) {
+			tokenType = TokenStateNeutral
+		}
 	}
 
 	// Read until end of line
@@ -822,6 +1398,31 @@ func (l *Lexer) scanString(quote byte) Token {
 	}
 }
 
+// trackInstanceBlock updates the brace depth for a brace about to be
+// scanned, noting when it opens or closes an instance section or a
+// junos-defaults group
+func (l *Lexer) trackInstanceBlock(brace byte) {
+	if brace == '{' {
+		l.braceDepth++
+		if instanceSections[l.lastToken] {
+			l.instanceDepth = l.braceDepth
+		}
+		if l.lastToken == "junos-defaults" && l.defaultsDepth == 0 {
+			l.defaultsDepth = l.braceDepth
+		}
+		return
+	}
+	if l.braceDepth > 0 {
+		l.braceDepth--
+	}
+	if l.braceDepth < l.instanceDepth {
+		l.instanceDepth = 0
+	}
+	if l.braceDepth < l.defaultsDepth {
+		l.defaultsDepth = 0
+	}
+}
+
 // scanBrace scans { or }
 func (l *Lexer) scanBrace() Token {
 	startLine, startCol := l.line, l.col
@@ -862,9 +1463,11 @@ func (l *Lexer) scanUnquotedValue() Token {
 		l.advance()
 	}
 
-	value := l.input[start:l.pos]
-	// Trim trailing whitespace from the value
-	value = strings.TrimRight(value, " \t")
+	// Leave trailing whitespace for the next token so it isn't dropped
+	value := strings.TrimRight(l.input[start:l.pos], " \t")
+	trimmed := l.pos - start - len(value)
+	l.pos -= trimmed
+	l.col -= trimmed
 
 	return Token{
 		Type:   TokenValue,
@@ -904,6 +1507,12 @@ func (l *Lexer) scanWhitespace() Token {
 		l.advance()
 	}
 
+	// A blank line ends a discard statistics section and a show table
+	if strings.Count(l.input[start:l.pos], "\n") > 1 {
+		l.inDiscardStats = false
+		l.table = ""
+	}
+
 	return Token{
 		Type:   TokenText,
 		Value:  l.input[start:l.pos],
@@ -917,17 +1526,81 @@ func (l *Lexer) scanWord() Token {
 	startLine, startCol := l.line, l.col
 	start := l.pos
 
+	// Port separator of a session endpoint ("/" in "10.0.0.5/51234")
+	if l.expectingPort {
+		l.expectingPort = false
+		if l.input[l.pos] == '/' {
+			l.advance()
+			return Token{Type: TokenOperator, Value: "/", Line: startLine, Column: startCol}
+		}
+	}
+
 	// Read until whitespace or special character
 	for l.pos < len(l.input) {
 		ch := l.input[l.pos]
-		if isWhitespace(ch) || ch == '{' || ch == '}' || ch == ';' || ch == '"' || ch == '\'' || ch == '#' {
+		if isWhitespace(ch) || ch == '{' || ch == '}' || ch == ';' || ch == '"' || ch == '\'' || l.atCommentPrefix() {
 			break
 		}
 		l.advance()
 	}
 
+	// Base64 blobs (certificate lines, keys) are opaque values; classifying
+	// them would only find accidental matches, and cost a regex per pattern.
+	// Inside a PEM block even the short last line of the blob is one.
+	switch word := l.input[start:l.pos]; {
+	case word == "-----BEGIN":
+		l.inPEMBlock = true
+	case word == "-----END":
+		l.inPEMBlock = false
+	case isBase64Blob(word) || (l.inPEMBlock && base64BlobPattern.MatchString(word)):
+		l.lastToken = word
+		return Token{Type: TokenValue, Value: word, Line: startLine, Column: startCol}
+	}
+
+	l.resolveParseMode()
+	if l.parseMode == ParseModeShow {
+		// Ping reply fields ("ttl=64"): emit the name and "=" now, the value separately
+		if pingFieldPattern.MatchString(l.input[start:l.pos]) {
+			l.pos = start + strings.IndexByte(l.input[start:l.pos], '=') + 1
+			l.col = startCol + l.pos - start
+		}
+
+		// Trailing commas separate fields in show output ("State: Active, Timeout: 1790,")
+		if l.pos-start > 1 && l.input[l.pos-1] == ',' {
+			l.pos--
+			l.col--
+		}
+
+		// Route markers attached to the protocol ("+[BGP/170]"): emit the
+		// marker on its own
+		if (l.input[start] == '+' || l.input[start] == '-') && routeProtocolPattern.MatchString(l.input[start+1:l.pos]) {
+			l.pos = start + 1
+			l.col = startCol + 1
+		}
+
+		// A duration in parentheses ("(6w3d 02:30 ago)"): emit the "(" on its own
+		if l.input[start] == '(' && timeDurationPattern.MatchString(l.input[start+1:l.pos]) {
+			l.pos = start + 1
+			l.col = startCol + 1
+		}
+
+		// Session endpoints ("10.0.0.5/51234 --> 93.184.216.34/443"): emit the
+		// address now, the "/" and port as separate tokens
+		if m := sessionEndpointPattern.FindStringSubmatchIndex(l.input[start:l.pos]); m != nil && l.onSessionLine() {
+			l.pos = start + m[3]
+			l.col = startCol + m[3]
+			l.expectingPort = true
+		}
+	}
+
 	word := l.input[start:l.pos]
 	tokenType := l.classifyWord(word)
+	l.lastToken = strings.ToLower(word)
+
+	// The value before a SECRET-DATA marker is an opaque secret
+	if l.secretEndsAt(l.pos) {
+		tokenType = TokenValue
+	}
 
 	return Token{
 		Type:   tokenType,
@@ -939,49 +1612,171 @@ func (l *Lexer) scanWord() Token {
 
 // classifyWord determines the token type for a word
 func (l *Lexer) classifyWord(word string) TokenType {
-	// Auto-detect mode on first classification if needed
-	if l.parseMode == ParseModeAuto && !l.detectedMode {
-		l.parseMode = l.detectParseMode()
-		l.detectedMode = true
+	l.resolveParseMode()
+
+	// Standalone brackets delimit sets like "destination-port [ 80 443 ]"
+	if word == "[" || word == "]" {
+		l.inReferenceList = word == "[" && referenceKeywords[l.lastToken]
+		return TokenOperator
 	}
 
 	lower := strings.ToLower(word)
 
+	// Pipe filters in a command line ("show configuration | match bgp")
+	if word == "|" {
+		return TokenOperator
+	}
+	if l.lastToken == "|" {
+		if argument, ok := pipeFilters[lower]; ok {
+			l.pipeArgument = argument
+			return TokenKeyword
+		}
+	}
+	if tokenType, ok := l.takePipeArgument(); ok {
+		return tokenType
+	}
+
 	if l.parseMode == ParseModeShow {
-		return l.classifyShowWord(word, lower)
+		tokenType := l.classifyShowWord(word, lower)
+		if tokenType == TokenIdentifier && l.namesObject(word) {
+			return TokenValue
+		}
+		return tokenType
+	}
+
+	keyword := lower
+	if l.caseSensitive {
+		keyword = word
+	}
+	tokenType := l.classifyConfigWord(word, keyword)
+	if tokenType == TokenIdentifier && l.namesObject(word) {
+		return TokenValue
 	}
+	// Zone and address-book references are user-defined names, like values
+	if tokenType == TokenIdentifier && (referenceKeywords[l.lastToken] || l.inReferenceList) {
+		return TokenValue
+	}
+	if tokenType == TokenIdentifier && l.firstWordIsCommand && verbPattern.MatchString(word) && l.wordStartsLine(word) {
+		return TokenCommand
+	}
+	return tokenType
+}
+
+// takePipeArgument consumes the pending pipe filter argument, returning
+// its token type and whether one was expected
+func (l *Lexer) takePipeArgument() (TokenType, bool) {
+	argument := l.pipeArgument
+	l.pipeArgument = TokenText
+	return argument, argument != TokenText
+}
 
-	return l.classifyConfigWord(word, lower)
+// resolveParseMode auto-detects the parse mode on first use if needed
+func (l *Lexer) resolveParseMode() {
+	if l.parseMode == ParseModeAuto && !l.detectedMode {
+		l.parseMode = l.detectParseMode()
+		l.detectedMode = true
+	}
 }
 
 // classifyConfigWord handles configuration syntax classification
 func (l *Lexer) classifyConfigWord(word, lower string) TokenType {
+	// Remember the line of a "replace" command for its pattern operands
+	if lower == "replace" {
+		l.replaceLine = l.line
+	}
+
 	// Check if this is a unit number (after "unit" keyword)
 	if l.expectingUnit && unitNumberPattern.MatchString(word) {
 		l.expectingUnit = false
 		return TokenUnit
 	}
 
+	// Value of a keyword with a known value type ("peer-as 65000")
+	if expected := l.expectingType; expected != TokenText {
+		l.expectingType = TokenText
+		if typedValuePatterns[expected].MatchString(word) {
+			return expected
+		}
+	}
+
+	// "set cli terminal xterm"
+	if l.lastToken == "terminal" && terminalTypes[lower] {
+		return TokenValue
+	}
+
+	// Address family names get one color whatever else they are
+	if l.lastToken == "family" && addressFamilies[lower] {
+		return TokenProtocol
+	}
+
+	// Instance names, set style ("set routing-instances CUSTOMER-A ...") or
+	// as the blocks inside an instance section ("CUSTOMER-A {")
+	if instanceSections[l.lastToken] || (l.instanceDepth > 0 && l.braceDepth == l.instanceDepth && l.nextWord() == "{") {
+		return TokenValue
+	}
+
+	// "as-path NAME REGEX": the name is an identifier (even "AS1") and the
+	// rest of the line, quoted or not, is a single regex value
+	if l.lastToken == "as-path" {
+		if l.nextWord() != "" {
+			l.expectingValue = true
+		}
+		return TokenIdentifier
+	}
+
+	// "replace pattern OLD with NEW": both operands are values unless they
+	// are addresses, interfaces or other recognized patterns
+	if (l.lastToken == "pattern" || l.lastToken == "with") && lower != "with" && l.replaceLine == l.line {
+		if tokenType := l.classifySharedPatterns(word); tokenType != TokenIdentifier {
+			return tokenType
+		}
+		return TokenValue
+	}
+
+	// Confederation members are AS numbers ("confederation 65000 members [ 65001 65002 ]")
+	if asValuePattern.MatchString(word) && l.onConfederationLine() {
+		return TokenASN
+	}
+
+	// Community regex members ("members 65000:1.*") stay a single value
+	if l.lastToken == "members" && regexMetaPattern.MatchString(word) {
+		return TokenValue
+	}
+
+	// DHCP option codes ("option 82") stand out like unit numbers
+	if l.lastToken == "option" && unitNumberPattern.MatchString(word) {
+		return TokenUnit
+	}
+
+	// Hex option-82 IDs and option data are values
+	if hexValueKeywords[l.lastToken] && hexStringPattern.MatchString(word) {
+		return TokenValue
+	}
+
 	// Check for AS number format (AS65000, as65001)
 	if asnPattern.MatchString(word) {
 		return TokenASN
 	}
 
 	// Check keyword maps first
+	if destructiveCommands[lower] {
+		return TokenCommandDestructive
+	}
 	if commands[lower] {
-		l.lastToken = lower
 		return TokenCommand
 	}
 	if sections[lower] {
-		l.lastToken = lower
 		return TokenSection
 	}
 	if protocols[lower] {
-		l.lastToken = lower
 		return TokenProtocol
 	}
+	// Route attributes are policy actions after "then", but plain keywords
+	// elsewhere (e.g. "ospf area 0 interface ge-0/0/0 metric 10")
+	if routeAttributeLabels[lower] && l.lastToken != "then" {
+		return TokenKeyword
+	}
 	if actions[lower] {
-		l.lastToken = lower
 		return TokenAction
 	}
 	if keywords[lower] {
@@ -989,13 +1784,16 @@ func (l *Lexer) classifyConfigWord(word, lower string) TokenType {
 		if valueKeywords[lower] {
 			l.expectingValue = true
 		}
+		l.expectingType = typedValueKeywords[lower]
 		// Set flag after "unit" keyword to classify next number as TokenUnit
 		if lower == "unit" {
 			l.expectingUnit = true
 		}
-		l.lastToken = lower
 		return TokenKeyword
 	}
+	if booleanValues[lower] {
+		return TokenValue
+	}
 
 	// Fall through to shared patterns
 	return l.classifySharedPatterns(word)
@@ -1003,7 +1801,178 @@ func (l *Lexer) classifyConfigWord(word, lower string) TokenType {
 
 // classifyShowWord handles show command output classification
 func (l *Lexer) classifyShowWord(word, lower string) TokenType {
-	// State classification (highest priority for visibility)
+	// A table header line starts the table its rows belong to
+	l.lineTableHeaders()
+
+	// A discard statistics section runs over consecutive counter lines; a
+	// prompt, another header or any other line ends it
+	if l.inDiscardStats && l.dropCounterMatch() == nil {
+		l.inDiscardStats = false
+	}
+
+	// Inactive route entries get their protocol and "-" marker dimmed so the
+	// active routes stand out; the prefix and next hop keep their colors
+	if (word == "-" || routeProtocolPattern.MatchString(word)) && l.onInactiveRouteLine() {
+		return TokenStateNeutral
+	}
+	// as does the reason in extensive output ("Inactive reason: Route Preference")
+	if lower == "reason:" && l.lastToken == "inactive" {
+		l.reasonLine = l.line
+	}
+	if l.reasonLine == l.line || (lower == "inactive" && l.nextWord() == "reason:") {
+		return TokenStateNeutral
+	}
+
+	// Request command confirmations ("Reboot the system ? [yes,no] (no) yes"):
+	// the options and default are keywords, the answer a value
+	if lower == "[yes,no]" || ((lower == "(no)" || lower == "(yes)") && l.lastToken == "[yes,no]") {
+		return TokenKeyword
+	}
+	if (lower == "yes" || lower == "no") && (l.lastToken == "[yes,no]" || l.lastToken == "(no)" || l.lastToken == "(yes)") {
+		return TokenValue
+	}
+
+	// Commit history entries: the user who committed, like a prompt's, and
+	// how ("by admin via cli")
+	if l.lastToken == "by" || l.lastToken == "via" {
+		switch user, method := l.commitEntryOffsets(); l.pos - len(word) {
+		case user:
+			return TokenPromptUser
+		case method:
+			return TokenKeyword
+		}
+	}
+
+	// Attributes of extensive route entries ("AS path: 65002 I")
+	if tokenType, ok := l.classifyRouteAttribute(word, lower); ok {
+		return tokenType
+	}
+
+	// GRES/NSR replication state: "Not synchronized" is bad as a phrase, and a
+	// disabled high-availability feature is an option, not a failure
+	if (lower == "not" && strings.ToLower(l.nextWord()) == "synchronized") ||
+		(lower == "synchronized" && l.lastToken == "not") {
+		return TokenStateBad
+	}
+	if lower == "disabled" && l.pos-len(word) == l.gresStateOffset() {
+		return TokenStateNeutral
+	}
+
+	// Shutdown and reboot progress lines are warnings
+	if l.onRequestProgressLine() {
+		return TokenStateWarning
+	}
+
+	// Ping and traceroute: latencies and their "ms" unit, reply fields
+	// ("icmp_seq=1 ttl=64 time=1.234 ms"), unreachable annotations and
+	// rapid ping progress ("!!!.!")
+	if lower == "ms" && latencyPattern.MatchString(l.lastToken) {
+		return TokenTimeDuration
+	}
+	if latencyPattern.MatchString(word) && strings.ToLower(l.nextWord()) == "ms" {
+		return TokenTimeDuration
+	}
+	if tokenType, ok := pingFieldTypes[l.lastToken]; ok && latencyPattern.MatchString(word) {
+		return tokenType
+	}
+	if unreachablePattern.MatchString(word) {
+		return TokenStateBad
+	}
+	if rapidPingPattern.MatchString(word) {
+		if strings.Contains(word, ".") {
+			return TokenStateWarning
+		}
+		return TokenStateGood
+	}
+
+	// IS-IS adjacency: level, state, then hold time ("r2  2  Up  23"). A
+	// bare level number only counts inside the adjacency table.
+	afterLevel := l.afterLevel
+	l.afterLevel = false
+	if l.expectingHold {
+		l.expectingHold = false
+		if unitNumberPattern.MatchString(word) {
+			return TokenTimeDuration
+		}
+	}
+	if isisLevelPattern.MatchString(word) ||
+		(isisNumericLevelPattern.MatchString(word) && l.table == "isis adjacency" && isisAdjacencyStates[strings.ToLower(l.nextWord())]) {
+		l.afterLevel = true
+		return TokenLevel
+	}
+	if afterLevel && isisAdjacencyStates[lower] {
+		l.expectingHold = true
+	}
+
+	// Label following an MPLS label operation
+	if l.expectingLabel {
+		l.expectingLabel = false
+		if mplsLabelPattern.MatchString(word) {
+			return TokenNumber
+		}
+	}
+
+	// MPLS label operations, either inside an mpls.N table or following a
+	// next-hop ("via ge-0/0/0.0, Swap 299824")
+	if labelOperations[strings.TrimSuffix(lower, ",")] && (l.inMPLSTable || strings.HasSuffix(l.lastToken, ",")) {
+		l.expectingLabel = lower != "pop"
+		return TokenAction
+	}
+
+	// BFD detection time and transmit interval ("0.900     0.300        3"),
+	// followed by another time or the multiplier
+	if bfdTimePattern.MatchString(word) {
+		if next := l.nextWord(); bfdTimePattern.MatchString(next) || unitNumberPattern.MatchString(next) {
+			return TokenTimeDuration
+		}
+	}
+
+	// PFE statistics: a "discard statistics:" header starts a section of
+	// drop counters, any other "statistics:" header ends it (see also the
+	// start of classifyShowWord and scanWhitespace)
+	if lower == "statistics:" {
+		l.inDiscardStats = l.lineContains("discard")
+	}
+	if tokenType, ok := l.classifyDropCounter(word); ok {
+		return tokenType
+	}
+
+	// Commit confirmed timers ("rolled back in 10 minutes unless confirmed")
+	if unitNumberPattern.MatchString(word) && timerUnits[strings.ToLower(l.nextWord())] {
+		return TokenTimeDuration
+	}
+	// and the rollback itself ("will be automatically rolled back", "automatic
+	// rollback complete"), not rollbacks in commit history listings
+	if (lower == "rolled" && strings.ToLower(l.nextWord()) == "back") || (lower == "back" && l.lastToken == "rolled") {
+		return TokenStateWarning
+	}
+	if lower == "rollback" && l.lastToken == "automatic" {
+		return TokenStateWarning
+	}
+	// "not confirmed" and the condition of a pending rollback ("unless
+	// confirmed") are warnings too
+	if lower == "confirmed" && (l.lastToken == "not" || l.lastToken == "unless") {
+		return TokenStateWarning
+	}
+
+	// DHCP binding states, and the seconds left on the lease before them
+	if state, ok := dhcpBindingStates[word]; ok {
+		return state
+	}
+	if unitNumberPattern.MatchString(word) {
+		if _, ok := dhcpBindingStates[l.nextWord()]; ok {
+			return TokenTimeDuration
+		}
+	}
+
+	// State classification (highest priority for visibility). An active flow
+	// session is healthy, unlike an active BGP peer.
+	if lower == "active" && l.onSessionLine() {
+		return TokenStateGood
+	}
+	if state, ok := tableStates[l.table][lower]; ok {
+		return state
+	}
 	if statesGood[lower] {
 		return TokenStateGood
 	}
@@ -1017,17 +1986,54 @@ func (l *Lexer) classifyShowWord(word, lower string) TokenType {
 		return TokenStateNeutral
 	}
 
+	// Session direction arrow in flow session output
+	if word == sessionArrow {
+		return TokenStatusSymbol
+	}
+
 	// Status symbols are single-char route markers (*, +, -, >) or protocol
 	// indicators (B, O, I, S, L, D). Limit to 2 chars to avoid matching words.
 	if len(word) <= 2 && statusSymbols[word] {
 		return TokenStatusSymbol
 	}
 
+	// Timestamps: a date, then optionally its time and time zone
+	inTimestamp := l.inTimestamp
+	l.inTimestamp = false
+	if datePattern.MatchString(word) {
+		l.inTimestamp = true
+		return TokenTimestamp
+	}
+	if inTimestamp && clockTimePattern.MatchString(word) {
+		l.inTimestamp = true
+		return TokenTimestamp
+	}
+	if inTimestamp && timeZonePattern.MatchString(word) {
+		return TokenTimestamp
+	}
+	if clockAMPMPattern.MatchString(word) {
+		return TokenTimestamp
+	}
+
+	// Load averages, flagged when the system is busy
+	if loadAveragePattern.MatchString(word) && l.lineContains("load average") {
+		if load, err := strconv.ParseFloat(word, 64); err == nil && load >= highLoadAverage {
+			return TokenStateWarning
+		}
+		return TokenNumber
+	}
+
 	// Show-specific patterns
 	if timeDurationPattern.MatchString(word) {
 		return TokenTimeDuration
 	}
 	if percentagePattern.MatchString(word) {
+		// Filesystem capacity, followed by its mount point. Pseudo and
+		// read-only filesystems always show as full, so their capacity is a
+		// plain number that the highlighter's percentage bands don't flag.
+		if mount := l.nextWord(); strings.HasPrefix(mount, "/") && fixedSizeFilesystem(l.lineBefore(word), mount) {
+			return TokenNumber
+		}
 		return TokenPercentage
 	}
 	if byteSizePattern.MatchString(word) {
@@ -1037,24 +2043,106 @@ func (l *Lexer) classifyShowWord(word, lower string) TokenType {
 		return TokenRouteProtocol
 	}
 	if tableNamePattern.MatchString(lower) {
+		// A "table.N:" header starts a new table; remember if it's MPLS
+		if strings.HasSuffix(lower, ":") {
+			l.inMPLSTable = strings.HasPrefix(lower, "mpls.")
+		}
 		return TokenTableName
 	}
 
+	// LSA type at the start of an OSPF database line ("Router  10.0.0.1 ...")
+	if lsaTypes[lower] && l.wordStartsLine(word) {
+		return TokenColumnHeader
+	}
+
+	// Route attribute labels followed by their value, e.g. ", metric 20"
+	if routeAttributeLabels[strings.TrimSuffix(lower, ":")] && l.nextWordIsNumber() {
+		return TokenKeyword
+	}
+
 	// Column headers
 	if columnHeaders[lower] {
 		return TokenColumnHeader
 	}
+	if l.lineTableHeaders()[lower] {
+		return TokenColumnHeader
+	}
 
 	// Fall through to shared patterns (IPs, interfaces, etc.)
 	return l.classifySharedPatterns(word)
 }
 
+// fixedSizeFilesystem reports whether a "show system storage" line is for a
+// pseudo or read-only filesystem (devfs, package images), which always shows
+// as full. line is the text before the capacity.
+func fixedSizeFilesystem(line, mount string) bool {
+	fields := strings.Fields(line)
+	if len(fields) > 0 && fixedSizeDevicePattern.MatchString(fields[0]) {
+		return true
+	}
+	return strings.HasPrefix(mount, "/packages/") || strings.HasPrefix(mount, "/.mount/packages/")
+}
+
+// classifyDropCounter classifies the words of a PFE or queue drop counter
+// line ("Software input low drops  :  12", "RED-dropped packets :  67  2 pps"):
+// the drop reason is an identifier and a nonzero count or rate is bad for
+// drops and errors, a warning for other discards. ok is false for words that
+// are not part of a drop counter.
+func (l *Lexer) classifyDropCounter(word string) (tokenType TokenType, ok bool) {
+	m := l.dropCounterMatch()
+	if m == nil {
+		return 0, false
+	}
+	label := strings.ToLower(l.input[m[2]:m[3]])
+	if !l.inDiscardStats && !dropReasonPattern.MatchString(label) {
+		return 0, false
+	}
+
+	start := l.pos - len(word)
+	switch {
+	case start < m[3]:
+		return TokenIdentifier, true
+	case start != m[4] && start != m[6]:
+		return 0, false
+	case strings.Trim(word, "0") == "":
+		return TokenNumber, true
+	case strings.Contains(label, "drop") || strings.Contains(label, "error"):
+		return TokenStateBad, true
+	default:
+		return TokenStateWarning, true
+	}
+}
+
+// dropCounterMatch returns the dropCounterPattern match for the current line,
+// as offsets into the input, or nil. The match is cached per line so long
+// lines aren't rescanned for every word.
+func (l *Lexer) dropCounterMatch() []int {
+	if l.dropLine != l.line {
+		l.dropLine = l.line
+		line, start := l.currentLine()
+		l.dropCounter = dropCounterPattern.FindStringSubmatchIndex(line)
+		for i := range l.dropCounter {
+			if l.dropCounter[i] >= 0 {
+				l.dropCounter[i] += start
+			}
+		}
+	}
+	return l.dropCounter
+}
+
 // classifySharedPatterns handles patterns common to both config and show modes
 func (l *Lexer) classifySharedPatterns(word string) TokenType {
 	// Check patterns - order matters! More specific patterns first.
+	if urlPattern.MatchString(word) || pathPattern.MatchString(word) {
+		return TokenPath
+	}
 	if interfacePattern.MatchString(word) {
 		return TokenInterface
 	}
+	// OIDs before IPv4 so "1.3.6.1" isn't taken for an address
+	if oidPattern.MatchString(word) || mibObjectPattern.MatchString(word) {
+		return TokenOID
+	}
 	if ipv4PrefixPattern.MatchString(word) {
 		return TokenIPv4Prefix
 	}
@@ -1074,7 +2162,11 @@ func (l *Lexer) classifySharedPatterns(word string) TokenType {
 	if ipv6Pattern.MatchString(word) {
 		return TokenIPv6
 	}
-	if numberPattern.MatchString(word) {
+	// Hex values: ether-types, flexible-match masks, OSPF sequence numbers
+	if hexNumberPattern.MatchString(word) {
+		return TokenNumber
+	}
+	if numberPattern.MatchString(word) || signedNumberPattern.MatchString(word) || numberRangePattern.MatchString(word) {
 		return TokenNumber
 	}
 
@@ -1103,6 +2195,303 @@ func (l *Lexer) peek(offset int) byte {
 	return 0
 }
 
+// lineContains reports whether the line containing the current position contains substr
+func (l *Lexer) lineContains(substr string) bool {
+	line, _ := l.currentLine()
+	return strings.Contains(line, substr)
+}
+
+// currentLine returns the line containing the current position, without its
+// newline, and the offset in the input where it starts
+func (l *Lexer) currentLine() (string, int) {
+	start := strings.LastIndexByte(l.input[:l.pos], '\n') + 1
+	end := strings.IndexByte(l.input[l.pos:], '\n')
+	if end < 0 {
+		end = len(l.input)
+	} else {
+		end += l.pos
+	}
+	return l.input[start:end], start
+}
+
+// isBase64Blob reports whether word is a long run of base64 characters with
+// both letter cases, like a line of a PEM certificate
+func isBase64Blob(word string) bool {
+	if len(word) < minBase64Blob || !base64BlobPattern.MatchString(word) {
+		return false
+	}
+	return strings.ContainsAny(word, "ABCDEFGHIJKLMNOPQRSTUVWXYZ") && strings.ContainsAny(word, "abcdefghijklmnopqrstuvwxyz")
+}
+
+// lineBefore returns the text of the current line before word, the word
+// just scanned
+func (l *Lexer) lineBefore(word string) string {
+	start := l.pos - len(word)
+	return l.input[strings.LastIndexByte(l.input[:start], '\n')+1 : start]
+}
+
+// atCommentPrefix reports whether a comment prefix starts at the current position
+func (l *Lexer) atCommentPrefix() bool {
+	for _, prefix := range l.commentPrefix {
+		if prefix != "" && strings.HasPrefix(l.input[l.pos:], prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// onSessionLine reports whether the current line is a flow session line:
+// a session header or a flow with a session arrow. The answer is cached per
+// line so long lines aren't rescanned for every word.
+func (l *Lexer) onSessionLine() bool {
+	if l.arrowLine != l.line {
+		l.arrowLine = l.line
+		line, _ := l.currentLine()
+		l.arrowOnLine = strings.Contains(line, sessionArrow) || strings.HasPrefix(strings.TrimSpace(line), sessionHeader)
+	}
+	return l.arrowOnLine
+}
+
+// onConfederationLine reports whether the current line contains
+// "confederation". The answer is cached per line like onSessionLine.
+func (l *Lexer) onConfederationLine() bool {
+	if l.confedLine != l.line {
+		l.confedLine = l.line
+		l.confedOnLine = l.lineContains("confederation")
+	}
+	return l.confedOnLine
+}
+
+// onInactiveRouteLine reports whether the current line is a route entry
+// without the active marker. The answer is cached per line like onSessionLine.
+func (l *Lexer) onInactiveRouteLine() bool {
+	if l.routeLine != l.line {
+		l.routeLine = l.line
+		line, _ := l.currentLine()
+		l.inactiveRoute = inactiveRoutePattern.MatchString(line)
+	}
+	return l.inactiveRoute
+}
+
+// gresStateOffset returns the input offset where the state after a
+// high-availability feature label ("Graceful switchover: Disabled") starts
+// on the current line, or -1 if there is none. The answer is cached per line
+// like onSessionLine.
+func (l *Lexer) gresStateOffset() int {
+	if l.gresLine != l.line {
+		l.gresLine = l.line
+		l.gresStateAt = -1
+		line, start := l.currentLine()
+		if m := gresLabelPattern.FindStringIndex(line); m != nil {
+			l.gresStateAt = start + m[1]
+		}
+	}
+	return l.gresStateAt
+}
+
+// commitEntryOffsets returns the input offsets where the username and the
+// access method of a commit history entry start on the current line, -1 for
+// either that isn't there. The answer is cached per line like onSessionLine.
+func (l *Lexer) commitEntryOffsets() (user, method int) {
+	if l.commitLine != l.line {
+		l.commitLine = l.line
+		l.commitUser, l.commitMethod = -1, -1
+		line, start := l.currentLine()
+		if m := commitEntryPattern.FindStringSubmatchIndex(line); m != nil {
+			l.commitUser = start + m[4]
+			if m[8] >= 0 {
+				l.commitMethod = start + m[8]
+			}
+		}
+	}
+	return l.commitUser, l.commitMethod
+}
+
+// lineTableHeaders returns the column headers of the show table whose header
+// line is the current line, or nil if it isn't one of tableHeaders, and
+// starts that table. The answer is cached per line like onSessionLine.
+func (l *Lexer) lineTableHeaders() map[string]bool {
+	if l.headersLine != l.line {
+		l.headersLine = l.line
+		l.lineHeaders = nil
+		line, _ := l.currentLine()
+		for _, table := range tableHeaders {
+			if containsAll(line, table.marks) {
+				l.lineHeaders = table.headers
+				l.table = table.table
+				break
+			}
+		}
+	}
+	return l.lineHeaders
+}
+
+// containsAll reports whether s contains every one of substrs
+func containsAll(s string, substrs []string) bool {
+	for _, substr := range substrs {
+		if !strings.Contains(s, substr) {
+			return false
+		}
+	}
+	return true
+}
+
+// hopTimeoutType returns the type of a "*" probe timeout on the current
+// line: TokenStateBad on a traceroute hop where every probe timed out,
+// TokenStateWarning on one with latencies, or TokenText when the line isn't
+// a traceroute hop in show output. The answer is cached per line like
+// onSessionLine.
+func (l *Lexer) hopTimeoutType() TokenType {
+	l.resolveParseMode()
+	if l.parseMode != ParseModeShow {
+		return TokenText
+	}
+	if l.hopLine != l.line {
+		l.hopLine = l.line
+		line, _ := l.currentLine()
+		switch {
+		case hopTimeoutPattern.MatchString(line):
+			l.hopTimeout = TokenStateBad
+		case hopLinePattern.MatchString(line):
+			l.hopTimeout = TokenStateWarning
+		default:
+			l.hopTimeout = TokenText
+		}
+	}
+	return l.hopTimeout
+}
+
+// classifyRouteAttribute classifies the words of an attribute line in an
+// extensive route entry: the label is a header, AS path members are ASNs,
+// community members communities, and the origin and next hop type values.
+// "Local AS: 65001 Peer AS: 65002" has a second label, with its own ASN.
+func (l *Lexer) classifyRouteAttribute(word, lower string) (TokenType, bool) {
+	if lower == "as:" && (l.lastToken == "local" || l.lastToken == "peer") {
+		return TokenColumnHeader, true
+	}
+	if l.lastToken == "as:" && asValuePattern.MatchString(word) {
+		return TokenASN, true
+	}
+
+	label := l.routeAttributeLabel()
+	start := l.pos - len(word)
+	switch {
+	case label == "":
+		return TokenText, false
+	case start < l.attributeProtoEnd:
+		return TokenRouteProtocol, true
+	case start < l.attributeLabelEnd:
+		return TokenColumnHeader, true
+	}
+
+	switch label {
+	case "as path":
+		if asValuePattern.MatchString(word) {
+			return TokenASN, true
+		}
+	case "communities":
+		return TokenCommunity, true
+	case "validation state":
+		if lower == "unverified" || lower == "unknown" {
+			return TokenStateNeutral, true
+		}
+	case "origin", "next hop type":
+		if strings.HasSuffix(l.lastToken, ":") {
+			return TokenValue, true
+		}
+	}
+	return TokenText, false
+}
+
+// routeAttributeLabel returns the route attribute label that starts the
+// current line, in lower case, or "" if there is none. The answer is cached
+// per line like hopTimeoutType.
+func (l *Lexer) routeAttributeLabel() string {
+	if l.attributeLine != l.line {
+		l.attributeLine = l.line
+		l.attributeLabel = ""
+		line, start := l.currentLine()
+		if m := routeAttributePattern.FindStringSubmatchIndex(line); m != nil {
+			l.attributeLabel = strings.ToLower(line[m[4]:m[5]])
+			l.attributeProtoEnd = start + max(m[3], 0)
+			l.attributeLabelEnd = start + m[1]
+		}
+	}
+	return l.attributeLabel
+}
+
+// onRequestProgressLine reports whether the current line is shutdown or
+// reboot progress. The answer is cached per line like onSessionLine.
+func (l *Lexer) onRequestProgressLine() bool {
+	if l.progressLine != l.line {
+		l.progressLine = l.line
+		line, _ := l.currentLine()
+		l.onProgress = requestProgressPattern.MatchString(line)
+	}
+	return l.onProgress
+}
+
+// secretEndsAt reports whether only spaces and a ";" separate end from a
+// SECRET-DATA marker on the current line, i.e. whether the token ending at
+// end is the secret the marker annotates. The marker is found once per line.
+func (l *Lexer) secretEndsAt(end int) bool {
+	if l.secretLine != l.line {
+		l.secretLine = l.line
+		line, start := l.currentLine()
+		l.secretAt = strings.Index(line, secretMarker)
+		if l.secretAt >= 0 {
+			l.secretAt += start
+		}
+	}
+	if l.secretAt < end {
+		return false
+	}
+	for i := end; i < l.secretAt; i++ {
+		if ch := l.input[i]; ch != ' ' && ch != '\t' && ch != ';' {
+			return false
+		}
+	}
+	return true
+}
+
+// wordStartsLine reports whether word, just scanned, began at the start of a line
+func (l *Lexer) wordStartsLine(word string) bool {
+	start := l.pos - len(word)
+	return start == 0 || l.input[start-1] == '\n'
+}
+
+// namesObject reports whether word follows an objectNameKeywords keyword on
+// the same line, as the name of a counter, policer or forwarding class
+func (l *Lexer) namesObject(word string) bool {
+	if !objectNameKeywords[l.lastToken] {
+		return false
+	}
+	i := l.pos - len(word) - 1
+	for i >= 0 && (l.input[i] == ' ' || l.input[i] == '\t') {
+		i--
+	}
+	return i >= 0 && l.input[i] != '\n'
+}
+
+// nextWord returns the next word on the current line, without a trailing
+// comma or semicolon, or "" at the end of the line
+func (l *Lexer) nextWord() string {
+	pos := l.pos
+	for pos < len(l.input) && (l.input[pos] == ' ' || l.input[pos] == '\t') {
+		pos++
+	}
+	start := pos
+	for pos < len(l.input) && !isWhitespace(l.input[pos]) && l.input[pos] != ',' && l.input[pos] != ';' {
+		pos++
+	}
+	return l.input[start:pos]
+}
+
+// nextWordIsNumber reports whether the next word on the current line is a plain number
+func (l *Lexer) nextWordIsNumber() bool {
+	return unitNumberPattern.MatchString(l.nextWord())
+}
+
 func isWhitespace(ch byte) bool {
 	return ch == ' ' || ch == '\t' || ch == '\n' || ch == '\r'
 }
@@ -1110,50 +2499,194 @@ func isWhitespace(ch byte) bool {
 // detectParseMode analyzes input to determine if it's config or show output.
 // Uses heuristics based on common patterns in each format.
 func (l *Lexer) detectParseMode() ParseMode {
+	return l.DetectionReport().Mode
+}
+
+// ModeDetection explains a parse mode auto-detection: the indicators found
+// in the input sample and the scores they add up to.
+type ModeDetection struct {
+	Mode             ParseMode
+	ConfigScore      int
+	ShowScore        int
+	ConfigIndicators []string // config indicators found, one point each
+	ShowIndicators   []string // show indicators found, one point each
+	Tabular          bool     // columns separated by 2+ spaces, two show points
+}
+
+// String summarizes the detection on one line, e.g.
+// "show (config 1 [;], show 3 [establ, tabular])"
+func (d ModeDetection) String() string {
+	show := append([]string(nil), d.ShowIndicators...)
+	if d.Tabular {
+		show = append(show, "tabular")
+	}
+	return fmt.Sprintf("%s (config %d [%s], show %d [%s])", d.Mode,
+		d.ConfigScore, strings.Join(d.ConfigIndicators, ", "),
+		d.ShowScore, strings.Join(show, ", "))
+}
+
+// DetectionReport returns how auto-detection decides the parse mode of the
+// lexer's input, for debugging misdetected input. It reports the detected
+// mode even if a mode was set with SetParseMode.
+func (l *Lexer) DetectionReport() ModeDetection {
 	// Sample first N chars for detection - enough to see headers/commands
 	// without processing entire large configs
 	sample := l.input
 	if len(sample) > parseModeDetectionSampleSize {
 		sample = sample[:parseModeDetectionSampleSize]
 	}
+	d := scoreParseMode(sample)
+
+	// Require showScore >= 2 to avoid false positives on single words like "up"
+	// appearing in config context. Config mode is the safe default
+	d.Mode = ParseModeConfig
+	if d.ShowScore >= 2 && d.ShowScore > d.ConfigScore {
+		d.Mode = ParseModeShow
+	}
+	return d
+}
+
+// scoreParseMode collects the config and show output indicators in sample.
+// The returned Mode is left unset.
+func scoreParseMode(sample string) ModeDetection {
+	var d ModeDetection
 	lower := strings.ToLower(sample)
 
 	// Config indicators: set, delete, {, }, ;
-	configScore := 0
 	configIndicators := []string{"set ", "delete ", "{", "}", ";", "host-name", "policy-statement"}
 	for _, ind := range configIndicators {
 		if strings.Contains(lower, ind) {
-			configScore++
+			d.ConfigIndicators = append(d.ConfigIndicators, strings.TrimSpace(ind))
+			d.ConfigScore++
 		}
 	}
 
 	// Show indicators: states, table names, column patterns
 	// Note: these must be fairly specific to avoid false positives
-	showScore := 0
 	showIndicators := []string{
 		"establ", "idle", "2way",
 		"inet.0", "inet6.0", "bgp.evpn",
 		"flaps", "up/dn",
 		"physical interface", "logical interface",
+		"system booted", "load averages",
+		"rolled back", "unless confirmed",
 	}
 	for _, ind := range showIndicators {
 		if strings.Contains(lower, ind) {
-			showScore++
+			d.ShowIndicators = append(d.ShowIndicators, ind)
+			d.ShowScore++
 		}
 	}
 
 	// Tabular data pattern (multiple spaces between words) - strong indicator
 	// worth 2 points since tabular output is very characteristic of show commands
 	if tabularPattern.MatchString(sample) {
-		showScore += 2
+		d.Tabular = true
+		d.ShowScore += 2
 	}
+	return d
+}
 
-	// Require showScore >= 2 to avoid false positives on single words like "up"
-	// appearing in config context. Config mode is the safe default
-	if showScore >= 2 && showScore > configScore {
-		return ParseModeShow
+// LineKind is what a single line of input looks like on its own.
+type LineKind int
+
+const (
+	// LineText is a blank line or one with no JunOS indicators.
+	LineText LineKind = iota
+
+	// LineConfig is configuration syntax ("set ...", "host-name r1;").
+	LineConfig
+
+	// LineShow is show command output (table rows, states, table names).
+	LineShow
+
+	// LinePrompt is a CLI prompt, with or without a command.
+	LinePrompt
+
+	// LineDiff is a "show | compare" line: "+ ", "- " or an [edit ...] header.
+	LineDiff
+)
+
+// String returns the name of the line kind
+func (k LineKind) String() string {
+	switch k {
+	case LineText:
+		return "text"
+	case LineConfig:
+		return "config"
+	case LineShow:
+		return "show"
+	case LinePrompt:
+		return "prompt"
+	case LineDiff:
+		return "diff"
+	default:
+		return "unknown"
 	}
-	return ParseModeConfig
+}
+
+// ClassifyLine reports what a single line looks like without any context
+// from the lines around it. Unlike parse mode detection, which defaults to
+// config, a line with no indicators at all is LineText.
+func ClassifyLine(line string) LineKind {
+	line = strings.TrimRight(line, "\r\n")
+	switch {
+	case strings.TrimSpace(line) == "":
+		return LineText
+	case IsPrompt(line):
+		return LinePrompt
+	case strings.HasPrefix(line, "[edit"),
+		strings.HasPrefix(line, "+ "), strings.HasPrefix(line, "+\t"),
+		strings.HasPrefix(line, "- "), strings.HasPrefix(line, "-\t"):
+		return LineDiff
+	}
+
+	d := scoreParseMode(line)
+	switch {
+	case d.ShowScore > d.ConfigScore:
+		return LineShow
+	case d.ConfigScore > 0:
+		return LineConfig
+	default:
+		return LineText
+	}
+}
+
+// LineDepths returns the brace nesting depth of each line of input, e.g. for
+// folding hierarchical config in an editor. A line's depth is the number of
+// blocks open at its start; a line that starts by closing a block has the
+// depth of the block's opening line. Braces in strings and comments don't
+// count. A trailing newline doesn't start another line.
+func LineDepths(input string) []int {
+	depths := make([]int, strings.Count(strings.TrimSuffix(input, "\n"), "\n")+1)
+	l := New(input)
+	l.SetParseMode(ParseModeConfig)
+
+	depth, line := 0, 0 // line: number of lines whose depth is set
+	for _, tok := range l.Tokenize() {
+		if strings.TrimSpace(tok.Value) == "" {
+			continue
+		}
+		first := tok.Line > line
+		for ; line < tok.Line && line < len(depths); line++ {
+			depths[line] = depth
+		}
+		switch tok.Value {
+		case "{":
+			depth++
+		case "}":
+			if depth > 0 {
+				depth--
+			}
+			if first {
+				depths[line-1] = depth
+			}
+		}
+	}
+	for ; line < len(depths); line++ {
+		depths[line] = depth
+	}
+	return depths
 }
 
 // IsPrompt checks if the input matches a JunOS CLI prompt pattern.
@@ -1162,6 +2695,17 @@ func IsPrompt(input string) bool {
 	return promptPattern.MatchString(strings.TrimSpace(input))
 }
 
+// PromptHost returns the user and hostname of a JunOS CLI prompt, e.g.
+// "admin" and "r1" for "{master:0}[edit] admin@r1#". ok is false when input
+// isn't a prompt.
+func PromptHost(input string) (user, host string, ok bool) {
+	matches := promptPattern.FindStringSubmatch(strings.TrimSpace(input))
+	if matches == nil {
+		return "", "", false
+	}
+	return matches[4], matches[5], true
+}
+
 // SetParseMode explicitly sets the parsing mode
 func (l *Lexer) SetParseMode(mode ParseMode) {
 	l.parseMode = mode
@@ -1,7 +1,11 @@
 package lexer
 
 import (
+	"reflect"
+	"strconv"
+	"strings"
 	"testing"
+	"time"
 )
 
 func TestTokenizeCommands(t *testing.T) {
@@ -10,8 +14,8 @@ func TestTokenizeCommands(t *testing.T) {
 		expected TokenType
 	}{
 		{"set", TokenCommand},
-		{"delete", TokenCommand},
-		{"deactivate", TokenCommand},
+		{"delete", TokenCommandDestructive},
+		{"deactivate", TokenCommandDestructive},
 		{"activate", TokenCommand},
 		{"edit", TokenCommand},
 		{"show", TokenCommand},
@@ -160,6 +164,17 @@ func TestTokenizeInterfaces(t *testing.T) {
 		// Channelized
 		{"ge-0/0/0:0", TokenInterface},
 		{"ge-0/0/0:1", TokenInterface},
+		// High-numbered FPCs and ports (PTX/MX)
+		{"et-11/0/71", TokenInterface},
+		{"xe-20/3/9:2", TokenInterface},
+		{"et-19/1/35.32767", TokenInterface},
+		// Slotted tunnel and services interfaces
+		{"gr-0/0/10", TokenInterface},
+		{"gr-0/0/10.1", TokenInterface},
+		{"lt-1/2/10.16385", TokenInterface},
+		{"ms-5/0/0.0", TokenInterface},
+		// Not an interface
+		{"xe-a/b/c", TokenIdentifier},
 	}
 
 	for _, tt := range tests {
@@ -186,6 +201,7 @@ func TestTokenizeIPv4(t *testing.T) {
 		{"172.16.0.1", TokenIPv4},
 		{"255.255.255.255", TokenIPv4},
 		{"0.0.0.0", TokenIPv4},
+		{"010.0.0.1", TokenIPv4},
 	}
 
 	for _, tt := range tests {
@@ -212,6 +228,7 @@ func TestTokenizeIPv4Prefix(t *testing.T) {
 		{"172.16.0.0/12", TokenIPv4Prefix},
 		{"0.0.0.0/0", TokenIPv4Prefix},
 		{"192.168.1.1/32", TokenIPv4Prefix},
+		{"10.255.0.0/16", TokenIPv4Prefix},
 	}
 
 	for _, tt := range tests {
@@ -228,6 +245,20 @@ func TestTokenizeIPv4Prefix(t *testing.T) {
 	}
 }
 
+func TestInvalidIPv4(t *testing.T) {
+	for _, input := range []string{"256.1.1.1", "999.999.999.999", "192.168.1.300", "10.0.0.0/33", "300.0.0.0/8"} {
+		for _, mode := range []ParseMode{ParseModeConfig, ParseModeShow} {
+			l := New("set x " + input)
+			l.SetParseMode(mode)
+			for _, tok := range l.Tokenize() {
+				if tok.Value == input && (tok.Type == TokenIPv4 || tok.Type == TokenIPv4Prefix) {
+					t.Errorf("mode %d: expected %q not to be an address, got %v", mode, input, tok.Type)
+				}
+			}
+		}
+	}
+}
+
 func TestTokenizeIPv6(t *testing.T) {
 	tests := []struct {
 		input    string
@@ -610,6 +641,31 @@ func TestTokenizeKeywords(t *testing.T) {
 	}
 }
 
+func TestApplyFlagsOmit(t *testing.T) {
+	input := "system {\n    apply-flags omit;\n    login {\n        /* OMITTED */\n        user admin;\n    }\n}\nset interfaces apply-flags omit\n"
+	l := New(input)
+	l.SetParseMode(ParseModeConfig)
+
+	expected := map[string]TokenType{
+		"apply-flags":   TokenKeyword,
+		"omit":          TokenKeyword,
+		"/* OMITTED */": TokenComment,
+		"interfaces":    TokenSection,
+	}
+	seen := map[string]int{}
+	for _, tok := range l.Tokenize() {
+		if exp, ok := expected[tok.Value]; ok {
+			seen[tok.Value]++
+			if tok.Type != exp {
+				t.Errorf("line %d: expected %q to be %v, got %v", tok.Line, tok.Value, exp, tok.Type)
+			}
+		}
+	}
+	if seen["apply-flags"] != 2 || seen["omit"] != 2 || seen["/* OMITTED */"] != 1 {
+		t.Errorf("unexpected tokens seen: %v", seen)
+	}
+}
+
 func TestTokenizeMAC(t *testing.T) {
 	tests := []struct {
 		input string
@@ -854,6 +910,18 @@ func TestTokenizeTableNames(t *testing.T) {
 		{"mpls.0", TokenTableName},
 		{"bgp.0", TokenTableName},
 		{"l2vpn.0", TokenTableName},
+		{"inet.3", TokenTableName},
+		{"inet6.3:", TokenTableName},
+		{"bgp.l3vpn.0", TokenTableName},
+		{"bgp.evpn.0:", TokenTableName},
+		{"CUST-A.inet.0", TokenTableName},
+		{"CUST-A.inet.0:", TokenTableName},
+		{"__default_evpn__.evpn.0:", TokenTableName},
+		{":vrf.inet.0", TokenTableName},
+		// Ordinary dotted words
+		{"jweb.1", TokenIdentifier},
+		{"r1.lab.inet", TokenIdentifier},
+		{"www.example.com", TokenIdentifier},
 	}
 
 	for _, tt := range tests {
@@ -1073,3 +1141,2944 @@ func TestShowModePreservesSharedPatterns(t *testing.T) {
 		})
 	}
 }
+
+// routeTableFixture is sample "show route" output
+const routeTableFixture = `inet.0: 25 destinations, 30 routes (25 active, 0 holddown, 0 hidden)
++ = Active Route, - = Last Active, * = Both
+
+10.255.255.0/24    *[OSPF/10] 3d 08:15:30, metric 20
+                    > to 10.0.0.2 via ge-0/0/0.0
+172.16.0.0/16      *[BGP/170] 5d 14:22:10, localpref 100
+                      AS path: 65002 65003 I, validation-state: valid
+                    > to 10.0.0.1 via ge-0/0/0.0
+`
+
+// findTokenAfter returns the first token with the given value and the next
+// non-whitespace token after it
+func findTokenAfter(tokens []Token, value string) (Token, Token, bool) {
+	for i, tok := range tokens {
+		if tok.Value != value {
+			continue
+		}
+		for j := i + 1; j < len(tokens); j++ {
+			if tokens[j].Type != TokenText {
+				return tok, tokens[j], true
+			}
+		}
+		return tok, Token{}, true
+	}
+	return Token{}, Token{}, false
+}
+
+func TestRouteAttributeLabelsShowMode(t *testing.T) {
+	l := New(routeTableFixture)
+	l.SetParseMode(ParseModeShow)
+	tokens := l.Tokenize()
+
+	for _, label := range []string{"metric", "localpref"} {
+		tok, next, ok := findTokenAfter(tokens, label)
+		if !ok {
+			t.Fatalf("did not find %q in route table", label)
+		}
+		if tok.Type != TokenKeyword {
+			t.Errorf("expected %q to be TokenKeyword, got %v", label, tok.Type)
+		}
+		if next.Type != TokenNumber {
+			t.Errorf("expected value after %q to be TokenNumber, got %v (%q)", label, next.Type, next.Value)
+		}
+	}
+}
+
+func TestRouteAttributeLabelsAsColumnHeaders(t *testing.T) {
+	// Without a value following, labels are still column headers
+	l := New("Prefix             Metric   LocalPref")
+	l.SetParseMode(ParseModeShow)
+	tokens := l.Tokenize()
+
+	for _, tok := range tokens {
+		if (tok.Value == "Metric" || tok.Value == "LocalPref") && tok.Type != TokenColumnHeader {
+			t.Errorf("expected %q to be TokenColumnHeader, got %v", tok.Value, tok.Type)
+		}
+	}
+}
+
+func TestRouteAttributeLabelsConfigMode(t *testing.T) {
+	tests := []struct {
+		input    string
+		label    string
+		expected TokenType
+	}{
+		{"set protocols ospf area 0.0.0.0 interface ge-0/0/0.0 metric 10", "metric", TokenKeyword},
+		{"set routing-options static route 0.0.0.0/0 preference 5", "preference", TokenKeyword},
+		{"set policy-options policy-statement p term t then metric 10", "metric", TokenAction},
+		{"set policy-options policy-statement p term t then local-preference 200", "local-preference", TokenAction},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			tokens := New(tt.input).Tokenize()
+			tok, next, ok := findTokenAfter(tokens, tt.label)
+			if !ok {
+				t.Fatalf("did not find %q", tt.label)
+			}
+			if tok.Type != tt.expected {
+				t.Errorf("expected %q to be %v, got %v", tt.label, tt.expected, tok.Type)
+			}
+			if next.Type != TokenNumber {
+				t.Errorf("expected value after %q to be TokenNumber, got %v", tt.label, next.Type)
+			}
+		})
+	}
+}
+
+// mplsTableFixture is sample "show route table mpls.0" output
+const mplsTableFixture = `mpls.0: 4 destinations, 4 routes (4 active, 0 holddown, 0 hidden)
++ = Active Route, - = Last Active, * = Both
+
+0                  *[MPLS/0] 1w0d 02:13:11, metric 1
+                      to table inet.0
+299824             *[LDP/9] 1w0d 02:13:01, metric 1
+                    > to 10.0.0.2 via ge-0/0/0.0, Swap 299824
+299840             *[LDP/9] 1w0d 02:13:01, metric 1
+                    > to 10.0.0.2 via ge-0/0/0.0, Pop
+299856             *[RSVP/7] 1w0d 02:13:01, metric 1
+                    > to 10.0.0.6 via ge-0/0/1.0, Push 300000, Push 299776(top)
+`
+
+func TestMPLSLabelOperations(t *testing.T) {
+	l := New(mplsTableFixture)
+	l.SetParseMode(ParseModeShow)
+	tokens := l.Tokenize()
+
+	ops := map[string]int{}
+	labels := map[string]bool{"299824": false, "300000": false, "299776(top)": false}
+	for i, tok := range tokens {
+		if labelOperations[strings.ToLower(tok.Value)] {
+			if tok.Type != TokenAction {
+				t.Errorf("expected %q to be TokenAction, got %v", tok.Value, tok.Type)
+			}
+			ops[tok.Value]++
+		}
+		if _, ok := labels[tok.Value]; ok && i > 1 && tokens[i-2].Type == TokenAction {
+			if tok.Type != TokenNumber {
+				t.Errorf("expected label %q to be TokenNumber, got %v", tok.Value, tok.Type)
+			}
+			labels[tok.Value] = true
+		}
+		if tok.Value == "[LDP/9]" && tok.Type != TokenRouteProtocol {
+			t.Errorf("expected [LDP/9] to be TokenRouteProtocol, got %v", tok.Type)
+		}
+	}
+
+	if ops["Swap"] != 1 || ops["Pop"] != 1 || ops["Push"] != 2 {
+		t.Errorf("expected Swap, Pop and 2x Push, got %v", ops)
+	}
+	for label, found := range labels {
+		if !found {
+			t.Errorf("label %q not classified after its operation", label)
+		}
+	}
+}
+
+func TestMPLSLabelOperationsNextHopLine(t *testing.T) {
+	// Single next-hop line without the table header (streamed output)
+	l := New("                    > to 10.0.0.2 via ge-0/0/0.0, Swap 299824")
+	l.SetParseMode(ParseModeShow)
+	tokens := l.Tokenize()
+
+	op, next, ok := findTokenAfter(tokens, "Swap")
+	if !ok {
+		t.Fatal("did not find Swap")
+	}
+	if op.Type != TokenAction {
+		t.Errorf("expected Swap to be TokenAction, got %v", op.Type)
+	}
+	if next.Type != TokenNumber {
+		t.Errorf("expected label after Swap to be TokenNumber, got %v", next.Type)
+	}
+}
+
+func TestMPLSLabelOperationsGatedOnContext(t *testing.T) {
+	// "push"/"pop" outside an MPLS context are not label operations
+	l := New("Pop  Push  Swap")
+	l.SetParseMode(ParseModeShow)
+	for _, tok := range l.Tokenize() {
+		if tok.Type == TokenAction {
+			t.Errorf("expected %q outside MPLS context not to be TokenAction", tok.Value)
+		}
+	}
+
+	// In config mode "push" stays a TCP flag match condition
+	tokens := New("set firewall family inet filter f term t from tcp-flags push").Tokenize()
+	last := tokens[len(tokens)-1]
+	if last.Value != "push" || last.Type != TokenAction {
+		t.Errorf("expected config 'push' to remain TokenAction, got %v", last.Type)
+	}
+}
+
+func TestTokenizeBracketedSets(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		elements map[string]TokenType
+	}{
+		{
+			name:  "port set",
+			input: "set firewall family inet filter f term t from destination-port [ 80 443 8080 ]",
+			elements: map[string]TokenType{
+				"80": TokenNumber, "443": TokenNumber, "8080": TokenNumber,
+			},
+		},
+		{
+			name:  "address set",
+			input: "source-address [ 10.0.0.0/8 192.168.1.1 2001:db8::/32 ];",
+			elements: map[string]TokenType{
+				"10.0.0.0/8": TokenIPv4Prefix, "192.168.1.1": TokenIPv4, "2001:db8::/32": TokenIPv6Prefix,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tokens := New(tt.input).Tokenize()
+			brackets := 0
+			for _, tok := range tokens {
+				if tok.Value == "[" || tok.Value == "]" {
+					brackets++
+					if tok.Type != TokenOperator {
+						t.Errorf("expected %q to be TokenOperator, got %v", tok.Value, tok.Type)
+					}
+				}
+				if expected, ok := tt.elements[tok.Value]; ok && tok.Type != expected {
+					t.Errorf("expected element %q to be %v, got %v", tok.Value, expected, tok.Type)
+				}
+			}
+			if brackets != 2 {
+				t.Errorf("expected 2 brackets, got %d", brackets)
+			}
+		})
+	}
+}
+
+func TestBracketsInsideWordsUnaffected(t *testing.T) {
+	l := New("10.0.0.0/24        *[BGP/170] 5d 14:22:10")
+	l.SetParseMode(ParseModeShow)
+	for _, tok := range l.Tokenize() {
+		if tok.Value == "[BGP/170]" && tok.Type != TokenRouteProtocol {
+			t.Errorf("expected [BGP/170] to remain TokenRouteProtocol, got %v", tok.Type)
+		}
+	}
+}
+
+// flowSessionFixture is sample "show security flow session" output
+const flowSessionFixture = `Session ID: 1234, Policy name: allow-web/4, State: Active, Timeout: 1790, Valid
+  In: 10.0.0.5/51234 --> 93.184.216.34/443;tcp, Conn Tag: 0x0, If: ge-0/0/1.0, Pkts: 10, Bytes: 1500,
+  Out: 93.184.216.34/443 --> 203.0.113.1/38211;tcp, Conn Tag: 0x0, If: ge-0/0/0.0, Pkts: 8, Bytes: 4000,
+Session ID: 1235, Policy name: allow-v6/5, State: Active, Timeout: 20, Invalid
+  In: 2001:db8::5/40000 --> 2001:db8::1/53;udp, Conn Tag: 0x0, If: ge-0/0/1.0, Pkts: 1, Bytes: 80,
+Total sessions: 2
+`
+
+func TestFlowSessionEndpoints(t *testing.T) {
+	l := New(flowSessionFixture)
+	l.SetParseMode(ParseModeShow)
+	tokens := l.Tokenize()
+
+	// Content must be preserved exactly
+	var reconstructed strings.Builder
+	for _, tok := range tokens {
+		reconstructed.WriteString(tok.Value)
+	}
+	if reconstructed.String() != flowSessionFixture {
+		t.Fatalf("token reconstruction mismatch:\n%q", reconstructed.String())
+	}
+
+	expected := []struct {
+		value     string
+		tokenType TokenType
+	}{
+		{"10.0.0.5", TokenIPv4},
+		{"51234", TokenNumber},
+		{"-->", TokenStatusSymbol},
+		{"93.184.216.34", TokenIPv4},
+		{"443", TokenNumber},
+		{"203.0.113.1", TokenIPv4},
+		{"38211", TokenNumber},
+		{"2001:db8::5", TokenIPv6},
+		{"40000", TokenNumber},
+		{"ge-0/0/1.0", TokenInterface},
+		{"Valid", TokenStateGood},
+		{"Invalid", TokenStateBad},
+	}
+
+	for _, exp := range expected {
+		found := false
+		for _, tok := range tokens {
+			if tok.Value == exp.value {
+				found = true
+				if tok.Type != exp.tokenType {
+					t.Errorf("expected %q to be %v, got %v", exp.value, exp.tokenType, tok.Type)
+				}
+				break
+			}
+		}
+		if !found {
+			t.Errorf("did not find token %q", exp.value)
+		}
+	}
+
+	// Port separators are operators
+	for i, tok := range tokens {
+		if tok.Value == "/" && (i == 0 || (tokens[i-1].Type != TokenIPv4 && tokens[i-1].Type != TokenIPv6)) {
+			t.Errorf("unexpected standalone '/' token at %d", i)
+		}
+	}
+}
+
+func TestShowModeSplitsTrailingCommas(t *testing.T) {
+	l := New("Session ID: 1234, Policy name: allow-web/4, State: Active, Timeout: 1790, Valid")
+	l.SetParseMode(ParseModeShow)
+	tokens := l.Tokenize()
+
+	expected := map[string]TokenType{
+		// An active flow session is healthy
+		"Active": TokenStateGood,
+		"1790":   TokenNumber,
+	}
+	seen := map[string]bool{}
+	for _, tok := range tokens {
+		if exp, ok := expected[tok.Value]; ok {
+			seen[tok.Value] = true
+			if tok.Type != exp {
+				t.Errorf("expected %q without its comma to be %v, got %v", tok.Value, exp, tok.Type)
+			}
+		}
+	}
+	for value := range expected {
+		if !seen[value] {
+			t.Errorf("did not find %q", value)
+		}
+	}
+
+	// An active BGP peer is still connecting
+	l = New("10.0.0.1  65001  0  0  0  0  5:30 Active\n")
+	l.SetParseMode(ParseModeShow)
+	for _, tok := range l.Tokenize() {
+		if tok.Value == "Active" && tok.Type != TokenStateBad {
+			t.Errorf("expected a BGP peer's Active to be TokenStateBad, got %v", tok.Type)
+		}
+	}
+}
+
+func TestEndpointSplitRequiresArrow(t *testing.T) {
+	// Without a session arrow, IPv6 prefixes are not split into address and port
+	l := New("2001:db8::/32      *[Static/5] 1d 00:00:00")
+	l.SetParseMode(ParseModeShow)
+	tokens := l.Tokenize()
+	if tokens[0].Value != "2001:db8::/32" || tokens[0].Type != TokenIPv6Prefix {
+		t.Errorf("expected IPv6 prefix to stay intact, got %q (%v)", tokens[0].Value, tokens[0].Type)
+	}
+}
+
+func TestDestructiveCommands(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected TokenType
+	}{
+		{"delete policy-options prefix-list foo 10.0.0.0/8", TokenCommandDestructive},
+		{"deactivate interfaces ge-0/0/2", TokenCommandDestructive},
+		{"set policy-options prefix-list foo 10.0.0.0/8", TokenCommand},
+		{"activate interfaces ge-0/0/2", TokenCommand},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			tokens := New(tt.input).Tokenize()
+			if tokens[0].Type != tt.expected {
+				t.Errorf("expected %v, got %v", tt.expected, tokens[0].Type)
+			}
+			// The rest of the line classifies normally
+			last := tokens[len(tokens)-1]
+			if last.Value == "10.0.0.0/8" && last.Type != TokenIPv4Prefix {
+				t.Errorf("expected trailing prefix to be TokenIPv4Prefix, got %v", last.Type)
+			}
+		})
+	}
+}
+
+// ethernetSwitchingFixture is sample "show ethernet-switching table" output
+const ethernetSwitchingFixture = `Ethernet-switching table: 4 entries, 2 learned
+  VLAN              MAC address       Type         Age Interfaces
+  default           *                 Flood          - All-members
+  default           00:11:22:33:44:55 Learn          0 ge-0/0/1.0
+  users             00:aa:bb:cc:dd:ee Static         - ge-0/0/2.0
+  voice             00:11:22:33:44:66 Learn      1:23:45 ge-0/0/3.0
+`
+
+func TestEthernetSwitchingTable(t *testing.T) {
+	l := New(ethernetSwitchingFixture)
+	if l.detectParseMode() != ParseModeShow {
+		t.Fatal("expected ethernet-switching table to be detected as show output")
+	}
+	tokens := l.Tokenize()
+
+	expected := map[string]TokenType{
+		"Learn":             TokenStateGood,
+		"Static":            TokenStateNeutral,
+		"Flood":             TokenStateWarning,
+		"00:11:22:33:44:55": TokenMAC,
+		"users":             TokenIdentifier,
+		"1:23:45":           TokenTimeDuration,
+		"ge-0/0/1.0":        TokenInterface,
+		"MAC":               TokenColumnHeader,
+		"address":           TokenColumnHeader,
+		"Age":               TokenColumnHeader,
+	}
+
+	seen := map[string]bool{}
+	for _, tok := range tokens {
+		if exp, ok := expected[tok.Value]; ok {
+			seen[tok.Value] = true
+			if tok.Type != exp {
+				t.Errorf("expected %q to be %v, got %v", tok.Value, exp, tok.Type)
+			}
+		}
+	}
+	for value := range expected {
+		if !seen[value] {
+			t.Errorf("did not find %q", value)
+		}
+	}
+}
+
+// TestTableStatesOnlyInTheirTable checks that table-specific states are plain
+// words in other show output, and after their table ends
+func TestTableStatesOnlyInTheirTable(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		word  string
+		state TokenType
+	}{
+		{"static route count", "inet.0: 12 destinations, 12 routes\n  Static routes: 4\n", "Static", TokenStateNeutral},
+		{"flood after ethernet switching table", ethernetSwitchingFixture + "\nStorm control: Flood 10 packets\n", "Flood", TokenStateWarning},
+		{"empty queue", "Output queue: Empty, 0 packets dropped\n", "Empty", TokenStateNeutral},
+		{"present after chassis fpc", chassisFPCFixture + "\nPower supply 0 Present\n", "Present", TokenStateWarning},
+		{"recv counter", "Input packets: 1024\n  recv 512 errors 0\n", "recv", TokenStateNeutral},
+		{"rjct after forwarding table", forwardingTableFixture + "\nFilter counters: rjct 12\n", "rjct", TokenStateBad},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := New(tt.input)
+			l.SetParseMode(ParseModeShow)
+			tokens := l.Tokenize()
+
+			// Only the last occurrence is outside the table
+			var last *Token
+			for i := range tokens {
+				if tokens[i].Value == tt.word {
+					last = &tokens[i]
+				}
+			}
+			if last == nil {
+				t.Fatalf("did not find %q", tt.word)
+			}
+			if last.Type == tt.state {
+				t.Errorf("expected %q not to be %v outside its table", tt.word, tt.state)
+			}
+		})
+	}
+}
+
+func TestSignedNumbers(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		value string
+		want  TokenType
+	}{
+		{"negative metric", "set policy-options policy-statement p then metric subtract -10", "-10", TokenNumber},
+		{"positive offset", "metric +5 offset", "+5", TokenNumber},
+		{"negative at line start", "-10 offset", "-10", TokenNumber},
+		{"diff remove marker", "- set system host-name r1", "-", TokenDiffRemove},
+		{"diff add marker", "+ set system host-name r1", "+", TokenDiffAdd},
+		{"dash word is not a number", "set interfaces ge-0/0/0", "ge-0/0/0", TokenInterface},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tokens := New(tt.input).Tokenize()
+			for _, tok := range tokens {
+				if tok.Value == tt.value {
+					if tok.Type != tt.want {
+						t.Errorf("expected %q to be %v, got %v", tt.value, tt.want, tok.Type)
+					}
+					return
+				}
+			}
+			t.Errorf("did not find %q in %q", tt.value, tt.input)
+		})
+	}
+}
+
+func TestPathsAndURLs(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		value string
+		want  TokenType
+	}{
+		{"ftp url", "load set ftp://user@10.0.0.1/configs/r1.conf", "ftp://user@10.0.0.1/configs/r1.conf", TokenPath},
+		{"https url", "request system software add https://example.com/junos-21.4.tgz", "https://example.com/junos-21.4.tgz", TokenPath},
+		{"absolute path", "save /var/tmp/config.txt", "/var/tmp/config.txt", TokenPath},
+		{"show mode path", "file list /var/log/\n/var/log/messages\n", "/var/log/messages", TokenPath},
+		{"interface unaffected", "set interfaces ge-0/0/0 unit 0", "ge-0/0/0", TokenInterface},
+		{"prefix unaffected", "route 10.0.0.0/8 discard", "10.0.0.0/8", TokenIPv4Prefix},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, tok := range New(tt.input).Tokenize() {
+				if tok.Value == tt.value {
+					if tok.Type != tt.want {
+						t.Errorf("expected %q to be %v, got %v", tt.value, tt.want, tok.Type)
+					}
+					return
+				}
+			}
+			t.Errorf("did not find %q in %q", tt.value, tt.input)
+		})
+	}
+}
+
+func TestPromptHostnameSplit(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		host     string
+		hostType TokenType
+		command  string
+	}{
+		{"IPv4 hostname", "root@192.168.1.1>", "192.168.1.1", TokenPromptHostOper, ""},
+		{"FQDN with command", "user@fw-01.example.com# show configuration", "fw-01.example.com", TokenPromptHostConf, "show"},
+		{"IPv6 hostname", "admin@2001:db8::1> show route", "2001:db8::1", TokenPromptHostOper, "show"},
+		{"dotted host with dotted argument", "root@r1.lab> show version 1.2", "r1.lab", TokenPromptHostOper, "show"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tokens := New(tt.input).Tokenize()
+			if len(tokens) < 4 {
+				t.Fatalf("expected prompt tokens, got %v", tokens)
+			}
+			if tokens[0].Type != TokenPromptUser {
+				t.Errorf("expected first token to be PromptUser, got %v %q", tokens[0].Type, tokens[0].Value)
+			}
+			if tokens[2].Value != tt.host || tokens[2].Type != tt.hostType {
+				t.Errorf("expected hostname %q (%v), got %q (%v)", tt.host, tt.hostType, tokens[2].Value, tokens[2].Type)
+			}
+			if tt.command == "" {
+				return
+			}
+			for _, tok := range tokens[3:] {
+				if tok.Value == tt.command {
+					if tok.Type != TokenCommand {
+						t.Errorf("expected %q after the prompt to be a command, got %v", tt.command, tok.Type)
+					}
+					return
+				}
+			}
+			t.Errorf("did not find command %q after the prompt", tt.command)
+		})
+	}
+}
+
+func TestPromptHostnameRejectsTrailingDot(t *testing.T) {
+	if IsPrompt("user@router.>") {
+		t.Error("hostname ending in a dot should not be a prompt")
+	}
+}
+
+func TestPromptHost(t *testing.T) {
+	tests := []struct {
+		input, user, host string
+		ok                bool
+	}{
+		{"admin@r1> ", "admin", "r1", true},
+		{"{master:0}[edit]\nadmin@r1-re0# ", "admin", "r1-re0", true},
+		{"{master:0}[edit] oper@core1.lab# show | compare\r\n", "oper", "core1.lab", true},
+		{"\r\nuser@2001:db8::1> ", "user", "2001:db8::1", true},
+		{"set system host-name r1", "", "", false},
+	}
+
+	for _, tt := range tests {
+		user, host, ok := PromptHost(tt.input)
+		if user != tt.user || host != tt.host || ok != tt.ok {
+			t.Errorf("PromptHost(%q) = %q, %q, %v; want %q, %q, %v", tt.input, user, host, ok, tt.user, tt.host, tt.ok)
+		}
+	}
+}
+
+// chassisFPCFixture is sample "show chassis fpc" output
+const chassisFPCFixture = `                     Temp  CPU Utilization (%)   CPU Utilization (%)  Memory    Utilization (%)
+Slot State            (C)  Total  Interrupt      1min   5min   15min  DRAM (MB) Heap     Buffer
+  0  Online           36     5          0        5      5      5    2048       23         11
+  1  Offline
+  2  Present
+  3  Empty
+`
+
+func TestChassisFPCStates(t *testing.T) {
+	l := New(chassisFPCFixture)
+	if l.detectParseMode() != ParseModeShow {
+		t.Fatal("expected chassis fpc output to be detected as show output")
+	}
+	tokens := l.Tokenize()
+
+	expected := map[string]TokenType{
+		"Online":  TokenStateGood,
+		"Offline": TokenStateBad,
+		"Present": TokenStateWarning,
+		"Empty":   TokenStateNeutral,
+		"Slot":    TokenColumnHeader,
+		"Temp":    TokenColumnHeader,
+		"Memory":  TokenColumnHeader,
+		"DRAM":    TokenColumnHeader,
+		"Heap":    TokenColumnHeader,
+		"2048":    TokenNumber,
+		"36":      TokenNumber,
+	}
+
+	seen := map[string]bool{}
+	for _, tok := range tokens {
+		if exp, ok := expected[tok.Value]; ok {
+			seen[tok.Value] = true
+			if tok.Type != exp {
+				t.Errorf("expected %q to be %v, got %v", tok.Value, exp, tok.Type)
+			}
+		}
+	}
+	for value := range expected {
+		if !seen[value] {
+			t.Errorf("did not find %q", value)
+		}
+	}
+}
+
+func TestParseModeFromString(t *testing.T) {
+	tests := []struct {
+		input string
+		want  ParseMode
+	}{
+		{"auto", ParseModeAuto},
+		{"config", ParseModeConfig},
+		{"show", ParseModeShow},
+		{"Show", ParseModeShow},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got, err := ParseModeFromString(tt.input)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("ParseModeFromString(%q) = %v, want %v", tt.input, got, tt.want)
+			}
+			if got.String() != strings.ToLower(tt.input) {
+				t.Errorf("expected String() to round-trip %q, got %q", tt.input, got.String())
+			}
+		})
+	}
+
+	if _, err := ParseModeFromString("diff"); err == nil {
+		t.Error("expected an error for an unknown parse mode")
+	}
+}
+
+func TestUnquotedValueKeepsTrailingWhitespace(t *testing.T) {
+	input := "set system host-name r1  \t\nset system domain-name example.com "
+	var reconstructed string
+	for _, tok := range New(input).Tokenize() {
+		reconstructed += tok.Value
+		if tok.Type == TokenValue && strings.TrimRight(tok.Value, " \t") != tok.Value {
+			t.Errorf("expected value %q without trailing whitespace", tok.Value)
+		}
+	}
+	if reconstructed != input {
+		t.Errorf("expected %q, got %q", input, reconstructed)
+	}
+}
+
+// isisAdjacencyFixture is sample "show isis adjacency" output
+const isisAdjacencyFixture = `Interface             System         L State        Hold (secs) SNPA
+ge-0/0/0.0            r2             2  Up                    23  0:5:86:71:fb:c1
+ge-0/0/1.0            r3             1  Down                   0
+ge-0/0/2.0            r4             3  Initializing          22
+`
+
+func TestISISAdjacency(t *testing.T) {
+	l := New(isisAdjacencyFixture)
+	if l.detectParseMode() != ParseModeShow {
+		t.Fatal("expected isis adjacency output to be detected as show output")
+	}
+	tokens := l.Tokenize()
+
+	tests := []struct {
+		after string
+		value string
+		want  TokenType
+	}{
+		{"r2", "2", TokenLevel},
+		{"2", "Up", TokenStateGood},
+		{"Up", "23", TokenTimeDuration},
+		{"r3", "1", TokenLevel},
+		{"1", "Down", TokenStateBad},
+		{"Down", "0", TokenTimeDuration},
+		{"r4", "3", TokenLevel},
+		{"3", "Initializing", TokenStateWarning},
+		{"Initializing", "22", TokenTimeDuration},
+		{"Interface", "System", TokenColumnHeader},
+		{"State", "Hold", TokenColumnHeader},
+		{"(secs)", "SNPA", TokenColumnHeader},
+	}
+
+	for _, tt := range tests {
+		_, tok, ok := findTokenAfter(tokens, tt.after)
+		if !ok {
+			t.Errorf("did not find a token after %q", tt.after)
+			continue
+		}
+		if tok.Value != tt.value || tok.Type != tt.want {
+			t.Errorf("after %q: expected %q (%v), got %q (%v)", tt.after, tt.value, tt.want, tok.Value, tok.Type)
+		}
+	}
+}
+
+func TestISISLevelOnlyInAdjacencyTable(t *testing.T) {
+	// A bare 1, 2 or 3 before a state is a level only in the adjacency
+	// table; the table ends at a blank line
+	input := isisAdjacencyFixture + "\nPhysical interface: ge-0/0/0, Flaps 2 Up 3 Down\n"
+	l := New(input)
+	l.SetParseMode(ParseModeShow)
+	levels := 0
+	for _, tok := range l.Tokenize() {
+		if tok.Type == TokenLevel {
+			levels++
+			if tok.Line > 4 {
+				t.Errorf("line %d: expected %q outside the adjacency table not to be a level", tok.Line, tok.Value)
+			}
+		}
+	}
+	if levels != 3 {
+		t.Errorf("expected 3 levels in the adjacency table, got %d", levels)
+	}
+}
+
+func TestISISLevelWords(t *testing.T) {
+	l := New("  IS-IS level 2 adjacency to r2 on ge-0/0/0.0: L2 Up\n  Level 1 L1L2 router\n")
+	l.SetParseMode(ParseModeShow)
+	for _, tok := range l.Tokenize() {
+		switch tok.Value {
+		case "L2", "L1L2":
+			if tok.Type != TokenLevel {
+				t.Errorf("expected %q to be TokenLevel, got %v", tok.Value, tok.Type)
+			}
+		case "1", "2":
+			if tok.Type == TokenLevel {
+				t.Errorf("expected %q not followed by a state not to be a level", tok.Value)
+			}
+		}
+	}
+}
+
+func TestCommentPrefixes(t *testing.T) {
+	input := "! uplink to core\nset system services ssh # trailing\n"
+
+	commentValues := func(l *Lexer) []string {
+		var comments []string
+		for _, tok := range l.Tokenize() {
+			if tok.Type == TokenComment {
+				comments = append(comments, tok.Value)
+			}
+		}
+		return comments
+	}
+
+	// Default: only "#" starts a comment
+	got := commentValues(New(input))
+	if len(got) != 1 || got[0] != "# trailing" {
+		t.Errorf("expected only the # comment by default, got %q", got)
+	}
+
+	l := New(input)
+	l.SetCommentPrefixes("#", "!")
+	got = commentValues(l)
+	if len(got) != 2 || got[0] != "! uplink to core" || got[1] != "# trailing" {
+		t.Errorf("expected both ! and # comments, got %q", got)
+	}
+
+	// Without "#" in the set, "#" is ordinary text
+	l = New("set system host-name r1#2")
+	l.SetCommentPrefixes("!")
+	if got := commentValues(l); len(got) != 0 {
+		t.Errorf("expected no comments without # in the prefix set, got %q", got)
+	}
+}
+
+func TestBooleanValues(t *testing.T) {
+	tests := []struct {
+		input string
+		value string
+	}{
+		{"set protocols ospf graceful-restart helper-disable on", "on"},
+		{"set interfaces ge-0/0/0 unit 0 family inet no-redirects off", "off"},
+		{"set system services netconf traceoptions flag all true", "true"},
+		{"set chassis fpc 0 power false", "false"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			l := New(tt.input)
+			l.SetParseMode(ParseModeConfig)
+			for _, tok := range l.Tokenize() {
+				if tok.Value == tt.value {
+					if tok.Type != TokenValue {
+						t.Errorf("expected %q to be TokenValue, got %v", tt.value, tok.Type)
+					}
+					return
+				}
+			}
+			t.Errorf("did not find %q", tt.value)
+		})
+	}
+}
+
+func TestBooleanValuesGatedOnConfigMode(t *testing.T) {
+	l := New("Interface  Admin Link\nge-0/0/0   on    off\n")
+	l.SetParseMode(ParseModeShow)
+	for _, tok := range l.Tokenize() {
+		if (tok.Value == "on" || tok.Value == "off") && tok.Type == TokenValue {
+			t.Errorf("expected %q not to be a boolean value in show mode", tok.Value)
+		}
+	}
+}
+
+func FuzzTokenize(f *testing.F) {
+	seeds := []string{
+		"set interfaces ge-0/0/0 unit 0 family inet address 192.168.1.1/24",
+		"interfaces {\n    ge-0/0/0 {\n        description \"uplink\";\n    }\n}\n",
+		"user@router> show bgp summary\n",
+		"- set system host-name r1\n+ set system host-name r2\n[edit system]\n",
+		"description \"unterminated",
+		"/* unterminated block comment",
+		"<*",
+		routeTableFixture,
+		flowSessionFixture,
+		"2001:db8:::::::::::::::::::::::::::::::::::::::::::::::::::::1",
+		"{{{{{{{{{{{{{{{{{{{{}}}}}}}}}}}}}}}}}}}",
+		"0@0#0 ",
+	}
+	for _, seed := range seeds {
+		f.Add(seed)
+	}
+
+	f.Fuzz(func(t *testing.T, input string) {
+		for _, mode := range []ParseMode{ParseModeAuto, ParseModeConfig, ParseModeShow} {
+			l := New(input)
+			if mode != ParseModeAuto {
+				l.SetParseMode(mode)
+			}
+			var sb strings.Builder
+			for _, tok := range l.Tokenize() {
+				sb.WriteString(tok.Value)
+			}
+			if sb.String() != input {
+				t.Fatalf("%v mode: tokens don't reconstruct the input\ninput: %q\n  got: %q", mode, input, sb.String())
+			}
+		}
+	})
+}
+
+func TestPromptKeepsTrailingWhitespace(t *testing.T) {
+	input := "user@router> show version  "
+	var reconstructed string
+	for _, tok := range New(input).Tokenize() {
+		reconstructed += tok.Value
+	}
+	if reconstructed != input {
+		t.Errorf("expected %q, got %q", input, reconstructed)
+	}
+}
+
+// tokenizeTime returns the fastest of a few runs tokenizing input in mode,
+// to keep scheduler noise out of scaling comparisons
+func tokenizeTime(input string, mode ParseMode) time.Duration {
+	fastest := time.Duration(-1)
+	for i := 0; i < 3; i++ {
+		l := New(input)
+		l.SetParseMode(mode)
+		start := time.Now()
+		l.Tokenize()
+		if elapsed := time.Since(start); fastest < 0 || elapsed < fastest {
+			fastest = elapsed
+		}
+	}
+	return fastest
+}
+
+// assertLinear fails if tokenizing a line of 4n words takes much more than
+// four times as long as a line of n words. Quadratic growth would be 16
+// times; the bound leaves room for timer noise without depending on how
+// fast the machine is.
+func assertLinear(t *testing.T, mode ParseMode, line func(n int) string) {
+	t.Helper()
+	const n = 1000
+	small := tokenizeTime(line(n), mode)
+	large := tokenizeTime(line(4*n), mode)
+	if small <= 0 {
+		small = 1
+	}
+	if ratio := float64(large) / float64(small); ratio > 10 {
+		t.Errorf("tokenizing 4x the words took %.1fx as long (%v vs %v), want linear growth", ratio, large, small)
+	}
+}
+
+func TestLongSessionLineIsLinear(t *testing.T) {
+	// Every word looks like a session endpoint; the arrow lookup must not
+	// rescan the line for each one
+	assertLinear(t, ParseModeShow, func(n int) string {
+		return strings.Repeat("10.0.0.1/1 ", n) + "--> 10.0.0.2/2\n"
+	})
+}
+
+func TestLongConfigLineIsLinear(t *testing.T) {
+	// Every word is an AS number; the confederation lookup must not rescan
+	// the line for each one
+	assertLinear(t, ParseModeConfig, func(n int) string {
+		return "set routing-options confederation 65000 members [ " + strings.Repeat("65001 ", n) + "]\n"
+	})
+}
+
+// ospfDatabaseFixture is sample "show ospf database" output
+const ospfDatabaseFixture = `
+    OSPF database, Area 0.0.0.0
+ Type       ID               Adv Rtr           Seq      Age  Opt  Cksum  Len
+Router  *10.0.0.1         10.0.0.1         0x80000005   120  0x22 0x1a2b  48
+Network  10.0.1.2         10.0.0.2         0x80000001   300  0x22 0x5e6f  32
+Summary  10.1.0.0         10.0.0.1         0x80000002    90  0x22 0x7a8b  28
+    OSPF AS SCOPE link state database
+ Type       ID               Adv Rtr           Seq      Age  Opt  Cksum  Len
+Extern  *0.0.0.0          10.0.0.1         0x80000004   600  0x22 0x9c0d  36
+`
+
+func TestOSPFDatabase(t *testing.T) {
+	l := New(ospfDatabaseFixture)
+	if l.detectParseMode() != ParseModeShow {
+		t.Fatal("expected ospf database output to be detected as show output")
+	}
+	tokens := l.Tokenize()
+
+	expected := map[string]TokenType{
+		"Router":     TokenColumnHeader,
+		"Network":    TokenColumnHeader,
+		"Summary":    TokenColumnHeader,
+		"Extern":     TokenColumnHeader,
+		"10.0.1.2":   TokenIPv4,
+		"0x80000005": TokenNumber,
+		"0x1a2b":     TokenNumber,
+		"120":        TokenNumber,
+		"ID":         TokenColumnHeader,
+		"Adv":        TokenColumnHeader,
+		"Seq":        TokenColumnHeader,
+		"Age":        TokenColumnHeader,
+		"Cksum":      TokenColumnHeader,
+	}
+
+	seen := map[string]bool{}
+	for _, tok := range tokens {
+		if exp, ok := expected[tok.Value]; ok {
+			seen[tok.Value] = true
+			if tok.Type != exp {
+				t.Errorf("expected %q to be %v, got %v", tok.Value, exp, tok.Type)
+			}
+		}
+	}
+	for value := range expected {
+		if !seen[value] {
+			t.Errorf("did not find %q", value)
+		}
+	}
+}
+
+func TestLSATypesOnlyAtLineStart(t *testing.T) {
+	l := New("  Router ID: 10.0.0.1, Network type: LAN\n")
+	l.SetParseMode(ParseModeShow)
+	for _, tok := range l.Tokenize() {
+		if (tok.Value == "Router" || tok.Value == "Network") && tok.Type == TokenColumnHeader {
+			t.Errorf("expected %q mid-line not to be an LSA type", tok.Value)
+		}
+	}
+}
+
+func TestFirstWordIsCommand(t *testing.T) {
+	input := "frobnicate interfaces ge-0/0/0\n    frobnicate indented\nset frobnicate 1\n"
+
+	commandValues := func(l *Lexer) []string {
+		var commands []string
+		for _, tok := range l.Tokenize() {
+			if tok.Type == TokenCommand {
+				commands = append(commands, tok.Value)
+			}
+		}
+		return commands
+	}
+
+	l := New(input)
+	l.SetParseMode(ParseModeConfig)
+	if got := commandValues(l); len(got) != 1 || got[0] != "set" {
+		t.Errorf("expected only 'set' as a command by default, got %q", got)
+	}
+
+	l = New(input)
+	l.SetParseMode(ParseModeConfig)
+	l.SetFirstWordIsCommand(true)
+	got := commandValues(l)
+	if len(got) != 2 || got[0] != "frobnicate" || got[1] != "set" {
+		t.Errorf("expected the leading 'frobnicate' and 'set' as commands, got %q", got)
+	}
+
+	// Words that are already classified, or don't look like verbs, are left alone
+	l = New("ge-0/0/0 unit 0\nFooBar baz\n")
+	l.SetParseMode(ParseModeConfig)
+	l.SetFirstWordIsCommand(true)
+	if got := commandValues(l); len(got) != 0 {
+		t.Errorf("expected no commands, got %q", got)
+	}
+}
+
+func TestFirstWordIsCommandAfterPrompt(t *testing.T) {
+	l := New("user@router> frobnicate chassis")
+	l.SetFirstWordIsCommand(true)
+	for _, tok := range l.Tokenize() {
+		if tok.Value == "frobnicate" && tok.Type != TokenCommand {
+			t.Errorf("expected 'frobnicate' after the prompt to be a command, got %v", tok.Type)
+		}
+	}
+}
+
+func TestAsPathRegex(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		regex string
+	}{
+		{"quoted", `set policy-options as-path AS1 ".* 65000 .*"`, `".* 65000 .*"`},
+		{"unquoted", "set policy-options as-path AS2 65000+.*", "65000+.*"},
+		{"hierarchical", `as-path transit ".* (65001|65002) .*";`, `".* (65001|65002) .*"`},
+		{"community member", "set policy-options community C2 members 65000:1.*", "65000:1.*"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := New(tt.input)
+			l.SetParseMode(ParseModeConfig)
+			tokens := l.Tokenize()
+
+			found := false
+			for _, tok := range tokens {
+				if tok.Value == tt.regex {
+					found = true
+					if tok.Type != TokenValue {
+						t.Errorf("expected regex %q to be TokenValue, got %v", tt.regex, tok.Type)
+					}
+				}
+				if tok.Type == TokenASN {
+					t.Errorf("expected the as-path name not to be an ASN, got %q", tok.Value)
+				}
+			}
+			if !found {
+				t.Errorf("expected regex %q as a single token", tt.regex)
+			}
+		})
+	}
+}
+
+func TestAsPathReferenceDoesNotSwallowNextLine(t *testing.T) {
+	input := "set policy-options policy-statement p term t from as-path AS1\nset policy-options policy-statement p term t then accept\n"
+	l := New(input)
+	l.SetParseMode(ParseModeConfig)
+	for _, tok := range l.Tokenize() {
+		if tok.Type == TokenValue {
+			t.Errorf("expected no regex value for an as-path reference, got %q", tok.Value)
+		}
+	}
+}
+
+func TestCommunityMembersStayCommunities(t *testing.T) {
+	l := New("set policy-options community C3 members [ 65000:100 65000:200 ]")
+	l.SetParseMode(ParseModeConfig)
+	for _, tok := range l.Tokenize() {
+		if strings.HasPrefix(tok.Value, "65000:") && tok.Type != TokenCommunity {
+			t.Errorf("expected %q to be TokenCommunity, got %v", tok.Value, tok.Type)
+		}
+	}
+}
+
+// forwardingTableFixture is sample "show route forwarding-table" output
+const forwardingTableFixture = `Routing table: default.inet
+Internet:
+Destination        Type RtRef Next hop           Type Index    NhRef Netif
+default            user     0 10.0.0.1           ucst      512     2 ge-0/0/0.0
+default            perm     0                    rjct       36     1
+0.0.0.0/32         perm     0                    dscd       34     1
+10.0.0.0/24        intf     0                    rslv      522     1 ge-0/0/0.0
+10.0.0.2/32        intf     0 10.0.0.2           locl      520     2
+10.0.0.255/32      dest     0 10.0.0.255         recv      518     1 ge-0/0/1.0
+192.168.0.0/16     user     0                    indr   262142     2
+`
+
+func TestForwardingTableNextHopTypes(t *testing.T) {
+	l := New(forwardingTableFixture)
+	if l.detectParseMode() != ParseModeShow {
+		t.Fatal("expected forwarding table output to be detected as show output")
+	}
+	tokens := l.Tokenize()
+
+	expected := map[string]TokenType{
+		"ucst":        TokenStateGood,
+		"rjct":        TokenStateBad,
+		"dscd":        TokenStateBad,
+		"recv":        TokenStateNeutral,
+		"locl":        TokenStateNeutral,
+		"indr":        TokenStateNeutral,
+		"ge-0/0/1.0":  TokenInterface,
+		"10.0.0.0/24": TokenIPv4Prefix,
+		"Netif":       TokenColumnHeader,
+		"Destination": TokenColumnHeader,
+		"RtRef":       TokenColumnHeader,
+	}
+
+	seen := map[string]bool{}
+	for _, tok := range tokens {
+		if exp, ok := expected[tok.Value]; ok {
+			seen[tok.Value] = true
+			if tok.Type != exp {
+				t.Errorf("expected %q to be %v, got %v", tok.Value, exp, tok.Type)
+			}
+		}
+	}
+	for value := range expected {
+		if !seen[value] {
+			t.Errorf("did not find %q", value)
+		}
+	}
+}
+
+const option82Fixture = `forwarding-options {
+    dhcp-relay {
+        relay-option-82 {
+            circuit-id {
+                use-interface-description device;
+            }
+            remote-id;
+        }
+    }
+}
+access {
+    address-assignment {
+        pool subscribers {
+            family inet {
+                dhcp-attributes {
+                    option 82 hex-string 0x0102abcd;
+                    option 150 ip-address 10.0.0.5;
+                }
+            }
+        }
+    }
+}
+dynamic-profiles {
+    profile-a {
+        agent-circuit-id 0x000a0b0c;
+        agent-remote-id ge-1/0/0:100;
+    }
+}
+`
+
+func TestDHCPOption82(t *testing.T) {
+	l := New(option82Fixture)
+	l.SetParseMode(ParseModeConfig)
+	tokens := l.Tokenize()
+
+	expected := map[string]TokenType{
+		"relay-option-82": TokenKeyword,
+		"circuit-id":      TokenKeyword,
+		"remote-id":       TokenKeyword,
+		"hex-string":      TokenKeyword,
+		"82":              TokenUnit,
+		"150":             TokenUnit,
+		"0x0102abcd":      TokenValue,
+		"0x000a0b0c":      TokenValue,
+		"10.0.0.5":        TokenIPv4,
+		"ge-1/0/0:100":    TokenInterface,
+	}
+
+	seen := map[string]bool{}
+	for _, tok := range tokens {
+		if exp, ok := expected[tok.Value]; ok {
+			seen[tok.Value] = true
+			if tok.Type != exp {
+				t.Errorf("expected %q to be %v, got %v", tok.Value, exp, tok.Type)
+			}
+		}
+	}
+	for value := range expected {
+		if !seen[value] {
+			t.Errorf("did not find %q", value)
+		}
+	}
+}
+
+const securityPolicyFixture = `security {
+    policies {
+        from-zone trust to-zone untrust {
+            policy allow-web {
+                match {
+                    source-address [ web-servers db-servers ];
+                    destination-address any;
+                    source-address-excluded;
+                    application junos-http;
+                }
+                then {
+                    permit;
+                }
+            }
+        }
+    }
+}
+`
+
+func TestSecurityPolicyReferences(t *testing.T) {
+	l := New(securityPolicyFixture)
+	l.SetParseMode(ParseModeConfig)
+	tokens := l.Tokenize()
+
+	expected := map[string]TokenType{
+		"from-zone":               TokenKeyword,
+		"trust":                   TokenValue,
+		"untrust":                 TokenValue,
+		"web-servers":             TokenValue,
+		"db-servers":              TokenValue,
+		"any":                     TokenValue,
+		"source-address-excluded": TokenKeyword,
+		"junos-http":              TokenIdentifier,
+	}
+
+	seen := map[string]bool{}
+	for _, tok := range tokens {
+		if exp, ok := expected[tok.Value]; ok {
+			seen[tok.Value] = true
+			if tok.Type != exp {
+				t.Errorf("expected %q to be %v, got %v", tok.Value, exp, tok.Type)
+			}
+		}
+	}
+	for value := range expected {
+		if !seen[value] {
+			t.Errorf("did not find %q", value)
+		}
+	}
+}
+
+func TestFilterAddressesStayPrefixes(t *testing.T) {
+	l := New("source-address 10.0.0.0/8 except;\ndestination-port [ ssh 443 ];")
+	l.SetParseMode(ParseModeConfig)
+	for _, tok := range l.Tokenize() {
+		if tok.Type == TokenValue {
+			t.Errorf("expected no values outside zone/address references, got %q", tok.Value)
+		}
+	}
+}
+
+// uptimeFixture is sample "show system uptime" output
+const uptimeFixture = `Current time: 2024-01-15 10:30:00 UTC
+Time Source:  NTP CLOCK
+System booted: 2023-12-01 08:00:00 UTC (6w3d 02:30 ago)
+Protocols started: 2023-12-01 08:02:00 UTC (6w3d 02:28 ago)
+Last configured: 2024-01-10 14:00:00 UTC (4d 20:30 ago) by admin
+10:30AM  up 45 days,  2:30, 2 users, load averages: 2.15, 0.48, 0.45
+`
+
+func TestSystemUptime(t *testing.T) {
+	l := New(uptimeFixture)
+	if l.detectParseMode() != ParseModeShow {
+		t.Fatal("expected uptime output to be detected as show output")
+	}
+	tokens := l.Tokenize()
+
+	expected := map[string]TokenType{
+		"2024-01-15": TokenTimestamp,
+		"10:30:00":   TokenTimestamp,
+		"UTC":        TokenTimestamp,
+		"10:30AM":    TokenTimestamp,
+		"6w3d":       TokenTimeDuration,
+		"02:30":      TokenTimeDuration,
+		"2:30":       TokenTimeDuration,
+		"2.15":       TokenStateWarning,
+		"0.48":       TokenNumber,
+		"0.45":       TokenNumber,
+	}
+
+	seen := map[string]bool{}
+	for _, tok := range tokens {
+		if exp, ok := expected[tok.Value]; ok {
+			seen[tok.Value] = true
+			if tok.Type != exp {
+				t.Errorf("expected %q to be %v, got %v", tok.Value, exp, tok.Type)
+			}
+		}
+	}
+	for value := range expected {
+		if !seen[value] {
+			t.Errorf("did not find %q", value)
+		}
+	}
+}
+
+func TestFloatsOutsideLoadAverages(t *testing.T) {
+	l := New("Peer   AS   Flaps   Ratio\n10.0.0.1   65001   2   1.50\n")
+	l.SetParseMode(ParseModeShow)
+	for _, tok := range l.Tokenize() {
+		if tok.Value == "1.50" && tok.Type == TokenStateWarning {
+			t.Error("expected floats outside load averages not to be flagged")
+		}
+	}
+}
+
+func TestReplacePattern(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		operands map[string]TokenType
+	}{
+		{"names", "replace pattern old-name with new-name", map[string]TokenType{
+			"old-name": TokenValue, "new-name": TokenValue,
+		}},
+		{"regex", "replace pattern ge-0/0/.* with xe-0/0/$1", map[string]TokenType{
+			"ge-0/0/.*": TokenValue, "xe-0/0/$1": TokenValue,
+		}},
+		{"interfaces", "replace pattern ge-0/0/0 with ge-1/0/0", map[string]TokenType{
+			"ge-0/0/0": TokenInterface, "ge-1/0/0": TokenInterface,
+		}},
+		{"quoted", `replace pattern "unit 0" with "unit 1"`, map[string]TokenType{
+			`"unit 0"`: TokenString, `"unit 1"`: TokenString,
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := New(tt.input)
+			l.SetParseMode(ParseModeConfig)
+
+			expected := map[string]TokenType{
+				"replace": TokenCommand,
+				"pattern": TokenKeyword,
+				"with":    TokenKeyword,
+			}
+			for value, typ := range tt.operands {
+				expected[value] = typ
+			}
+
+			seen := map[string]bool{}
+			for _, tok := range l.Tokenize() {
+				if exp, ok := expected[tok.Value]; ok {
+					seen[tok.Value] = true
+					if tok.Type != exp {
+						t.Errorf("expected %q to be %v, got %v", tok.Value, exp, tok.Type)
+					}
+				}
+			}
+			for value := range expected {
+				if !seen[value] {
+					t.Errorf("did not find %q", value)
+				}
+			}
+		})
+	}
+}
+
+func TestLongReplaceLineIsLinear(t *testing.T) {
+	// Every other word follows "with"; the replace lookup must not rescan
+	// the line for each one
+	assertLinear(t, ParseModeConfig, func(n int) string {
+		return "replace pattern a " + strings.Repeat("with b ", n) + "\n"
+	})
+}
+
+func TestClassifyLine(t *testing.T) {
+	tests := []struct {
+		line string
+		want LineKind
+	}{
+		{"admin@router> show bgp summary", LinePrompt},
+		{"[edit interfaces]", LineDiff},
+		{"+    description uplink;", LineDiff},
+		{"- unit 0;", LineDiff},
+		{"set system host-name r1", LineConfig},
+		{"    host-name r1;", LineConfig},
+		{"interfaces {", LineConfig},
+		{"10.0.0.1   65001   12345   Establ", LineShow},
+		{"  inet.0: 150/200/180/0", LineShow},
+		{"hello world", LineText},
+		{"", LineText},
+		{"   \r\n", LineText},
+	}
+
+	for _, tt := range tests {
+		if got := ClassifyLine(tt.line); got != tt.want {
+			t.Errorf("ClassifyLine(%q) = %v, want %v", tt.line, got, tt.want)
+		}
+	}
+}
+
+func TestSNMPOIDs(t *testing.T) {
+	tests := []struct {
+		input string
+		word  string
+		want  TokenType
+	}{
+		{"set snmp view all-mib oid 1.3.6.1.2.1.2.2.1.8 include", "1.3.6.1.2.1.2.2.1.8", TokenOID},
+		{"set snmp view juniper oid .1.3.6.1.4.1.2636 include", ".1.3.6.1.4.1.2636", TokenOID},
+		{"set snmp view internet oid 1.3.6.1 include", "1.3.6.1", TokenOID},
+		{"snmpTrapOID.0 = IF-MIB::linkDown", "IF-MIB::linkDown", TokenOID},
+		{"set snmp trap-options source-address 10.1.2.3", "10.1.2.3", TokenIPv4},
+		{"set snmp community public clients 192.168.1.0/24", "192.168.1.0/24", TokenIPv4Prefix},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.word, func(t *testing.T) {
+			l := New(tt.input)
+			found := false
+			for _, tok := range l.Tokenize() {
+				if tok.Value == tt.word {
+					found = true
+					if tok.Type != tt.want {
+						t.Errorf("expected %q to be %v, got %v", tt.word, tt.want, tok.Type)
+					}
+				}
+			}
+			if !found {
+				t.Errorf("did not find %q", tt.word)
+			}
+		})
+	}
+}
+
+const routingInstancesFixture = `routing-instances {
+    CUSTOMER-A {
+        instance-type vrf;
+        interface ge-0/0/1.100;
+        route-distinguisher 65000:100;
+    }
+    CUSTOMER-B {
+        instance-type virtual-router;
+        protocols {
+            ospf {
+                area 0.0.0.0;
+            }
+        }
+    }
+}
+logical-systems {
+    LS1 {
+        interfaces {
+            ge-0/0/2 {
+                unit 0;
+            }
+        }
+    }
+}
+`
+
+func TestInstanceNames(t *testing.T) {
+	l := New(routingInstancesFixture)
+	l.SetParseMode(ParseModeConfig)
+	tokens := l.Tokenize()
+
+	expected := map[string]TokenType{
+		"CUSTOMER-A":        TokenValue,
+		"CUSTOMER-B":        TokenValue,
+		"LS1":               TokenValue,
+		"routing-instances": TokenSection,
+		"logical-systems":   TokenSection,
+		"interfaces":        TokenSection,
+		"ospf":              TokenProtocol,
+		"ge-0/0/2":          TokenInterface,
+	}
+
+	seen := map[string]bool{}
+	for _, tok := range tokens {
+		if exp, ok := expected[tok.Value]; ok {
+			seen[tok.Value] = true
+			if tok.Type != exp {
+				t.Errorf("expected %q to be %v, got %v", tok.Value, exp, tok.Type)
+			}
+		}
+	}
+	for value := range expected {
+		if !seen[value] {
+			t.Errorf("did not find %q", value)
+		}
+	}
+}
+
+func TestInstanceNamesSetStyle(t *testing.T) {
+	tests := []struct {
+		input string
+		names []string
+	}{
+		{"set routing-instances CUSTOMER-A instance-type vrf", []string{"CUSTOMER-A"}},
+		{"set logical-systems LS1 interfaces ge-0/0/2 unit 0", []string{"LS1"}},
+		{"set tenants T1 routing-instances VRF1 interface ge-0/0/1.0", []string{"T1", "VRF1"}},
+	}
+
+	for _, tt := range tests {
+		l := New(tt.input)
+		l.SetParseMode(ParseModeConfig)
+		types := map[string]TokenType{}
+		for _, tok := range l.Tokenize() {
+			types[tok.Value] = tok.Type
+		}
+		for _, name := range tt.names {
+			if types[name] != TokenValue {
+				t.Errorf("%q: expected %q to be TokenValue, got %v", tt.input, name, types[name])
+			}
+		}
+	}
+}
+
+// commitConfirmedFixture is sample output around "commit confirmed"
+const commitConfirmedFixture = `commit confirmed will be automatically rolled back in 10 minutes unless confirmed
+commit complete
+Commit was not confirmed; automatic rollback complete.
+`
+
+func TestCommitConfirmed(t *testing.T) {
+	l := New(commitConfirmedFixture)
+	if l.detectParseMode() != ParseModeShow {
+		t.Fatal("expected commit confirmed output to be detected as show output")
+	}
+
+	var got []string
+	for _, tok := range l.Tokenize() {
+		switch tok.Type {
+		case TokenStateGood, TokenStateWarning, TokenTimeDuration:
+			got = append(got, tok.Value+"="+tok.Type.String())
+		}
+	}
+
+	want := []string{
+		"confirmed=StateGood",
+		"rolled=StateWarning",
+		"back=StateWarning",
+		"10=TimeDuration",
+		"confirmed=StateWarning",
+		"complete=StateGood",
+		"confirmed=StateWarning",
+		"rollback=StateWarning",
+	}
+	if strings.Join(got, " ") != strings.Join(want, " ") {
+		t.Errorf("got  %v\nwant %v", got, want)
+	}
+}
+
+func TestRollbackOutsideCommitConfirmed(t *testing.T) {
+	// Rollbacks listed in commit history are ordinary words
+	input := "1   2024-01-14 09:12:45 UTC by netops via cli\n    rollback 2, rolled config\nrollback 3 loaded\n"
+	l := New(input)
+	l.SetParseMode(ParseModeShow)
+	for _, tok := range l.Tokenize() {
+		if tok.Type == TokenStateWarning {
+			t.Errorf("expected %q not to be a warning", tok.Value)
+		}
+	}
+}
+
+func TestAddressFamilies(t *testing.T) {
+	families := []string{
+		"inet", "inet6", "mpls", "iso", "ethernet-switching", "bridge",
+		"ccc", "vpls", "evpn", "inet-vpn", "l2vpn", "route-target", "any",
+	}
+	prefixes := []string{
+		"set interfaces ge-0/0/0 unit 0 family ",
+		"set protocols bgp group ibgp family ",
+		"set firewall family ",
+	}
+
+	for _, prefix := range prefixes {
+		for _, family := range families {
+			input := prefix + family
+			l := New(input)
+			l.SetParseMode(ParseModeConfig)
+			tokens := l.Tokenize()
+
+			last := tokens[len(tokens)-1]
+			if last.Value != family || last.Type != TokenProtocol {
+				t.Errorf("%q: expected %q to be TokenProtocol, got %q as %v", input, family, last.Value, last.Type)
+			}
+		}
+	}
+}
+
+func TestFamilyNamesElsewhere(t *testing.T) {
+	// "evpn" outside a family statement is still a section
+	l := New("set protocols evpn encapsulation vxlan")
+	l.SetParseMode(ParseModeConfig)
+	for _, tok := range l.Tokenize() {
+		if tok.Value == "evpn" && tok.Type != TokenSection {
+			t.Errorf("expected evpn to be TokenSection, got %v", tok.Type)
+		}
+	}
+}
+
+func TestDetectionReport(t *testing.T) {
+	input := "Peer            AS      State\n10.0.0.1     65001    Establ\n  inet.0: 10/12/12/0\n"
+	d := New(input).DetectionReport()
+
+	if d.Mode != ParseModeShow {
+		t.Errorf("expected show mode, got %v", d.Mode)
+	}
+	if !d.Tabular {
+		t.Error("expected the tabular pattern to be found")
+	}
+	if strings.Join(d.ShowIndicators, ",") != "establ,inet.0" {
+		t.Errorf("expected show indicators establ and inet.0, got %v", d.ShowIndicators)
+	}
+	if d.ShowScore != 4 || d.ConfigScore != 0 {
+		t.Errorf("expected scores config 0 / show 4, got %d / %d", d.ConfigScore, d.ShowScore)
+	}
+	if want := "show (config 0 [], show 4 [establ, inet.0, tabular])"; d.String() != want {
+		t.Errorf("String() = %q, want %q", d.String(), want)
+	}
+
+	// The report agrees with what Tokenize detects
+	l := New("set system host-name r1;")
+	d = l.DetectionReport()
+	l.Tokenize()
+	if d.Mode != l.GetParseMode() {
+		t.Errorf("report mode %v differs from detected mode %v", d.Mode, l.GetParseMode())
+	}
+	if strings.Join(d.ConfigIndicators, ",") != "set,;,host-name" || d.ConfigScore != 3 {
+		t.Errorf("expected config indicators set, ; and host-name, got %v (%d)", d.ConfigIndicators, d.ConfigScore)
+	}
+}
+
+// bfdSessionFixture is sample "show bfd session" output
+const bfdSessionFixture = `                                                  Detect   Transmit
+Address                  State     Interface      Time     Interval  Multiplier
+10.0.0.2                 Up        ge-0/0/0.0     0.900     0.300        3
+10.0.0.6                 Down      ge-0/0/1.0     3.000     1.000        3
+10.0.0.10                AdminDown xe-0/0/2.0     0.000     1.000        3
+10.0.0.14                Init      ge-0/0/3.0     0.900     0.300        3
+
+4 sessions, 4 clients
+`
+
+func TestBFDSessions(t *testing.T) {
+	l := New(bfdSessionFixture)
+	if l.detectParseMode() != ParseModeShow {
+		t.Fatal("expected BFD output to be detected as show output")
+	}
+	tokens := l.Tokenize()
+
+	expected := map[string]TokenType{
+		"Up":         TokenStateGood,
+		"Down":       TokenStateBad,
+		"AdminDown":  TokenStateNeutral,
+		"Init":       TokenStateWarning,
+		"0.900":      TokenTimeDuration,
+		"0.300":      TokenTimeDuration,
+		"3.000":      TokenTimeDuration,
+		"3":          TokenNumber,
+		"Multiplier": TokenColumnHeader,
+		"Detect":     TokenColumnHeader,
+		"Transmit":   TokenColumnHeader,
+		"Address":    TokenColumnHeader,
+		"Time":       TokenColumnHeader,
+		"ge-0/0/0.0": TokenInterface,
+	}
+
+	seen := map[string]bool{}
+	for _, tok := range tokens {
+		if exp, ok := expected[tok.Value]; ok {
+			seen[tok.Value] = true
+			if tok.Type != exp {
+				t.Errorf("expected %q to be %v, got %v", tok.Value, exp, tok.Type)
+			}
+		}
+	}
+	for value := range expected {
+		if !seen[value] {
+			t.Errorf("did not find %q", value)
+		}
+	}
+}
+
+func TestTableHeadersOnlyOnHeaderLine(t *testing.T) {
+	// Column names that are ordinary words are headers only on their own
+	// table's header line, not in other show output
+	tests := []string{
+		"Time to check the address interval",
+		"System hold on ge-0/0/0",
+		"Session id 5, seq 12, len 48",
+		"MAC age of the entry",
+		"Slot 0 memory and cpu buffer",
+		"Destination unreachable, index 512",
+	}
+
+	for _, input := range tests {
+		l := New(input + "\n")
+		l.SetParseMode(ParseModeShow)
+		for _, tok := range l.Tokenize() {
+			if tok.Type == TokenColumnHeader {
+				t.Errorf("%q: expected %q not to be a column header", input, tok.Value)
+			}
+		}
+	}
+}
+
+func TestSetCLIPreferences(t *testing.T) {
+	tests := []struct {
+		input string
+		want  map[string]TokenType
+	}{
+		{"set cli screen-length 0", map[string]TokenType{"screen-length": TokenKeyword, "0": TokenNumber}},
+		{"set cli screen-width 200", map[string]TokenType{"screen-width": TokenKeyword, "200": TokenNumber}},
+		{"set cli terminal xterm", map[string]TokenType{"terminal": TokenKeyword, "xterm": TokenValue}},
+		{"set cli idle-timeout 30", map[string]TokenType{"idle-timeout": TokenKeyword, "30": TokenNumber}},
+		{`set cli prompt "lab> "`, map[string]TokenType{"prompt": TokenKeyword, `"lab> "`: TokenValue}},
+	}
+
+	for _, tt := range tests {
+		l := New(tt.input)
+		l.SetParseMode(ParseModeConfig)
+		tokens := l.Tokenize()
+
+		if tokens[0].Type != TokenCommand || tokens[2].Value != "cli" || tokens[2].Type != TokenCommand {
+			t.Errorf("%q: expected \"set cli\" to be commands", tt.input)
+		}
+		for _, tok := range tokens {
+			if exp, ok := tt.want[tok.Value]; ok && tok.Type != exp {
+				t.Errorf("%q: expected %q to be %v, got %v", tt.input, tok.Value, exp, tok.Type)
+			}
+		}
+	}
+}
+
+const pfeStatisticsFixture = `Packet Forwarding Engine Local Traffic statistics:
+    Local packets input                 :              1234567
+    Software input control plane drops  :                    0
+    Software input high drops           :                   12
+Packet Forwarding Engine hardware discard statistics:
+    Timeout                    :                    0
+    Normal discard             :                 5678
+    Stack underflow            :                    7
+Packet Forwarding Engine Input IPv4 Header Checksum Error and Output MTU Error statistics:
+    Output MTU                 :                   42
+`
+
+func TestPFEDropCounters(t *testing.T) {
+	l := New(pfeStatisticsFixture)
+	l.SetParseMode(ParseModeShow)
+	tokens := l.Tokenize()
+
+	expected := map[string]TokenType{
+		"drops":     TokenIdentifier,
+		"Timeout":   TokenIdentifier,
+		"underflow": TokenIdentifier,
+		"0":         TokenNumber,
+		"12":        TokenStateBad,
+		"5678":      TokenStateWarning,
+		"7":         TokenStateWarning,
+		"1234567":   TokenNumber,
+		"42":        TokenNumber,
+	}
+
+	seen := map[string]bool{}
+	for _, tok := range tokens {
+		if exp, ok := expected[tok.Value]; ok {
+			seen[tok.Value] = true
+			if tok.Type != exp {
+				t.Errorf("expected %q to be %v, got %v", tok.Value, exp, tok.Type)
+			}
+		}
+	}
+	for value := range expected {
+		if !seen[value] {
+			t.Errorf("did not find %q", value)
+		}
+	}
+}
+
+func TestPFEDiscardSectionEnds(t *testing.T) {
+	discard := "Packet Forwarding Engine hardware discard statistics:\n" +
+		"    Normal discard             :                 5678\n"
+	tests := []struct {
+		name  string
+		input string
+	}{
+		{"blank line", discard + "\n    Packets forwarded  :  99\n"},
+		{"prompt", discard + "admin@r1> show chassis fpc\n    Packets forwarded  :  99\n"},
+		{"section header", discard + "Traffic summary:\n    Packets forwarded  :  99\n"},
+	}
+	for _, tt := range tests {
+		l := New(tt.input)
+		l.SetParseMode(ParseModeShow)
+		for _, tok := range l.Tokenize() {
+			switch tok.Value {
+			case "5678":
+				if tok.Type != TokenStateWarning {
+					t.Errorf("%s: expected the discard counter to be a warning, got %v", tt.name, tok.Type)
+				}
+			case "99":
+				if tok.Type != TokenNumber {
+					t.Errorf("%s: expected an unrelated counter after the section to be a number, got %v", tt.name, tok.Type)
+				}
+			}
+		}
+	}
+}
+
+func TestInterfaceKeywordContexts(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		mode  ParseMode
+		want  TokenType
+	}{
+		{"ospf set", "set protocols ospf area 0.0.0.0 interface ge-0/0/0.0 metric 10", ParseModeConfig, TokenKeyword},
+		{"ospf block", "area 0 {\n    interface ge-0/0/0.0 passive;\n}", ParseModeConfig, TokenKeyword},
+		{"firewall match", "set firewall family inet filter f term t from interface ge-0/0/0.0", ParseModeConfig, TokenKeyword},
+		{"firewall block", "from {\n    interface ge-0/0/0.0;\n}", ParseModeConfig, TokenKeyword},
+		{"show header", "Interface              State     Area            DR ID\nge-0/0/0.0             BDR       0.0.0.0         10.0.0.2", ParseModeShow, TokenColumnHeader},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := New(tt.input)
+			l.SetParseMode(tt.mode)
+			tokens := l.Tokenize()
+
+			found := false
+			for i, tok := range tokens {
+				if !strings.EqualFold(tok.Value, "interface") {
+					continue
+				}
+				found = true
+				if tok.Type != tt.want {
+					t.Errorf("expected %q to be %v, got %v", tok.Value, tt.want, tok.Type)
+				}
+				if tt.mode == ParseModeConfig && (i+2 >= len(tokens) || tokens[i+2].Type != TokenInterface) {
+					t.Errorf("expected an interface name after %q", tok.Value)
+				}
+			}
+			if !found {
+				t.Error("did not find \"interface\"")
+			}
+		})
+	}
+}
+
+func TestVLANRanges(t *testing.T) {
+	tests := []struct {
+		input string
+		word  string
+		want  TokenType
+	}{
+		{"set vlans v100 vlan-id-list 10-20", "10-20", TokenNumber},
+		{"set vlans v100 vlan-id-list 100-200,300", "100-200,300", TokenNumber},
+		{"set vlans v100 vlan-id-list 100,200,300", "100,200,300", TokenNumber},
+		{"set interfaces ge-0/0/0 unit 0 family ethernet-switching vlan members [ 100 200-300 ]", "200-300", TokenNumber},
+		{"set vlans v100 vlan-id-list 300-", "300-", TokenIdentifier},
+		{"set vlans v100 vlan-id-list 100-200,", "100-200,", TokenIdentifier},
+		{"set vlans v100 vlan-id-list 10--20", "10--20", TokenIdentifier},
+	}
+
+	for _, tt := range tests {
+		l := New(tt.input)
+		l.SetParseMode(ParseModeConfig)
+		found := false
+		for _, tok := range l.Tokenize() {
+			if tok.Value == "vlan-id-list" && tok.Type != TokenKeyword {
+				t.Errorf("%q: expected vlan-id-list to be a keyword, got %v", tt.input, tok.Type)
+			}
+			if tok.Value == tt.word {
+				found = true
+				if tok.Type != tt.want {
+					t.Errorf("%q: expected %q to be %v, got %v", tt.input, tok.Value, tt.want, tok.Type)
+				}
+			}
+		}
+		if !found {
+			t.Errorf("%q: did not find %q", tt.input, tt.word)
+		}
+	}
+}
+
+const inactiveRouteFixture = `inet.0: 4 destinations, 5 routes (3 active, 0 holddown, 1 hidden)
+
+10.0.0.0/24        *[OSPF/10] 1d 02:00:00, metric 2
+                    > to 10.0.1.2 via ge-0/0/1.0
+                    [BGP/170] 3d 04:00:00, localpref 100
+                    > to 10.0.2.2 via ge-0/0/2.0
+10.0.1.0/24         [Static/5] 00:10:00
+                    > to 10.0.3.2 via ge-0/0/3.0
+10.0.2.0/24        +[BGP/170] 00:20:00, localpref 200
+10.0.3.0/24        -[RIP/100] 00:30:00, metric 3
+                Inactive reason: Route Preference
+`
+
+func TestInactiveRoutesDimmed(t *testing.T) {
+	l := New(inactiveRouteFixture)
+	l.SetParseMode(ParseModeShow)
+	tokens := l.Tokenize()
+
+	expected := map[string]TokenType{
+		// Active routes
+		"10.0.0.0/24": TokenIPv4Prefix,
+		"[OSPF/10]":   TokenRouteProtocol,
+		"1d":          TokenTimeDuration,
+		"10.0.2.0/24": TokenIPv4Prefix,
+		"+":           TokenStatusSymbol,
+		"00:20:00":    TokenTimeDuration,
+		// Inactive routes, including the last active one, only have their
+		// protocol, "-" marker and inactive reason dimmed
+		"[Static/5]": TokenStateNeutral,
+		"[RIP/100]":  TokenStateNeutral,
+		"-":          TokenStateNeutral,
+		"Inactive":   TokenStateNeutral,
+		"reason:":    TokenStateNeutral,
+		"Preference": TokenStateNeutral,
+		// Their prefix, age and next hops keep their colors
+		"10.0.1.0/24": TokenIPv4Prefix,
+		"3d":          TokenTimeDuration,
+		"00:10:00":    TokenTimeDuration,
+		"00:30:00":    TokenTimeDuration,
+		"10.0.3.2":    TokenIPv4,
+		"ge-0/0/3.0":  TokenInterface,
+	}
+
+	seen := map[string]bool{}
+	for _, tok := range tokens {
+		if exp, ok := expected[tok.Value]; ok {
+			seen[tok.Value] = true
+			if tok.Type != exp {
+				t.Errorf("expected %q to be %v, got %v", tok.Value, exp, tok.Type)
+			}
+		}
+	}
+	for value := range expected {
+		if !seen[value] {
+			t.Errorf("did not find %q", value)
+		}
+	}
+}
+
+func TestTypedKeywordValues(t *testing.T) {
+	tests := []struct {
+		input string
+		word  string
+		want  TokenType
+	}{
+		{"set protocols bgp group external peer-as 65000", "65000", TokenASN},
+		{"set protocols bgp group external peer-as 1.10", "1.10", TokenASN},
+		{"set protocols bgp group external peer-as AS65001", "AS65001", TokenASN},
+		{"set protocols bgp local-as 4200000000", "4200000000", TokenASN},
+		{"set routing-options autonomous-system 65002", "65002", TokenASN},
+		{"set routing-options router-id 10.0.0.1", "10.0.0.1", TokenIPv4},
+		{"set interfaces ge-0/0/0 unit 0 vlan-id 100", "100", TokenNumber},
+		{"protocols {\n    bgp {\n        peer-as 1.20;\n    }\n}", "1.20", TokenASN},
+		// Values that don't fit the type fall back to the generic rules
+		{"set vlans v100 vlan-id none", "none", TokenIdentifier},
+		// Only the word right after the keyword is typed
+		{"set protocols bgp group external peer-as 65000 4200000000", "4200000000", TokenNumber},
+	}
+
+	for _, tt := range tests {
+		l := New(tt.input)
+		l.SetParseMode(ParseModeConfig)
+		found := false
+		for _, tok := range l.Tokenize() {
+			if tok.Value == tt.word {
+				found = true
+				if tok.Type != tt.want {
+					t.Errorf("%q: expected %q to be %v, got %v", tt.input, tok.Value, tt.want, tok.Type)
+				}
+			}
+		}
+		if !found {
+			t.Errorf("%q: did not find %q", tt.input, tt.word)
+		}
+	}
+}
+
+func TestHexValuesInConfig(t *testing.T) {
+	tests := []struct {
+		input string
+		word  string
+		want  TokenType
+	}{
+		{"set firewall family ethernet-switching filter f term t from ether-type 0x8100", "0x8100", TokenNumber},
+		{"set firewall family inet filter f term t from flexible-match-mask mask-in-hex 0xff00 bit-length 16", "0xff00", TokenNumber},
+		{"set firewall family inet filter f term t from flexible-match-mask prefix 0x0000FFFF", "0x0000FFFF", TokenNumber},
+		{"set firewall family inet filter f term t from flexible-match-mask mask-in-hex 0xff00", "mask-in-hex", TokenKeyword},
+		// Hex option-82 IDs stay values
+		{"set forwarding-options dhcp-relay relay-option-82 circuit-id agent-circuit-id 0x000a0b0c", "0x000a0b0c", TokenValue},
+		// Not hex
+		{"set firewall family inet filter f term t from flexible-match-mask mask-in-hex 0xzz", "0xzz", TokenIdentifier},
+	}
+
+	for _, tt := range tests {
+		l := New(tt.input)
+		l.SetParseMode(ParseModeConfig)
+		found := false
+		for _, tok := range l.Tokenize() {
+			if tok.Value == tt.word {
+				found = true
+				if tok.Type != tt.want {
+					t.Errorf("%q: expected %q to be %v, got %v", tt.input, tok.Value, tt.want, tok.Type)
+				}
+			}
+		}
+		if !found {
+			t.Errorf("%q: did not find %q", tt.input, tt.word)
+		}
+	}
+}
+
+func TestASDotNotation(t *testing.T) {
+	tests := []struct {
+		input string
+		word  string
+		want  TokenType
+	}{
+		{"set routing-options autonomous-system 65000.100", "65000.100", TokenASN},
+		{"set protocols bgp group external peer-as 65000.100", "65000.100", TokenASN},
+		{"set protocols bgp group external local-as 1.10", "1.10", TokenASN},
+		{"set routing-options confederation 65000 members [ 65001 65002.5 ]", "65001", TokenASN},
+		{"set routing-options confederation 65000 members [ 65001 65002.5 ]", "65002.5", TokenASN},
+		{"set routing-options confederation 1.100 members 1.101", "1.101", TokenASN},
+		// Not asdot
+		{"set routing-options router-id 1.2.3.4", "1.2.3.4", TokenIPv4},
+		{"set x y 70000.1", "70000.1", TokenIdentifier},
+		{"set x y 0.900", "0.900", TokenIdentifier},
+		{"set x y 3.000", "3.000", TokenIdentifier},
+		// Plain decimals outside an AS number context
+		{"set x y 1.5", "1.5", TokenIdentifier},
+		{"set x y 12.5", "12.5", TokenIdentifier},
+		{"set x y 45.5", "45.5", TokenIdentifier},
+		{"version 21.4R3.5;", "21.4R3.5", TokenValue},
+	}
+
+	for _, tt := range tests {
+		l := New(tt.input)
+		l.SetParseMode(ParseModeConfig)
+		found := false
+		for _, tok := range l.Tokenize() {
+			if tok.Value == tt.word {
+				found = true
+				if tok.Type != tt.want {
+					t.Errorf("%q: expected %q to be %v, got %v", tt.input, tok.Value, tt.want, tok.Type)
+				}
+			}
+		}
+		if !found {
+			t.Errorf("%q: did not find %q", tt.input, tt.word)
+		}
+	}
+
+	// Nor are they ASNs in show output
+	l := New("Ratio 1.5 of 12.5, release 21.4 and 45.5\n")
+	l.SetParseMode(ParseModeShow)
+	for _, tok := range l.Tokenize() {
+		if tok.Type == TokenASN {
+			t.Errorf("show mode: expected %q not to be an ASN", tok.Value)
+		}
+	}
+}
+
+const requestRebootFixture = `Reboot the system ? [yes,no] (no) yes
+
+Shutdown NOW!
+[pid 12345]
+
+*** FINAL System shutdown message from admin@r1 ***
+
+System going down IMMEDIATELY
+`
+
+func TestRequestConfirmation(t *testing.T) {
+	l := New(requestRebootFixture)
+	l.SetParseMode(ParseModeShow)
+	tokens := l.Tokenize()
+
+	expected := map[string]TokenType{
+		"[yes,no]":    TokenKeyword,
+		"(no)":        TokenKeyword,
+		"yes":         TokenValue,
+		"Shutdown":    TokenStateWarning,
+		"NOW!":        TokenStateWarning,
+		"FINAL":       TokenStateWarning,
+		"IMMEDIATELY": TokenStateWarning,
+		"Reboot":      TokenIdentifier,
+	}
+
+	seen := map[string]bool{}
+	for _, tok := range tokens {
+		if exp, ok := expected[tok.Value]; ok {
+			seen[tok.Value] = true
+			if tok.Type != exp {
+				t.Errorf("expected %q to be %v, got %v", tok.Value, exp, tok.Type)
+			}
+		}
+	}
+	for value := range expected {
+		if !seen[value] {
+			t.Errorf("did not find %q", value)
+		}
+	}
+}
+
+func TestSecretData(t *testing.T) {
+	tests := []struct {
+		input  string
+		secret string
+	}{
+		{`set system root-authentication encrypted-password "$6$abc$def" ## SECRET-DATA`, `"$6$abc$def"`},
+		{"root-authentication {\n    encrypted-password \"$6$abc\"; ## SECRET-DATA\n}", `"$6$abc"`},
+		{"set snmp community $9$xyz.abc ## SECRET-DATA", "$9$xyz.abc"},
+		{"secret \"$9$abcdefg\"; ## SECRET-DATA", `"$9$abcdefg"`},
+		{"authentication-key $9$ab.cd/ef; ## SECRET-DATA ##", "$9$ab.cd/ef"},
+	}
+
+	for _, tt := range tests {
+		l := New(tt.input)
+		l.SetParseMode(ParseModeConfig)
+		foundSecret, foundMarker := false, false
+		for _, tok := range l.Tokenize() {
+			if tok.Value == tt.secret {
+				foundSecret = true
+				if tok.Type != TokenValue {
+					t.Errorf("%q: expected the secret to be a value, got %v", tt.input, tok.Type)
+				}
+			}
+			if strings.HasPrefix(tok.Value, "## SECRET-DATA") {
+				foundMarker = true
+				if tok.Type != TokenStateNeutral {
+					t.Errorf("%q: expected the marker to be dimmed, got %v", tt.input, tok.Type)
+				}
+			}
+		}
+		if !foundSecret || !foundMarker {
+			t.Errorf("%q: did not find the secret and its marker", tt.input)
+		}
+	}
+
+	// Other annotations and words are unaffected
+	l := New("set system host-name r1 ## primary\nset snmp community public")
+	l.SetParseMode(ParseModeConfig)
+	for _, tok := range l.Tokenize() {
+		if tok.Value == "## primary" && tok.Type != TokenAnnotation {
+			t.Errorf("expected a plain annotation, got %v", tok.Type)
+		}
+		if tok.Value == "public" && tok.Type == TokenStateNeutral {
+			t.Error("expected no secret outside SECRET-DATA lines")
+		}
+	}
+}
+
+const interfaceQueueFixture = `Queue: 0, Forwarding classes: best-effort
+  Queued:
+    Packets              :               1234567                   100 pps
+  Transmitted:
+    Packets              :               1234500                   100 pps
+    Bytes                :             123450000                 80000 bps
+    Tail-dropped packets :                     0                     0 pps
+    RED-dropped packets  :                    67                     2 pps
+    RED-dropped bytes    :                  6700                   200 bps
+Queue: 3, Forwarding classes: network-control
+`
+
+func TestInterfaceQueueCounters(t *testing.T) {
+	l := New(interfaceQueueFixture)
+	l.SetParseMode(ParseModeShow)
+	tokens := l.Tokenize()
+
+	expected := map[string]TokenType{
+		"3":            TokenNumber,
+		"1234500":      TokenNumber,
+		"123450000":    TokenNumber,
+		"100":          TokenNumber,
+		"Tail-dropped": TokenIdentifier,
+		"RED-dropped":  TokenIdentifier,
+		"0":            TokenNumber,
+		"67":           TokenStateBad,
+		"2":            TokenStateBad,
+		"6700":         TokenStateBad,
+		"200":          TokenStateBad,
+	}
+
+	seen := map[string]bool{}
+	for _, tok := range tokens {
+		if exp, ok := expected[tok.Value]; ok {
+			seen[tok.Value] = true
+			if tok.Type != exp {
+				t.Errorf("expected %q to be %v, got %v", tok.Value, exp, tok.Type)
+			}
+		}
+	}
+	for value := range expected {
+		if !seen[value] {
+			t.Errorf("did not find %q", value)
+		}
+	}
+}
+
+func TestPipeFilters(t *testing.T) {
+	tests := []struct {
+		input string
+		want  map[string]TokenType
+	}{
+		{"show configuration | match bgp", map[string]TokenType{
+			"|": TokenOperator, "match": TokenKeyword, "bgp": TokenValue,
+		}},
+		{"show interfaces terse | except down", map[string]TokenType{
+			"|": TokenOperator, "except": TokenKeyword, "down": TokenValue,
+		}},
+		{"show route | count", map[string]TokenType{
+			"|": TokenOperator, "count": TokenKeyword,
+		}},
+		{`show configuration | display set | match "ge-0/0/0"`, map[string]TokenType{
+			"|": TokenOperator, "display": TokenKeyword, "set": TokenKeyword,
+			"match": TokenKeyword, `"ge-0/0/0"`: TokenValue,
+		}},
+		{"show log messages | last 20 | no-more", map[string]TokenType{
+			"|": TokenOperator, "last": TokenKeyword, "20": TokenNumber, "no-more": TokenKeyword,
+		}},
+		{"show bgp summary | find Peer", map[string]TokenType{
+			"|": TokenOperator, "find": TokenKeyword, "Peer": TokenValue,
+		}},
+		{"admin@r1> show configuration | match bgp", map[string]TokenType{
+			"|": TokenOperator, "match": TokenKeyword, "bgp": TokenValue,
+		}},
+	}
+
+	for _, mode := range []ParseMode{ParseModeConfig, ParseModeShow} {
+		for _, tt := range tests {
+			l := New(tt.input)
+			l.SetParseMode(mode)
+			seen := make(map[string]bool)
+			for _, tok := range l.Tokenize() {
+				want, ok := tt.want[tok.Value]
+				if !ok {
+					continue
+				}
+				seen[tok.Value] = true
+				if tok.Type != want {
+					t.Errorf("mode %d %q: expected %q to be %v, got %v", mode, tt.input, tok.Value, want, tok.Type)
+				}
+			}
+			for word := range tt.want {
+				if !seen[word] {
+					t.Errorf("mode %d %q: did not find %q", mode, tt.input, word)
+				}
+			}
+		}
+	}
+}
+
+func TestObjectNameReferences(t *testing.T) {
+	tests := []struct {
+		input string
+		word  string
+	}{
+		// Definitions in config
+		{"set firewall family inet filter f term t then count web-hits", "web-hits"},
+		{"set firewall family inet filter f term t then policer p-10m", "p-10m"},
+		{"set firewall family inet filter f term t then forwarding-class expedited", "expedited"},
+		{"firewall {\n    filter f {\n        term t {\n            then {\n                count web-hits;\n            }\n        }\n    }\n}", "web-hits"},
+		// References in commands and show output
+		{"show firewall filter protect-re counter web-hits", "web-hits"},
+		{"show policer p-10m", "p-10m"},
+		{"admin@r1> show firewall filter protect-re counter web-hits", "web-hits"},
+	}
+
+	for _, mode := range []ParseMode{ParseModeConfig, ParseModeShow} {
+		for _, tt := range tests {
+			l := New(tt.input)
+			l.SetParseMode(mode)
+			found := false
+			for _, tok := range l.Tokenize() {
+				if tok.Value == tt.word {
+					found = true
+					if tok.Type != TokenValue {
+						t.Errorf("mode %d %q: expected %q to be Value, got %v", mode, tt.input, tok.Value, tok.Type)
+					}
+				}
+			}
+			if !found {
+				t.Errorf("mode %d %q: did not find %q", mode, tt.input, tt.word)
+			}
+		}
+	}
+
+	// The keyword must be on the same line as the name
+	l := New("show route | count\nCount: 10 lines\n")
+	l.SetParseMode(ParseModeShow)
+	for _, tok := range l.Tokenize() {
+		if tok.Value == "Count:" && tok.Type == TokenValue {
+			t.Error("expected a word on the next line not to be a name")
+		}
+	}
+}
+
+const junosDefaultsFixture = `groups {
+    junos-defaults {
+        applications {
+            application junos-ftp {
+                application-protocol ftp;
+            }
+        }
+    }
+    re0 {
+        system {
+            host-name r1-re0;
+        }
+    }
+}
+system {
+    host-name r1;
+}
+`
+
+func TestJunosDefaultsDimmed(t *testing.T) {
+	l := New(junosDefaultsFixture)
+	l.SetParseMode(ParseModeConfig)
+	tokens := l.Tokenize()
+
+	// Everything between "junos-defaults" and the end of its block is dimmed
+	start, end := -1, -1
+	for i, tok := range tokens {
+		if tok.Value == "junos-defaults" {
+			start = i + 1
+		}
+		if tok.Value == "re0" {
+			end = i
+		}
+	}
+	if start < 0 || end < 0 {
+		t.Fatal("fixture words not found")
+	}
+	for _, tok := range tokens[start:end] {
+		if tok.Type != TokenText && tok.Type != TokenStateNeutral {
+			t.Errorf("expected %q inside junos-defaults to be StateNeutral, got %v", tok.Value, tok.Type)
+		}
+	}
+
+	// Config outside the block, including other groups, keeps its colors
+	want := map[string]TokenType{
+		"re0": TokenIdentifier, "host-name": TokenKeyword, "r1-re0": TokenValue, "r1": TokenValue,
+	}
+	for _, tok := range tokens[end:] {
+		if tok.Type == TokenStateNeutral {
+			t.Errorf("expected %q after junos-defaults not to be dimmed", tok.Value)
+		}
+		if w, ok := want[tok.Value]; ok && tok.Type != w {
+			t.Errorf("expected %q to be %v, got %v", tok.Value, w, tok.Type)
+		}
+	}
+}
+
+// pingFixture is sample "ping" output, including a lost reply and rapid ping
+const pingFixture = `PING 10.0.0.1 (10.0.0.1): 56 data bytes
+64 bytes from 10.0.0.1: icmp_seq=0 ttl=64 time=1.234 ms
+64 bytes from 10.0.0.1: icmp_seq=1 ttl=63 time=0.987 ms
+Request timeout for icmp_seq 2
+
+--- 10.0.0.1 ping statistics ---
+3 packets transmitted, 2 packets received, 33% packet loss
+round-trip min/avg/max/stddev = 0.987/1.110/1.234/0.123 ms
+!!!!!
+!!.!!
+`
+
+// tracerouteFixture is sample "traceroute" output with timeouts and an
+// unreachable annotation
+const tracerouteFixture = `traceroute to 8.8.8.8 (8.8.8.8), 30 hops max, 40 byte packets
+ 1  10.0.0.1 (10.0.0.1)  0.512 ms  0.433 ms  0.401 ms
+ 2  * * *
+ 3  10.0.0.5 (10.0.0.5)  1.742 ms *  1.611 ms
+ 4  192.0.2.1 (192.0.2.1)  5.123 ms !H
+`
+
+func TestPingOutput(t *testing.T) {
+	l := New(pingFixture)
+	l.SetParseMode(ParseModeShow)
+
+	expected := map[string]TokenType{
+		"icmp_seq=":               TokenIdentifier,
+		"0":                       TokenNumber,
+		"63":                      TokenNumber,
+		"1.234":                   TokenTimeDuration,
+		"0.987":                   TokenTimeDuration,
+		"ms":                      TokenTimeDuration,
+		"timeout":                 TokenStateBad,
+		"0.987/1.110/1.234/0.123": TokenTimeDuration,
+		"!!!!!":                   TokenStateGood,
+		"!!.!!":                   TokenStateWarning,
+	}
+
+	seen := map[string]bool{}
+	for _, tok := range l.Tokenize() {
+		if exp, ok := expected[tok.Value]; ok {
+			seen[tok.Value] = true
+			if tok.Type != exp {
+				t.Errorf("expected %q to be %v, got %v", tok.Value, exp, tok.Type)
+			}
+		}
+	}
+	for value := range expected {
+		if !seen[value] {
+			t.Errorf("did not find %q", value)
+		}
+	}
+}
+
+func TestTracerouteOutput(t *testing.T) {
+	l := New(tracerouteFixture)
+	l.SetParseMode(ParseModeShow)
+
+	expected := map[string]TokenType{
+		"0.512": TokenTimeDuration,
+		"1.742": TokenTimeDuration,
+		"5.123": TokenTimeDuration,
+		"ms":    TokenTimeDuration,
+		"!H":    TokenStateBad,
+	}
+	timeouts := map[int]TokenType{3: TokenStateBad, 4: TokenStateWarning}
+
+	for _, tok := range l.Tokenize() {
+		if tok.Value == "*" {
+			if want := timeouts[tok.Line]; tok.Type != want {
+				t.Errorf("line %d: expected \"*\" to be %v, got %v", tok.Line, want, tok.Type)
+			}
+			continue
+		}
+		if exp, ok := expected[tok.Value]; ok && tok.Type != exp {
+			t.Errorf("expected %q to be %v, got %v", tok.Value, exp, tok.Type)
+		}
+	}
+}
+
+// taskReplicationFixture is sample "show task replication" and "show system
+// switchover" output
+const taskReplicationFixture = `        Stateful Replication: Enabled
+        RE mode: Master
+
+    Protocol                Synchronization Status
+    BGP                     Complete
+    OSPF                    Not Synchronized
+    IS-IS                   Synchronized
+Graceful switchover: Disabled
+Nonstop routing: Enabled
+Interface ge-0/0/1 Disabled
+`
+
+func TestTaskReplication(t *testing.T) {
+	l := New(taskReplicationFixture)
+	l.SetParseMode(ParseModeShow)
+
+	// Keyed by line and value, since the fixture repeats words
+	expected := map[[2]string]TokenType{
+		{"1", "Enabled"}:      TokenStateGood,
+		{"5", "Complete"}:     TokenStateGood,
+		{"6", "Not"}:          TokenStateBad,
+		{"6", "Synchronized"}: TokenStateBad,
+		{"7", "Synchronized"}: TokenStateGood,
+		{"8", "Disabled"}:     TokenStateNeutral,
+		{"9", "Enabled"}:      TokenStateGood,
+		// Disabled outside a high-availability feature is still bad
+		{"10", "Disabled"}: TokenStateBad,
+	}
+
+	seen := map[[2]string]bool{}
+	for _, tok := range l.Tokenize() {
+		key := [2]string{strconv.Itoa(tok.Line), tok.Value}
+		if exp, ok := expected[key]; ok {
+			seen[key] = true
+			if tok.Type != exp {
+				t.Errorf("line %s: expected %q to be %v, got %v", key[0], tok.Value, exp, tok.Type)
+			}
+		}
+	}
+	for key := range expected {
+		if !seen[key] {
+			t.Errorf("did not find %q on line %s", key[1], key[0])
+		}
+	}
+}
+
+func TestLongReplicationLineIsLinear(t *testing.T) {
+	// Every word is "Disabled"; the feature label lookup must not rescan the
+	// line for each one
+	assertLinear(t, ParseModeShow, func(n int) string {
+		return "Graceful switchover: " + strings.Repeat("Disabled ", n) + "\n"
+	})
+}
+
+func TestLineDepths(t *testing.T) {
+	input := `system {
+    host-name r1;
+    services {
+        ssh;
+        /* no telnet { here } */
+    }
+
+}
+interfaces {
+    ge-0/0/0 { description "uplink {core}"; }
+    ae0 {
+        unit 0; }
+}
+`
+	want := []int{0, 1, 1, 2, 2, 1, 1, 0, 0, 1, 1, 2, 0}
+	if got := LineDepths(input); !reflect.DeepEqual(got, want) {
+		t.Errorf("LineDepths() = %v, want %v", got, want)
+	}
+
+	// junos-defaults braces are dimmed but still nest
+	if got, want := LineDepths("groups {\n    junos-defaults {\n        x;\n    }\n}"), []int{0, 1, 2, 1, 0}; !reflect.DeepEqual(got, want) {
+		t.Errorf("LineDepths(junos-defaults) = %v, want %v", got, want)
+	}
+
+	if got := LineDepths(""); !reflect.DeepEqual(got, []int{0}) {
+		t.Errorf("LineDepths(\"\") = %v, want [0]", got)
+	}
+}
+
+// commitHistoryFixture is sample "show system commit" output
+const commitHistoryFixture = `0   2024-01-15 10:30:00 UTC by admin via cli
+1   2024-01-14 09:12:45 UTC by netops via netconf commit confirmed, rollback in 10mins
+2   2024-01-10 17:01:02 UTC by root via other
+    upgrade bgp policy
+`
+
+func TestCommitHistory(t *testing.T) {
+	l := New(commitHistoryFixture)
+	l.SetParseMode(ParseModeShow)
+
+	expected := map[string]TokenType{
+		"0":          TokenNumber,
+		"2":          TokenNumber,
+		"2024-01-15": TokenTimestamp,
+		"10:30:00":   TokenTimestamp,
+		"admin":      TokenPromptUser,
+		"netops":     TokenPromptUser,
+		"root":       TokenPromptUser,
+		"cli":        TokenKeyword,
+		"netconf":    TokenKeyword,
+		"other":      TokenKeyword,
+		"by":         TokenIdentifier,
+		// The commit comment is plain text
+		"upgrade": TokenIdentifier,
+	}
+
+	seen := map[string]bool{}
+	for _, tok := range l.Tokenize() {
+		if exp, ok := expected[tok.Value]; ok {
+			seen[tok.Value] = true
+			if tok.Type != exp {
+				t.Errorf("expected %q to be %v, got %v", tok.Value, exp, tok.Type)
+			}
+		}
+	}
+	for value := range expected {
+		if !seen[value] {
+			t.Errorf("did not find %q", value)
+		}
+	}
+}
+
+func TestLongCommitHistoryLineIsLinear(t *testing.T) {
+	// Every other word follows "by"; the commit entry lookup must not rescan
+	// the line for each one
+	assertLinear(t, ParseModeShow, func(n int) string {
+		return "0   2024-01-15 10:30:00 UTC by admin via cli " + strings.Repeat("by x ", n) + "\n"
+	})
+}
+
+const certificateFixture = `set security pki ca-profile root-ca ca-identity root-ca
+-----BEGIN CERTIFICATE-----
+MIIDdzCCAl+gAwIBAgIEAgAAuTANBgkqhkiG9w0BAQUFADBaMQswCQYDVQQGEwJJ
+RTESMBAGA1UEChMJQmFsdGltb3JlMRMwEQYDVQQLEwpDeWJlclRydXN0MSIwIAYD
+VQQDExlCYWx0aW1vcmUgQ3liZXJUcnVzdCBSb290MB4XDTAwMDUxMjE4NDYwMFoX
+Q3liZXJUcnVzdDEiMCAGA1UEAxMZ+8Q==
+-----END CERTIFICATE-----
+set interfaces ge-0/0/0 unit 0 family inet address 10.0.0.1/24
+`
+
+func TestBase64Blobs(t *testing.T) {
+	blobs := map[string]bool{
+		"MIIDdzCCAl+gAwIBAgIEAgAAuTANBgkqhkiG9w0BAQUFADBaMQswCQYDVQQGEwJJ": true,
+		"RTESMBAGA1UEChMJQmFsdGltb3JlMRMwEQYDVQQLEwpDeWJlclRydXN0MSIwIAYD": true,
+		"VQQDExlCYWx0aW1vcmUgQ3liZXJUcnVzdCBSb290MB4XDTAwMDUxMjE4NDYwMFoX": true,
+		"Q3liZXJUcnVzdDEiMCAGA1UEAxMZ+8Q==":                                true,
+	}
+
+	for _, mode := range []ParseMode{ParseModeConfig, ParseModeShow} {
+		l := New(certificateFixture)
+		l.SetParseMode(mode)
+		found := 0
+		for _, tok := range l.Tokenize() {
+			switch {
+			case blobs[tok.Value]:
+				found++
+				if tok.Type != TokenValue {
+					t.Errorf("mode %d: expected %q to be Value, got %v", mode, tok.Value, tok.Type)
+				}
+			case tok.Value == "ge-0/0/0" && tok.Type != TokenInterface:
+				t.Errorf("mode %d: expected interface after the block, got %v", mode, tok.Type)
+			case tok.Value == "10.0.0.1/24" && tok.Type != TokenIPv4Prefix:
+				t.Errorf("mode %d: expected prefix after the block, got %v", mode, tok.Type)
+			}
+		}
+		if found != len(blobs) {
+			t.Errorf("mode %d: found %d of %d blob lines", mode, found, len(blobs))
+		}
+	}
+
+	// A key outside a PEM block is one opaque value, even when it happens to
+	// contain something that looks like an interface or an address
+	l := New(`set system login user admin authentication ssh-rsa "AAAAB3NzaC1yc2EAAAADAQABAAABgQge0xe10ab0Zz9+/Kq8w=="`)
+	for _, tok := range l.Tokenize() {
+		if tok.Type == TokenInterface || tok.Type == TokenIPv4 {
+			t.Errorf("expected no %v inside a key, got %q", tok.Type, tok.Value)
+		}
+	}
+
+	// Short words and ordinary identifiers are unaffected
+	for _, word := range []string{"ABCDEFGHIJ", "abcdefghijklmnopqrstuvwxyzabcdefghijklmnop", "Q3liZXJUcnVzdDEiMCAGA1UEAxMZ"} {
+		if isBase64Blob(word) {
+			t.Errorf("expected %q not to be a blob", word)
+		}
+	}
+}
+
+// systemStorageFixture is sample "show system storage" output, with a
+// filesystem filling up, a full one and always-full pseudo filesystems
+const systemStorageFixture = `Filesystem              Size       Used      Avail  Capacity   Mounted on
+/dev/gpt/junos          1.3G       773M       441M       64%  /.mount
+tmpfs                   2.5G        38K       2.5G        0%  /.mount/tmp
+/dev/md6                 12M        11M        1M       92%  /.mount/mfs
+/dev/ada0s1a            7.0G       6.7G       310M       96%  /.mount/var
+procfs                  4.0K       4.0K         0B      100%  /proc
+/dev/md1.uzip            45M        45M         0B      100%  /packages/mnt/jbase
+`
+
+func TestSystemStorage(t *testing.T) {
+	l := New(systemStorageFixture)
+	l.SetParseMode(ParseModeShow)
+
+	expected := map[string]TokenType{
+		"Filesystem":          TokenColumnHeader,
+		"Capacity":            TokenColumnHeader,
+		"Mounted":             TokenColumnHeader,
+		"on":                  TokenColumnHeader,
+		"/dev/gpt/junos":      TokenPath,
+		"1.3G":                TokenByteSize,
+		"773M":                TokenByteSize,
+		"0B":                  TokenByteSize,
+		"64%":                 TokenPercentage,
+		"0%":                  TokenPercentage,
+		"92%":                 TokenPercentage,
+		"96%":                 TokenPercentage,
+		"100%":                TokenNumber,
+		"/.mount/var":         TokenPath,
+		"/packages/mnt/jbase": TokenPath,
+	}
+
+	seen := map[string]bool{}
+	for _, tok := range l.Tokenize() {
+		if exp, ok := expected[tok.Value]; ok {
+			seen[tok.Value] = true
+			if tok.Type != exp {
+				t.Errorf("line %d: expected %q to be %v, got %v", tok.Line, tok.Value, exp, tok.Type)
+			}
+		}
+	}
+	for value := range expected {
+		if !seen[value] {
+			t.Errorf("did not find %q", value)
+		}
+	}
+}
+
+func TestLongStorageHeaderLineIsLinear(t *testing.T) {
+	// Every word is a storage header; the header line lookup must not rescan
+	// the line for each one
+	assertLinear(t, ParseModeShow, func(n int) string {
+		return "Filesystem Mounted on " + strings.Repeat("on ", n) + "\n"
+	})
+}
+
+// dhcpBindingFixture is sample "show dhcp server binding" output
+const dhcpBindingFixture = `IP address        Session Id  Hardware address   Expires     State      Interface
+192.168.10.11     4           00:10:94:00:00:01  86341       BOUND      ge-0/0/1.0
+192.168.10.12     5           00:10:94:00:00:02  0           RELEASE    ge-0/0/1.0
+192.168.10.13     6           00:10:94:00:00:03  60          INIT       ge-0/0/1.0
+192.168.10.14     7           00:10:94:00:00:04  3599        RENEWING   ge-0/0/1.0
+`
+
+func TestDHCPBindings(t *testing.T) {
+	l := New(dhcpBindingFixture)
+	l.SetParseMode(ParseModeShow)
+
+	expected := map[string]TokenType{
+		"IP":                TokenColumnHeader,
+		"Hardware":          TokenColumnHeader,
+		"Expires":           TokenColumnHeader,
+		"192.168.10.11":     TokenIPv4,
+		"00:10:94:00:00:01": TokenMAC,
+		"4":                 TokenNumber,
+		"86341":             TokenTimeDuration,
+		"0":                 TokenTimeDuration,
+		"3599":              TokenTimeDuration,
+		"BOUND":             TokenStateGood,
+		"RELEASE":           TokenStateNeutral,
+		"INIT":              TokenStateWarning,
+		"RENEWING":          TokenStateWarning,
+		"ge-0/0/1.0":        TokenInterface,
+	}
+
+	seen := map[string]bool{}
+	for _, tok := range l.Tokenize() {
+		if exp, ok := expected[tok.Value]; ok {
+			seen[tok.Value] = true
+			if tok.Type != exp {
+				t.Errorf("line %d: expected %q to be %v, got %v", tok.Line, tok.Value, exp, tok.Type)
+			}
+		}
+	}
+	for value := range expected {
+		if !seen[value] {
+			t.Errorf("did not find %q", value)
+		}
+	}
+
+	// The states are upper case in the binding table only
+	l = New("the lease is bound to the client")
+	l.SetParseMode(ParseModeShow)
+	for _, tok := range l.Tokenize() {
+		if tok.Value == "bound" && tok.Type == TokenStateGood {
+			t.Error("expected \"bound\" in prose not to be a DHCP state")
+		}
+	}
+}
+
+func TestLongDHCPBindingHeaderLineIsLinear(t *testing.T) {
+	// Every word is a binding header; the header line lookup must not
+	// rescan the line for each one
+	assertLinear(t, ParseModeShow, func(n int) string {
+		return "Hardware address Expires " + strings.Repeat("Hardware ", n) + "\n"
+	})
+}
+
+func TestUpperCaseKeywords(t *testing.T) {
+	inputs := []string{
+		"set interfaces ge-0/0/0 unit 0 family inet address 10.0.0.1/24",
+		"set protocols ospf area 0.0.0.0 interface xe-0/0/1.0",
+		"set firewall filter f term t then accept",
+		"delete interfaces ae0 disable",
+		"interfaces {\n    lo0 {\n        unit 0;\n    }\n}",
+	}
+
+	for _, input := range inputs {
+		want := New(input).Tokenize()
+		got := New(strings.ToUpper(input)).Tokenize()
+		if len(got) != len(want) {
+			t.Fatalf("%q: expected %d tokens, got %d", input, len(want), len(got))
+		}
+		for i := range want {
+			if got[i].Type != want[i].Type {
+				t.Errorf("%q: expected %q to be %v like %q, got %v", input, got[i].Value, want[i].Type, want[i].Value, got[i].Type)
+			}
+			if got[i].Value != strings.ToUpper(want[i].Value) {
+				t.Errorf("%q: expected the original case %q, got %q", input, strings.ToUpper(want[i].Value), got[i].Value)
+			}
+		}
+	}
+
+	// Case sensitive keywords, as the JunOS CLI takes them
+	l := New("SET INTERFACES GE-0/0/0 description Uplink\nset interfaces ge-0/0/1 disable")
+	l.SetParseMode(ParseModeConfig)
+	l.SetCaseSensitive(true)
+	expected := map[string]TokenType{
+		"SET":        TokenIdentifier,
+		"INTERFACES": TokenIdentifier,
+		"GE-0/0/0":   TokenInterface,
+		"set":        TokenCommand,
+		"interfaces": TokenSection,
+		"disable":    TokenKeyword,
+	}
+	for _, tok := range l.Tokenize() {
+		if exp, ok := expected[tok.Value]; ok && tok.Type != exp {
+			t.Errorf("case sensitive: expected %q to be %v, got %v", tok.Value, exp, tok.Type)
+		}
+	}
+
+	// The command after a prompt follows the same setting
+	for _, caseSensitive := range []bool{false, true} {
+		l := New("admin@r1# SET INTERFACES ge-0/0/0 disable")
+		l.SetCaseSensitive(caseSensitive)
+		want := TokenCommand
+		if caseSensitive {
+			want = TokenIdentifier
+		}
+		for _, tok := range l.Tokenize() {
+			if tok.Value == "SET" && tok.Type != want {
+				t.Errorf("prompt line, case sensitive %v: expected %q to be %v, got %v", caseSensitive, tok.Value, want, tok.Type)
+			}
+		}
+	}
+}
+
+// bgpExtensiveFixture is a sample "show route protocol bgp extensive" entry
+const bgpExtensiveFixture = `10.10.0.0/16 (2 entries, 1 announced)
+        *BGP    Preference: 170/-101
+                Next hop type: Router, Next hop index: 592
+                Source: 192.0.2.1
+                Next hop: 192.0.2.1 via ge-0/0/0.0, selected
+                Local AS: 65001 Peer AS: 65002
+                Age: 3d 4:05:06
+                Validation State: unverified
+                AS path: 65002 65010 {65020 65021} I
+                Communities: 65002:100 no-export large:65002:1:2 target:65002:7
+                Localpref: 100
+                MED: 50
+                Origin: IGP
+`
+
+func TestBGPExtensiveAttributes(t *testing.T) {
+	l := New(bgpExtensiveFixture)
+	l.SetParseMode(ParseModeShow)
+
+	expected := map[string]TokenType{
+		"BGP":             TokenRouteProtocol,
+		"Preference:":     TokenColumnHeader,
+		"type:":           TokenColumnHeader,
+		"Router":          TokenValue,
+		"Source:":         TokenColumnHeader,
+		"192.0.2.1":       TokenIPv4,
+		"Local":           TokenColumnHeader,
+		"AS:":             TokenColumnHeader,
+		"65001":           TokenASN,
+		"65002":           TokenASN,
+		"65010":           TokenASN,
+		"65020":           TokenASN,
+		"65021":           TokenASN,
+		"unverified":      TokenStateNeutral,
+		"path:":           TokenColumnHeader,
+		"Communities:":    TokenColumnHeader,
+		"65002:100":       TokenCommunity,
+		"no-export":       TokenCommunity,
+		"large:65002:1:2": TokenCommunity,
+		"target:65002:7":  TokenCommunity,
+		"Localpref:":      TokenColumnHeader,
+		"100":             TokenNumber,
+		"MED:":            TokenColumnHeader,
+		"Origin:":         TokenColumnHeader,
+		"IGP":             TokenValue,
+		"3d":              TokenTimeDuration,
+		"index:":          TokenIdentifier,
+		"10.10.0.0/16":    TokenIPv4Prefix,
+	}
+
+	seen := map[string]bool{}
+	for _, tok := range l.Tokenize() {
+		if exp, ok := expected[tok.Value]; ok {
+			seen[tok.Value] = true
+			if tok.Type != exp {
+				t.Errorf("line %d: expected %q to be %v, got %v", tok.Line, tok.Value, exp, tok.Type)
+			}
+		}
+	}
+	for value := range expected {
+		if !seen[value] {
+			t.Errorf("did not find %q", value)
+		}
+	}
+}
+
+func TestTokenTypeValuesStable(t *testing.T) {
+	// Exported TokenType values must not shift when new types are added.
+	tests := []struct {
+		tokenType TokenType
+		want      int
+	}{
+		{TokenValue, 24},
+		{TokenStateGood, 25},
+		{TokenTableName, 35},
+		{TokenPromptUser, 36},
+		{TokenDiffContext, 45},
+		{TokenCommandDestructive, 46},
+		{TokenOID, 50},
+	}
+	for _, tt := range tests {
+		if int(tt.tokenType) != tt.want {
+			t.Errorf("%v = %d, want %d", tt.tokenType, int(tt.tokenType), tt.want)
+		}
+	}
+}
+
+func TestShowTokensGatedOnShowMode(t *testing.T) {
+	// Show output coloring never applies to config, even to show output
+	// parsed as config
+	tests := []struct {
+		name  string
+		input string
+		types []TokenType
+	}{
+		{"mac entry types", "set routing-options static route 0.0.0.0/0 next-hop 10.0.0.1", []TokenType{TokenStateNeutral}},
+		{"chassis fpc states", "set chassis fpc 0 pic 0 empty", []TokenType{TokenStateNeutral}},
+		{"isis levels", "set protocols isis interface ge-0/0/0.0 level 2 metric 10", []TokenType{TokenLevel, TokenTimeDuration}},
+		{"next hop types", "set routing-options static route 10.0.0.0/8 discard", []TokenType{TokenStateGood, TokenStateBad, TokenStateNeutral}},
+		{"uptime", uptimeFixture, []TokenType{TokenTimestamp, TokenStateWarning}},
+		{"commit confirmed", "commit confirmed 10\nrollback 1\n", []TokenType{TokenStateGood, TokenStateWarning, TokenTimeDuration}},
+		{"bfd sessions", bfdSessionFixture, []TokenType{TokenTimeDuration, TokenStateNeutral}},
+		{"pfe drop counters", pfeStatisticsFixture, []TokenType{TokenStateBad, TokenStateWarning}},
+		{"request confirmation", requestRebootFixture, []TokenType{TokenStateWarning}},
+		{"interface queue counters", interfaceQueueFixture, []TokenType{TokenStateBad}},
+		{"ping", pingFixture, []TokenType{TokenTimeDuration, TokenStateGood, TokenStateBad}},
+		{"traceroute", tracerouteFixture, []TokenType{TokenTimeDuration, TokenStateGood, TokenStateBad}},
+		{"task replication", taskReplicationFixture, []TokenType{TokenStateBad, TokenStateNeutral}},
+		{"commit history", commitHistoryFixture, []TokenType{TokenPromptUser}},
+		{"system storage", systemStorageFixture, []TokenType{TokenColumnHeader, TokenPercentage, TokenStateWarning, TokenStateBad}},
+		{"dhcp bindings", dhcpBindingFixture, []TokenType{TokenStateGood, TokenStateNeutral, TokenStateWarning, TokenTimeDuration, TokenColumnHeader}},
+		{"bgp extensive attributes", bgpExtensiveFixture, []TokenType{TokenColumnHeader, TokenRouteProtocol, TokenStateNeutral}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := New(tt.input)
+			l.SetParseMode(ParseModeConfig)
+			for _, tok := range l.Tokenize() {
+				for _, typ := range tt.types {
+					if tok.Type == typ {
+						t.Errorf("expected no %v in config mode, got %q", typ, tok.Value)
+					}
+				}
+			}
+		})
+	}
+}
@@ -58,6 +58,14 @@ const (
 	TokenDiffAdd     // + lines (added) - green
 	TokenDiffRemove  // - lines (removed) - red
 	TokenDiffContext // [edit ...] context headers - cyan/blue
+
+	// Types added after the initial release are appended here so existing
+	// TokenType values keep their numbering.
+	TokenCommandDestructive // delete, deactivate
+	TokenPath               // /var/tmp/config.txt, ftp://host/file
+	TokenLevel              // L1, L2, L1L2 (IS-IS levels)
+	TokenTimestamp          // 2024-01-15 10:30:00 UTC, 10:30AM
+	TokenOID                // 1.3.6.1.2.1.2.2.1.8, IF-MIB::ifOperStatus
 )
 
 // Token represents a single lexical token
@@ -163,6 +171,16 @@ func (t TokenType) String() string {
 		return "DiffRemove"
 	case TokenDiffContext:
 		return "DiffContext"
+	case TokenCommandDestructive:
+		return "CommandDestructive"
+	case TokenPath:
+		return "Path"
+	case TokenLevel:
+		return "Level"
+	case TokenTimestamp:
+		return "Timestamp"
+	case TokenOID:
+		return "OID"
 	default:
 		return "Unknown"
 	}
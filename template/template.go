@@ -0,0 +1,36 @@
+// Package template provides text/template functions for highlighting JunOS
+// snippets, so jink can be embedded in report generators.
+//
+//	tmpl := template.New("report").Funcs(jinktemplate.FuncMap())
+//	// {{ highlight .Config }}
+//	// {{ highlightTheme "nord" .Config }}
+package template
+
+import (
+	"strings"
+	"text/template"
+
+	"github.com/lasseh/jink/highlighter"
+)
+
+// FuncMap returns template functions for highlighting JunOS text:
+//
+//	highlight TEXT              highlights TEXT with the default theme
+//	highlightTheme NAME TEXT    highlights TEXT with the named theme
+//
+// Highlighting is forced: the caller decides what is JunOS, so detection
+// heuristics are skipped.
+func FuncMap() template.FuncMap {
+	hl := highlighter.New()
+	return template.FuncMap{
+		"highlight":      hl.HighlightForced,
+		"highlightTheme": highlightTheme,
+	}
+}
+
+// highlightTheme highlights text with the theme called name.
+// Unknown names fall back to the default theme.
+func highlightTheme(name, text string) string {
+	theme := highlighter.ThemeByName(strings.ToLower(name))
+	return highlighter.NewWithTheme(theme).HighlightForced(text)
+}
@@ -0,0 +1,51 @@
+package template
+
+import (
+	"strings"
+	"testing"
+	"text/template"
+
+	"github.com/lasseh/jink/highlighter"
+)
+
+func render(t *testing.T, text string, data any) string {
+	t.Helper()
+	tmpl, err := template.New("test").Funcs(FuncMap()).Parse(text)
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	var sb strings.Builder
+	if err := tmpl.Execute(&sb, data); err != nil {
+		t.Fatalf("execute: %v", err)
+	}
+	return sb.String()
+}
+
+func TestHighlightFunc(t *testing.T) {
+	out := render(t, "Config:\n{{ highlight .Config }}\n", map[string]string{
+		"Config": "set interfaces ge-0/0/0 unit 0",
+	})
+
+	if !strings.HasPrefix(out, "Config:\n") {
+		t.Errorf("expected template text to be preserved, got %q", out)
+	}
+	if !highlighter.HasANSI(out) {
+		t.Errorf("expected ANSI output, got %q", out)
+	}
+	if got := highlighter.StripANSI(out); got != "Config:\nset interfaces ge-0/0/0 unit 0\n" {
+		t.Errorf("expected text to be preserved, got %q", got)
+	}
+}
+
+func TestHighlightThemeFunc(t *testing.T) {
+	input := "set interfaces ge-0/0/0 unit 0"
+	out := render(t, `{{ highlightTheme "nord" . }}`, input)
+
+	want := highlighter.NewWithTheme(highlighter.NordTheme()).HighlightForced(input)
+	if out != want {
+		t.Errorf("expected nord theme output %q, got %q", want, out)
+	}
+	if def := render(t, `{{ highlight . }}`, input); def == out {
+		t.Error("expected nord output to differ from the default theme")
+	}
+}
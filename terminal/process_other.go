@@ -0,0 +1,28 @@
+//go:build !windows
+
+package terminal
+
+import (
+	"os"
+	"os/exec"
+	"os/signal"
+	"syscall"
+)
+
+// notifyResize relays terminal window size changes (SIGWINCH) to ch
+func notifyResize(ch chan<- os.Signal) {
+	signal.Notify(ch, syscall.SIGWINCH)
+}
+
+// newSessionAttr returns process attributes that start a command in a new
+// session, without a controlling terminal and as its own process group
+func newSessionAttr() *syscall.SysProcAttr {
+	return &syscall.SysProcAttr{Setsid: true}
+}
+
+// killSession kills the process group of a command started with
+// newSessionAttr. The command leads its own session, so its process group id
+// is its pid.
+func killSession(cmd *exec.Cmd) error {
+	return syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+}
@@ -0,0 +1,25 @@
+//go:build windows
+
+package terminal
+
+import (
+	"os"
+	"os/exec"
+	"syscall"
+)
+
+// notifyResize does nothing: Windows consoles have no signal for window size
+// changes, so the PTY keeps its initial size
+func notifyResize(ch chan<- os.Signal) {}
+
+// newSessionAttr returns no process attributes; Windows has no sessions or
+// controlling terminals to detach from
+func newSessionAttr() *syscall.SysProcAttr {
+	return nil
+}
+
+// killSession kills the command itself, as Windows has no process groups to
+// signal
+func killSession(cmd *exec.Cmd) error {
+	return cmd.Process.Kill()
+}
@@ -6,11 +6,14 @@ import (
 	"os"
 	"os/exec"
 	"os/signal"
+	"path/filepath"
+	"regexp"
+	"strings"
 	"sync"
-	"syscall"
 
 	"github.com/creack/pty"
 	"github.com/lasseh/jink/highlighter"
+	"github.com/lasseh/jink/lexer"
 	"golang.org/x/term"
 )
 
@@ -21,6 +24,16 @@ const (
 	lineFlushLimit = 4000      // Flush line buffer when it exceeds this size
 )
 
+// defaultRows and defaultCols size the PTY when the size of the terminal
+// jink runs in can't be determined
+const (
+	defaultRows = 24
+	defaultCols = 80
+)
+
+// titleEscape sets the terminal window title (OSC 2)
+const titleEscape = "\033]2;%s\007"
+
 var (
 	debug   bool
 	debugMu sync.RWMutex
@@ -45,16 +58,27 @@ type Terminal struct {
 	cmd         *exec.Cmd
 	pty         *os.File
 	highlighter *highlighter.Highlighter
+	stream      *highlighter.Stream // the command's output, highlighted as one input
 	enabled     bool
+	stdin       io.Reader
+	stdout      io.Writer
+
+	// Terminal title updates (see SetTitleUpdates)
+	titleUpdates bool
+	title        string // last title set, to skip repeats
 }
 
 // New creates a new Terminal for the given command
 func New(name string, args ...string) *Terminal {
 	cmd := exec.Command(name, args...)
+	hl := highlighter.New()
 	return &Terminal{
 		cmd:         cmd,
-		highlighter: highlighter.New(),
+		highlighter: hl,
+		stream:      hl.NewStream(),
 		enabled:     true,
+		stdin:       os.Stdin,
+		stdout:      os.Stdout,
 	}
 }
 
@@ -63,15 +87,49 @@ func (t *Terminal) SetTheme(theme *highlighter.Theme) {
 	t.highlighter.SetTheme(theme)
 }
 
+// SetHighlightPattern overlays color on output text matching re (see
+// highlighter.Highlighter.SetHighlightPattern)
+func (t *Terminal) SetHighlightPattern(re *regexp.Regexp, color string) {
+	t.highlighter.SetHighlightPattern(re, color)
+}
+
+// SetInput sets where keyboard input for the command is read from (default
+// os.Stdin). Raw mode and window resizing only apply when r is a terminal.
+func (t *Terminal) SetInput(r io.Reader) {
+	t.stdin = r
+}
+
+// SetOutput sets where highlighted output is written (default os.Stdout)
+func (t *Terminal) SetOutput(w io.Writer) {
+	t.stdout = w
+}
+
 // SetEnabled enables or disables highlighting
 func (t *Terminal) SetEnabled(enabled bool) {
 	t.enabled = enabled
 }
 
+// SetTitleUpdates enables setting the terminal title to "user@host" (OSC 2)
+// whenever the command's output shows a JunOS prompt, e.g. while logged in
+// to a router over SSH. Off by default.
+func (t *Terminal) SetTitleUpdates(enabled bool) {
+	t.titleUpdates = enabled
+}
+
 // Run starts the command and processes its output with highlighting.
 func (t *Terminal) Run() error {
-	// Start the command with a PTY
-	ptmx, err := pty.Start(t.cmd)
+	// Start the command with a PTY, sized before the command starts so it
+	// never sees an unsized one. pty.StartWithSize only wires the command to
+	// the PTY once one is open, so an unset Stdout means there is no PTY to
+	// be had and the command can still run with plain pipes.
+	tty, isTerminal := t.inputTerminal()
+	ptmx, err := pty.StartWithSize(t.cmd, ptySize(tty))
+	if err != nil && t.cmd.Stdout == nil {
+		if IsDebug() {
+			fmt.Fprintf(os.Stderr, "[DEBUG] No pty, running with pipes: %v\n", err)
+		}
+		return t.runPiped()
+	}
 	if err != nil {
 		return fmt.Errorf("starting pty: %w", err)
 	}
@@ -82,16 +140,20 @@ func (t *Terminal) Run() error {
 		}
 	}()
 
+	// Without a terminal for input there is no window size or raw mode to
+	// manage; the command has the default size
+	if !isTerminal {
+		return t.copyAndWait(ptmx)
+	}
+
 	// Handle terminal resize with proper cleanup
 	sigCh := make(chan os.Signal, 1)
-	signal.Notify(sigCh, syscall.SIGWINCH)
+	notifyResize(sigCh)
 	sigDone := make(chan struct{})
 	go func() {
 		defer close(sigDone)
 		for range sigCh {
-			if err := pty.InheritSize(os.Stdin, ptmx); err != nil && IsDebug() {
-				fmt.Fprintf(os.Stderr, "[DEBUG] Error resizing pty: %v\n", err)
-			}
+			resize(tty, ptmx)
 		}
 	}()
 	// Cleanup signal handler when done
@@ -100,34 +162,89 @@ func (t *Terminal) Run() error {
 		close(sigCh)
 		<-sigDone // Wait for goroutine to exit
 	}()
+	// Catch a resize between starting the command and handling the signal
+	resize(tty, ptmx)
 
-	// Trigger initial resize
-	sigCh <- syscall.SIGWINCH
-
-	// Put terminal into raw mode
-	oldState, err := term.MakeRaw(int(os.Stdin.Fd()))
+	// Put terminal into raw mode; if that fails the session still works,
+	// just with the terminal's own line editing and echo
+	oldState, err := term.MakeRaw(int(tty.Fd()))
 	if err != nil {
-		return fmt.Errorf("setting raw mode: %w", err)
+		if IsDebug() {
+			fmt.Fprintf(os.Stderr, "[DEBUG] Error setting raw mode: %v\n", err)
+		}
+		return t.copyAndWait(ptmx)
 	}
 	defer func() {
-		if err := term.Restore(int(os.Stdin.Fd()), oldState); err != nil && IsDebug() {
+		if err := term.Restore(int(tty.Fd()), oldState); err != nil && IsDebug() {
 			fmt.Fprintf(os.Stderr, "[DEBUG] Error restoring terminal: %v\n", err)
 		}
 	}()
 
+	return t.copyAndWait(ptmx)
+}
+
+// resize copies the size of tty to the PTY, or sets the default size if
+// there is no tty or its size is unknown (an error, or 0 rows or columns)
+func resize(tty, ptmx *os.File) {
+	if err := pty.Setsize(ptmx, ptySize(tty)); err != nil && IsDebug() {
+		fmt.Fprintf(os.Stderr, "[DEBUG] Error resizing pty: %v\n", err)
+	}
+}
+
+// ptySize returns the size of tty, or the default size if there is no tty or
+// its size is unknown
+func ptySize(tty *os.File) *pty.Winsize {
+	size := &pty.Winsize{Rows: defaultRows, Cols: defaultCols}
+	if tty != nil {
+		ttySize, err := pty.GetsizeFull(tty)
+		switch {
+		case err == nil && ttySize.Rows > 0 && ttySize.Cols > 0:
+			size = ttySize
+		case IsDebug():
+			fmt.Fprintf(os.Stderr, "[DEBUG] Terminal size unknown, using %dx%d\n", defaultCols, defaultRows)
+		}
+	}
+	return size
+}
+
+// runPiped runs the command with pipes instead of a PTY, highlighting its
+// combined output. Programs that check for a terminal behave as if piped.
+func (t *Terminal) runPiped() error {
+	r, w, err := os.Pipe()
+	if err != nil {
+		return fmt.Errorf("creating pipe: %w", err)
+	}
+	defer r.Close()
+
+	t.cmd.Stdin = t.stdin
+	t.cmd.Stdout = w
+	t.cmd.Stderr = w
+	err = t.cmd.Start()
+	w.Close() // the command has its own copy
+	if err != nil {
+		return fmt.Errorf("starting command: %w", err)
+	}
+
+	t.processOutput(r, t.stdout)
+	return t.cmd.Wait()
+}
+
+// copyAndWait copies input to the PTY and highlighted PTY output to the
+// output writer until the command exits.
+func (t *Terminal) copyAndWait(ptmx *os.File) error {
 	// Create channel for coordination
 	done := make(chan struct{})
 
 	// Copy stdin to PTY
 	go func() {
-		if _, err := io.Copy(ptmx, os.Stdin); err != nil && IsDebug() {
+		if _, err := io.Copy(ptmx, t.stdin); err != nil && IsDebug() {
 			fmt.Fprintf(os.Stderr, "[DEBUG] Error copying stdin: %v\n", err)
 		}
 	}()
 
 	// Copy PTY to stdout with highlighting
 	go func() {
-		t.processOutput(ptmx, os.Stdout)
+		t.processOutput(ptmx, t.stdout)
 		close(done)
 	}()
 
@@ -139,10 +256,148 @@ func (t *Terminal) Run() error {
 	return nil
 }
 
-// processOutput reads from the PTY and writes highlighted output.
+// RunPaged starts the command in a PTY and pipes its highlighted output into
+// the given pager command. The pager inherits the real terminal so it can be
+// scrolled interactively; the wrapped command gets no keyboard input, so its
+// prompts fail instead of waiting behind the pager. Quitting the pager kills
+// the command.
+func (t *Terminal) RunPaged(pager *exec.Cmd) error {
+	// Format output for the real terminal width when there is one, or the
+	// default size otherwise
+	tty, _ := t.inputTerminal()
+	ptmx, err := startWithoutInput(t.cmd, ptySize(tty))
+	if err != nil {
+		return fmt.Errorf("starting pty: %w", err)
+	}
+	t.pty = ptmx
+	defer func() {
+		if err := ptmx.Close(); err != nil && IsDebug() {
+			fmt.Fprintf(os.Stderr, "[DEBUG] Error closing pty: %v\n", err)
+		}
+	}()
+
+	pagerIn, err := pager.StdinPipe()
+	if err != nil {
+		t.stopCommand()
+		return fmt.Errorf("connecting pager: %w", err)
+	}
+	if pager.Stdout == nil {
+		pager.Stdout = t.stdout
+	}
+	if pager.Stderr == nil {
+		pager.Stderr = os.Stderr
+	}
+	if err := pager.Start(); err != nil {
+		t.stopCommand()
+		return fmt.Errorf("starting pager: %w", err)
+	}
+
+	pagerDone := make(chan error, 1)
+	go func() { pagerDone <- pager.Wait() }()
+	outputDone := make(chan error, 1)
+	go func() { outputDone <- t.processOutput(ptmx, pagerIn) }()
+
+	// The session ends when the command's output ends and the pager has shown
+	// it, or when the pager quits early and the rest of the output is unwanted
+	var pagerErr error
+	stopped := false
+	select {
+	case err := <-outputDone:
+		if err := pagerIn.Close(); err != nil && IsDebug() {
+			fmt.Fprintf(os.Stderr, "[DEBUG] Error closing pager input: %v\n", err)
+		}
+		pagerErr = <-pagerDone
+		if err != nil {
+			// The pager stopped reading before the output ended
+			stopped = true
+			t.killCommand()
+		}
+	case pagerErr = <-pagerDone:
+		stopped = true
+		t.killCommand()
+		<-outputDone
+	}
+
+	cmdErr := t.cmd.Wait()
+	if pagerErr != nil {
+		return fmt.Errorf("pager finished: %w", pagerErr)
+	}
+	if cmdErr != nil && !stopped {
+		return fmt.Errorf("command finished: %w", cmdErr)
+	}
+	return nil
+}
+
+// startWithoutInput starts cmd in a PTY of the given size in a new session,
+// without a controlling terminal and with an empty stdin, so that prompts
+// (passwords, host keys) fail instead of waiting for keyboard input that
+// never comes
+func startWithoutInput(cmd *exec.Cmd, size *pty.Winsize) (*os.File, error) {
+	stdin, err := os.Open(os.DevNull)
+	if err != nil {
+		return nil, fmt.Errorf("opening %s: %w", os.DevNull, err)
+	}
+	defer stdin.Close()
+	cmd.Stdin = stdin
+	return pty.StartWithAttrs(cmd, size, newSessionAttr())
+}
+
+// killCommand kills the running command and anything it started
+func (t *Terminal) killCommand() {
+	if err := killSession(t.cmd); err != nil && IsDebug() {
+		fmt.Fprintf(os.Stderr, "[DEBUG] Error killing command: %v\n", err)
+	}
+}
+
+// stopCommand kills the running command and waits for it to exit
+func (t *Terminal) stopCommand() {
+	t.killCommand()
+	if err := t.cmd.Wait(); err != nil && IsDebug() {
+		fmt.Fprintf(os.Stderr, "[DEBUG] Command finished: %v\n", err)
+	}
+}
+
+// inputTerminal returns the input as a file if it is a terminal
+func (t *Terminal) inputTerminal() (*os.File, bool) {
+	f, ok := t.stdin.(*os.File)
+	if !ok || !term.IsTerminal(int(f.Fd())) {
+		return nil, false
+	}
+	return f, true
+}
+
+// PagerCommand builds the pager command from a $PAGER-style string, defaulting
+// to less. less is given -R so that color escape sequences are displayed.
+func PagerCommand(pager string) *exec.Cmd {
+	fields := strings.Fields(pager)
+	if len(fields) == 0 {
+		fields = []string{"less"}
+	}
+
+	if filepath.Base(fields[0]) == "less" && !hasRawFlag(fields[1:]) {
+		fields = append(fields, "-R")
+	}
+	return exec.Command(fields[0], fields[1:]...)
+}
+
+// hasRawFlag checks if less arguments already enable raw control characters
+func hasRawFlag(args []string) bool {
+	for _, arg := range args {
+		if arg == "--RAW-CONTROL-CHARS" || arg == "--raw-control-chars" {
+			return true
+		}
+		if strings.HasPrefix(arg, "-") && !strings.HasPrefix(arg, "--") && strings.ContainsAny(arg, "Rr") {
+			return true
+		}
+	}
+	return false
+}
+
+// processOutput reads from the PTY and writes highlighted output until the
+// input ends or a write fails, returning the write error.
 // Both complete lines and partial lines (prompts) are highlighted.
 // Cursor control characters (like \r) are preserved to allow command-line editing.
-func (t *Terminal) processOutput(r io.Reader, w io.Writer) {
+func (t *Terminal) processOutput(r io.Reader, w io.Writer) error {
 	buf := make([]byte, readBufferSize)
 	lineBuf := make([]byte, 0, lineBufferSize)
 
@@ -162,7 +417,9 @@ func (t *Terminal) processOutput(r io.Reader, w io.Writer) {
 
 				// Flush on newline or when buffer gets large
 				if b == '\n' || len(lineBuf) > lineFlushLimit {
-					t.writeOutput(w, lineBuf)
+					if err := t.writeOutput(w, lineBuf); err != nil {
+						return err
+					}
 					lineBuf = lineBuf[:0]
 				}
 			}
@@ -170,7 +427,9 @@ func (t *Terminal) processOutput(r io.Reader, w io.Writer) {
 			// Flush partial lines (prompts) - also highlighted
 			// Cursor control chars like \r are preserved by the lexer
 			if len(lineBuf) > 0 {
-				t.writeOutput(w, lineBuf)
+				if err := t.writeOutput(w, lineBuf); err != nil {
+					return err
+				}
 				lineBuf = lineBuf[:0]
 			}
 		}
@@ -179,16 +438,18 @@ func (t *Terminal) processOutput(r io.Reader, w io.Writer) {
 			if IsDebug() && err != io.EOF {
 				fmt.Fprintf(os.Stderr, "[DEBUG] Read error: %v\n", err)
 			}
-			break
+			return nil
 		}
 	}
 }
 
 // writeOutput writes data to the writer, optionally highlighting it.
-func (t *Terminal) writeOutput(w io.Writer, data []byte) {
+func (t *Terminal) writeOutput(w io.Writer, data []byte) error {
+	t.updateTitle(w, data)
+
 	var output string
 	if t.enabled {
-		output = t.highlighter.HighlightForced(string(data))
+		output = t.stream.HighlightForced(string(data))
 		if IsDebug() {
 			fmt.Fprintf(os.Stderr, "[DEBUG] Highlight: %q -> %q\n", data, output)
 		}
@@ -196,7 +457,28 @@ func (t *Terminal) writeOutput(w io.Writer, data []byte) {
 		output = string(data)
 	}
 
-	if _, err := w.Write([]byte(output)); err != nil && IsDebug() {
+	if _, err := w.Write([]byte(output)); err != nil {
+		if IsDebug() {
+			fmt.Fprintf(os.Stderr, "[DEBUG] Write error: %v\n", err)
+		}
+		return err
+	}
+	return nil
+}
+
+// updateTitle writes an OSC 2 title escape ahead of output that shows a
+// prompt for a different user@host than the last one, when enabled.
+func (t *Terminal) updateTitle(w io.Writer, data []byte) {
+	if !t.titleUpdates {
+		return
+	}
+	user, host, ok := lexer.PromptHost(highlighter.StripANSI(string(data)))
+	if !ok || user+"@"+host == t.title {
+		return
+	}
+	t.title = user + "@" + host
+
+	if _, err := fmt.Fprintf(w, titleEscape, t.title); err != nil && IsDebug() {
 		fmt.Fprintf(os.Stderr, "[DEBUG] Write error: %v\n", err)
 	}
 }
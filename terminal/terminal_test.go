@@ -2,11 +2,19 @@ package terminal
 
 import (
 	"bytes"
+	"context"
+	"fmt"
 	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
 	"strings"
 	"sync"
 	"testing"
+	"time"
 
+	"github.com/creack/pty"
 	"github.com/lasseh/jink/highlighter"
 )
 
@@ -159,6 +167,41 @@ func TestProcessOutputPartialLine(t *testing.T) {
 	}
 }
 
+func TestProcessOutputTitleUpdates(t *testing.T) {
+	input := "show version\nadmin@r1> show version\nHostname: r1\nadmin@r1> exit\n\r\n{master:0}\noper@r2-re0> "
+	title := "\033]2;admin@r1\007"
+
+	term := New("echo", "test")
+	var output bytes.Buffer
+	term.processOutput(strings.NewReader(input), &output)
+	if strings.Contains(output.String(), "\033]2;") {
+		t.Errorf("expected no title updates by default, got %q", output.String())
+	}
+
+	for _, enabled := range []bool{true, false} {
+		term = New("echo", "test")
+		term.SetEnabled(enabled)
+		term.SetTitleUpdates(true)
+		output.Reset()
+		term.processOutput(strings.NewReader(input), &output)
+		out := output.String()
+
+		if strings.Count(out, title) != 1 {
+			t.Errorf("highlighting %v: expected one %q for repeated prompts, got %q", enabled, title, out)
+		}
+		prompt := "oper@r2-re0> "
+		if enabled {
+			prompt = term.highlighter.HighlightForced(prompt)
+		}
+		if !strings.HasSuffix(out, "\033]2;oper@r2-re0\007"+prompt) {
+			t.Errorf("highlighting %v: expected the title ahead of the new prompt, got %q", enabled, out)
+		}
+		if stripped := strings.NewReplacer(title, "", "\033]2;oper@r2-re0\007", "").Replace(out); !enabled && stripped != input {
+			t.Errorf("expected output to be unchanged apart from titles, got %q", stripped)
+		}
+	}
+}
+
 func TestProcessOutputLargeBuffer(t *testing.T) {
 	term := New("echo", "test")
 	term.SetEnabled(false)
@@ -249,3 +292,296 @@ func TestProcessOutputHandlesErrors(t *testing.T) {
 		t.Errorf("expected 'test\\n', got %q", output.String())
 	}
 }
+
+func TestPagerCommand(t *testing.T) {
+	tests := []struct {
+		pager    string
+		expected []string
+	}{
+		{"", []string{"less", "-R"}},
+		{"less", []string{"less", "-R"}},
+		{"less -S", []string{"less", "-S", "-R"}},
+		{"less -R", []string{"less", "-R"}},
+		{"less -SR", []string{"less", "-SR"}},
+		{"/usr/bin/less --RAW-CONTROL-CHARS", []string{"/usr/bin/less", "--RAW-CONTROL-CHARS"}},
+		{"most -s", []string{"most", "-s"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.pager, func(t *testing.T) {
+			cmd := PagerCommand(tt.pager)
+			if strings.Join(cmd.Args, " ") != strings.Join(tt.expected, " ") {
+				t.Errorf("PagerCommand(%q) args = %q, want %q", tt.pager, cmd.Args, tt.expected)
+			}
+		})
+	}
+}
+
+func TestRunPaged(t *testing.T) {
+	term := New("echo", "set interfaces ge-0/0/0")
+
+	var paged bytes.Buffer
+	pager := exec.Command("cat")
+	pager.Stdout = &paged
+
+	if err := term.RunPaged(pager); err != nil {
+		t.Fatalf("RunPaged failed: %v", err)
+	}
+
+	if !strings.Contains(paged.String(), "\033[") {
+		t.Errorf("pager should receive highlighted output, got %q", paged.String())
+	}
+	if !strings.Contains(highlighter.StripANSI(paged.String()), "set interfaces ge-0/0/0") {
+		t.Errorf("pager should receive the command output, got %q", paged.String())
+	}
+}
+
+func TestRunPagedStopsCommandWhenPagerQuits(t *testing.T) {
+	term := New("yes", "set interfaces ge-0/0/0")
+	var paged syncBuffer
+	pager := exec.Command("head", "-n", "1")
+	pager.Stdout = &paged
+
+	done := make(chan error, 1)
+	go func() { done <- term.RunPaged(pager) }()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("RunPaged failed: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("RunPaged kept running the command after the pager quit")
+	}
+	if term.cmd.ProcessState == nil {
+		t.Error("expected the command to be waited on")
+	}
+}
+
+func TestRunPagedCommandCannotPrompt(t *testing.T) {
+	term := New("sh", "-c", "read line; echo stdin=$?; (: < /dev/tty) 2>/dev/null; echo tty=$?")
+	var paged bytes.Buffer
+	pager := exec.Command("cat")
+	pager.Stdout = &paged
+
+	done := make(chan error, 1)
+	go func() { done <- term.RunPaged(pager) }()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("RunPaged failed: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("RunPaged waited for keyboard input")
+	}
+	plain := highlighter.StripANSI(paged.String())
+	if strings.Contains(plain, "stdin=0") || strings.Contains(plain, "tty=0") {
+		t.Errorf("expected reading stdin and /dev/tty to fail, got %q", plain)
+	}
+}
+
+func TestRunPagedStopsCommandWhenPagerFails(t *testing.T) {
+	term := New("sleep", "20")
+	pager := exec.Command(filepath.Join(t.TempDir(), "no-such-pager"))
+
+	start := time.Now()
+	if err := term.RunPaged(pager); err == nil || !strings.Contains(err.Error(), "starting pager") {
+		t.Fatalf("expected a pager start error, got %v", err)
+	}
+	if term.cmd.ProcessState == nil {
+		t.Error("expected the command to be killed and waited on")
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("expected the command to be killed, took %v", elapsed)
+	}
+}
+
+func TestRunWithInputAndOutput(t *testing.T) {
+	term := New("cat")
+	term.SetInput(strings.NewReader("set interfaces ge-0/0/0\n\004"))
+
+	var out bytes.Buffer
+	term.SetOutput(&out)
+
+	if err := term.Run(); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+
+	if !strings.Contains(out.String(), "\033[") {
+		t.Errorf("output should be highlighted, got %q", out.String())
+	}
+	if !strings.Contains(highlighter.StripANSI(out.String()), "set interfaces ge-0/0/0") {
+		t.Errorf("output should contain the command output, got %q", out.String())
+	}
+}
+
+func TestRunWithoutTerminalSize(t *testing.T) {
+	script := "stty size; echo set interfaces ge-0/0/0"
+
+	check := func(name string, out string) {
+		t.Helper()
+		plain := highlighter.StripANSI(out)
+		if !strings.Contains(plain, fmt.Sprintf("%d %d", defaultRows, defaultCols)) {
+			t.Errorf("%s: expected the default size %dx%d, got %q", name, defaultCols, defaultRows, plain)
+		}
+		if !strings.Contains(out, "\033[") || !strings.Contains(plain, "set interfaces ge-0/0/0") {
+			t.Errorf("%s: expected highlighted output, got %q", name, out)
+		}
+	}
+
+	// Input that isn't a terminal, like under a test harness or cron
+	stdin, err := os.Open(os.DevNull)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stdin.Close()
+
+	term := New("sh", "-c", script)
+	term.SetInput(stdin)
+	var out bytes.Buffer
+	term.SetOutput(&out)
+	if err := term.Run(); err != nil {
+		t.Fatalf("Run with non-terminal input failed: %v", err)
+	}
+	check("non-terminal input", out.String())
+
+	// A terminal that reports no size
+	ptmx, tty, err := pty.Open()
+	if err != nil {
+		t.Skipf("no pty available: %v", err)
+	}
+	defer ptmx.Close()
+	defer tty.Close()
+
+	term = New("sh", "-c", script)
+	term.SetInput(tty)
+	out.Reset()
+	term.SetOutput(&out)
+	if err := term.Run(); err != nil {
+		t.Fatalf("Run with a sizeless terminal failed: %v", err)
+	}
+	check("sizeless terminal", out.String())
+
+	// A paged command, whose input is redirected too; it has no terminal on
+	// stdin, so the size is read from its output
+	term = New("sh", "-c", "stty size <&1; echo set interfaces ge-0/0/0")
+	term.SetInput(stdin)
+	out.Reset()
+	pager := exec.Command("cat")
+	pager.Stdout = &out
+	if err := term.RunPaged(pager); err != nil {
+		t.Fatalf("RunPaged with non-terminal input failed: %v", err)
+	}
+	check("paged", out.String())
+}
+
+func TestWatchRerunsCommand(t *testing.T) {
+	countFile := filepath.Join(t.TempDir(), "count")
+	term := New("sh", "-c", "echo run >> "+countFile+"; echo set interfaces ge-0/0/0")
+	term.SetInput(strings.NewReader(""))
+
+	var out bytes.Buffer
+	term.SetOutput(&out)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+	defer cancel()
+	if err := term.Watch(ctx, 50*time.Millisecond); err != nil {
+		t.Fatalf("Watch failed: %v", err)
+	}
+
+	data, err := os.ReadFile(countFile)
+	if err != nil {
+		t.Fatalf("command never ran: %v", err)
+	}
+	if runs := strings.Count(string(data), "run"); runs < 2 {
+		t.Errorf("expected the command to run several times, ran %d", runs)
+	}
+
+	output := out.String()
+	if strings.Count(output, clearScreen) < 2 {
+		t.Errorf("expected the screen to be cleared before each run, got %q", output)
+	}
+	if !strings.Contains(output, "\033[") || !strings.Contains(highlighter.StripANSI(output), "set interfaces ge-0/0/0") {
+		t.Errorf("expected highlighted command output, got %q", output)
+	}
+}
+
+func TestWatchStopsRunOnCancel(t *testing.T) {
+	term := New("sh", "-c", "echo started; sleep 20")
+	term.SetInput(strings.NewReader(""))
+	var out syncBuffer
+	term.SetOutput(&out)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- term.Watch(ctx, time.Minute) }()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for !strings.Contains(out.String(), "started") {
+		if time.Now().After(deadline) {
+			t.Fatal("command never started")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	cancel()
+
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("Watch failed: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Watch did not stop the running command on cancel")
+	}
+}
+
+func TestWatchCommandCannotPrompt(t *testing.T) {
+	// Reading stdin or opening /dev/tty must fail instead of waiting for input
+	term := New("sh", "-c", "read line; echo stdin=$?; (: < /dev/tty) 2>/dev/null; echo tty=$?")
+	ttyStatus := regexp.MustCompile(`tty=\d`)
+	term.SetInput(strings.NewReader(""))
+	var out syncBuffer
+	term.SetOutput(&out)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	go func() {
+		// The header repeats the command, so wait for an exit status
+		for !ttyStatus.MatchString(out.String()) && ctx.Err() == nil {
+			time.Sleep(10 * time.Millisecond)
+		}
+		cancel()
+	}()
+	if err := term.Watch(ctx, time.Minute); err != nil {
+		t.Fatalf("Watch failed: %v", err)
+	}
+
+	output := highlighter.StripANSI(out.String())
+	if !strings.Contains(output, "stdin=1") || strings.Contains(output, "tty=0") {
+		t.Errorf("expected stdin at EOF and no controlling terminal, got %q", output)
+	}
+}
+
+func TestWatchRejectsBadInterval(t *testing.T) {
+	term := New("true")
+	if err := term.Watch(context.Background(), 0); err == nil {
+		t.Error("expected an error for a zero interval")
+	}
+}
+
+// syncBuffer is a bytes.Buffer safe to read while Watch writes to it
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
@@ -0,0 +1,79 @@
+package terminal
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// clearScreen moves the cursor home and clears the screen
+const clearScreen = "\033[H\033[2J"
+
+// Watch runs the command every interval like watch(1), clearing the screen
+// and writing a header and the command's highlighted output each time. It
+// returns when ctx is cancelled (e.g. on Ctrl-C), killing a run in progress,
+// or when the command can't be started. A command that exits with an error is
+// simply run again. The command gets no keyboard input: its stdin is empty and
+// it has no controlling terminal, so prompts (passwords, host keys) fail
+// instead of hanging.
+func (t *Terminal) Watch(ctx context.Context, interval time.Duration) error {
+	if interval <= 0 {
+		return fmt.Errorf("watch interval must be positive, got %v", interval)
+	}
+
+	for ctx.Err() == nil {
+		if err := t.watchOnce(ctx, interval); err != nil {
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+		case <-time.After(interval):
+		}
+	}
+	return nil
+}
+
+// watchOnce clears the screen and runs a fresh copy of the command in a PTY,
+// killing its process group if ctx is cancelled before it exits
+func (t *Terminal) watchOnce(ctx context.Context, interval time.Duration) error {
+	// An exec.Cmd can only run once
+	cmd := exec.CommandContext(ctx, t.cmd.Args[0], t.cmd.Args[1:]...)
+	cmd.Env, cmd.Dir = t.cmd.Env, t.cmd.Dir
+	cmd.Cancel = func() error {
+		return killSession(cmd)
+	}
+
+	header := fmt.Sprintf("%sEvery %v: %s    %s\n\n", clearScreen, interval,
+		strings.Join(cmd.Args, " "), time.Now().Format("15:04:05"))
+	if _, err := t.stdout.Write([]byte(header)); err != nil && IsDebug() {
+		fmt.Fprintf(os.Stderr, "[DEBUG] Write error: %v\n", err)
+	}
+
+	// Output still goes to a PTY, formatted for the real terminal width when
+	// there is one, but the command can't prompt for input
+	tty, _ := t.inputTerminal()
+	ptmx, err := startWithoutInput(cmd, ptySize(tty))
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil
+		}
+		return fmt.Errorf("starting pty: %w", err)
+	}
+	defer func() {
+		if err := ptmx.Close(); err != nil && IsDebug() {
+			fmt.Fprintf(os.Stderr, "[DEBUG] Error closing pty: %v\n", err)
+		}
+	}()
+
+	// Each run's output is a new input
+	t.stream = t.highlighter.NewStream()
+	t.processOutput(ptmx, t.stdout)
+	if err := cmd.Wait(); err != nil && IsDebug() {
+		fmt.Fprintf(os.Stderr, "[DEBUG] Command finished: %v\n", err)
+	}
+	return nil
+}